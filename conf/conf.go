@@ -3,6 +3,8 @@ package conf
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -30,3 +32,33 @@ func Load() {
 	godotenv.Load()
 	validate()
 }
+
+// Returns the optional duration parameter key
+// (e.g. 15m, 720h), or def if it is unset or invalid.
+func Duration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		fmt.Printf("⚠️ Warning: invalid duration %v for %v, using %v\n", v, key, def)
+		return def
+	}
+	return d
+}
+
+// Returns the optional boolean parameter key
+// (true/false, 1/0), or def if it is unset or invalid.
+func Bool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		fmt.Printf("⚠️ Warning: invalid boolean %v for %v, using %v\n", v, key, def)
+		return def
+	}
+	return b
+}
@@ -1,128 +1,558 @@
 package db
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
 
+// Builds SELECT, INSERT, UPDATE and DELETE statements.
+// Values are always sent as $n parameters, numbered when
+// the query is built, so methods can be called in any
+// order. Table and column names are checked against
+// identRe (and the Allow list, if set); expressions
+// passed to SelectExpr, JoinExpr, GroupByExpr, SetExpr
+// and Expr are not, so never build those from user input.
 type DynamicQuery struct {
-	table      string
-	operation  string
-	where      string
-	argCount   int
-	ret        string
-	selectList string
-	group      string
-	insertList []string
-	argsList   []interface{}
+	table       string
+	operation   string
+	selectList  []string
+	joins       []Cond
+	sets        []assignment
+	where       []Cond
+	group       []string
+	having      []Cond
+	order       []string
+	limit       *int
+	offset      *int
+	conflict    []string
+	conflictSet []string
+	doNothing   bool
+	ret         []string
+	allowed     map[string]bool
+	refs        []string
+	errs        []error
 }
 
-func Update(table string) DynamicQuery {
-	d := DynamicQuery{}
+type assignment struct {
+	col  string
+	expr Cond
+}
+
+var (
+	identRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	tableRe  = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(?:\s+(?:AS\s+)?([A-Za-z_][A-Za-z0-9_]*))?$`)
+	selectRe = regexp.MustCompile(`^(\*|[A-Za-z_][A-Za-z0-9_]*\.\*|[A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)?)(?:\s+(?:AS\s+)?([A-Za-z_][A-Za-z0-9_]*))?$`)
+	orderRe  = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)?)(?:\s+(?i:ASC|DESC))?(?:\s+(?i:NULLS\s+(?:FIRST|LAST)))?$`)
+)
+
+var operators = map[string]bool{
+	"=": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true,
+	"LIKE": true, "ILIKE": true, "NOT LIKE": true, "NOT ILIKE": true,
+	"@>": true, "<@": true, "&&": true, "@@": true,
+}
+
+var ErrNothingToSet = errors.New("query has no columns to set")
+
+func newQuery(operation, table string) DynamicQuery {
+	d := DynamicQuery{operation: operation}
+	if !tableRe.MatchString(table) {
+		d.errs = append(d.errs, fmt.Errorf("invalid table %q", table))
+	}
 	d.table = table
-	d.operation = "UPDATE"
-	d.insertList = []string{}
-	d.argsList = []interface{}{}
-	d.argCount = 0
 	return d
 }
 
+func Update(table string) DynamicQuery {
+	return newQuery("UPDATE", table)
+}
+
 func Insert(table string) DynamicQuery {
-	d := DynamicQuery{}
-	d.table = table
-	d.operation = "INSERT"
-	d.insertList = []string{}
-	d.argsList = []interface{}{}
-	d.argCount = 0
-	return d
+	return newQuery("INSERT", table)
 }
 
 func SelectFrom(table string) DynamicQuery {
-	d := DynamicQuery{}
-	d.table = table
-	d.operation = "SELECT"
-	d.argsList = []interface{}{}
-	d.argCount = 0
-	d.selectList = ""
+	return newQuery("SELECT", table)
+}
+
+func Delete(table string) DynamicQuery {
+	return newQuery("DELETE", table)
+}
+
+// Restricts the columns the query may reference to cols.
+// Meant for queries whose columns are picked by clients,
+// e.g. a sort parameter.
+func (d DynamicQuery) Allow(cols ...string) DynamicQuery {
+	d.allowed = map[string]bool{}
+	for _, c := range cols {
+		d.allowed[c] = true
+	}
+	return d
+}
+
+func (d DynamicQuery) fail(err error) DynamicQuery {
+	d.errs = append(d.errs[:len(d.errs):len(d.errs)], err)
+	return d
+}
+
+// Adds columns to the select list. Each one is a column,
+// table.*, or * and may be followed by an alias.
+func (d DynamicQuery) Select(cols ...string) DynamicQuery {
+	for _, c := range cols {
+		if !selectRe.MatchString(c) {
+			d = d.fail(fmt.Errorf("invalid select column %q", c))
+			continue
+		}
+		d.selectList = append(d.selectList[:len(d.selectList):len(d.selectList)], c)
+	}
+	return d
+}
+
+// Adds a trusted expression, e.g. "COUNT(*) plays"
+func (d DynamicQuery) SelectExpr(expr string) DynamicQuery {
+	d.selectList = append(d.selectList[:len(d.selectList):len(d.selectList)], expr)
 	return d
 }
 
-func (d DynamicQuery) Select(col string) DynamicQuery {
-	if d.selectList == "" {
-		d.selectList = col
-	} else {
-		d.selectList += fmt.Sprintf(", %v", col)
+func (d DynamicQuery) join(kind, table, left, right string) DynamicQuery {
+	if !tableRe.MatchString(table) {
+		return d.fail(fmt.Errorf("invalid join table %q", table))
 	}
+	d = d.ref(left).ref(right)
+	d.joins = append(d.joins[:len(d.joins):len(d.joins)], Expr(fmt.Sprintf("%v JOIN %v ON %v = %v", kind, table, left, right)))
 	return d
 }
 
-func (d DynamicQuery) GroupBy(col string) DynamicQuery {
-	d.group = col
+// Adds a trusted join clause in which ? stands for each
+// of args, e.g. "CROSS JOIN gin_query(?) q"
+func (d DynamicQuery) JoinExpr(expr string, args ...interface{}) DynamicQuery {
+	d.joins = append(d.joins[:len(d.joins):len(d.joins)], Expr(expr, args...))
 	return d
 }
 
+// INNER JOIN table ON left = right
+func (d DynamicQuery) Join(table, left, right string) DynamicQuery {
+	return d.join("INNER", table, left, right)
+}
+
+func (d DynamicQuery) LeftJoin(table, left, right string) DynamicQuery {
+	return d.join("LEFT", table, left, right)
+}
+
+func (d DynamicQuery) GroupBy(cols ...string) DynamicQuery {
+	for _, c := range cols {
+		d = d.ref(c)
+		d.group = append(d.group[:len(d.group):len(d.group)], c)
+	}
+	return d
+}
+
+// Groups by a trusted expression, e.g. "date(timestamp)"
+func (d DynamicQuery) GroupByExpr(expr string) DynamicQuery {
+	d.group = append(d.group[:len(d.group):len(d.group)], expr)
+	return d
+}
+
+// ANDs c to the HAVING clause
+func (d DynamicQuery) Having(c Cond) DynamicQuery {
+	d.having = append(d.having[:len(d.having):len(d.having)], c)
+	return d
+}
+
+// Adds a sort column, e.g. "id DESC"
+func (d DynamicQuery) OrderBy(col string) DynamicQuery {
+	m := orderRe.FindStringSubmatch(col)
+	if m == nil {
+		return d.fail(fmt.Errorf("invalid order by %q", col))
+	}
+	d = d.ref(m[1])
+	d.order = append(d.order[:len(d.order):len(d.order)], col)
+	return d
+}
+
+func (d DynamicQuery) Limit(n int) DynamicQuery {
+	d.limit = &n
+	return d
+}
+
+func (d DynamicQuery) Offset(n int) DynamicQuery {
+	d.offset = &n
+	return d
+}
+
+// Sets column k to v in an INSERT or UPDATE
 func (d DynamicQuery) Set(k string, v interface{}) DynamicQuery {
-	d.insertList = append(d.insertList, k)
-	d.argsList = append(d.argsList, v)
-	d.argCount++
+	return d.SetExpr(k, "?", v)
+}
+
+// Sets column k to a trusted expression in which ?
+// stands for each of args, e.g. "version + ?"
+func (d DynamicQuery) SetExpr(k, expr string, args ...interface{}) DynamicQuery {
+	d = d.ref(k)
+	d.sets = append(d.sets[:len(d.sets):len(d.sets)], assignment{k, Expr(expr, args...)})
 	return d
 }
 
+// ANDs cond op val to the WHERE clause
 func (d DynamicQuery) Where(cond, op string, val interface{}) DynamicQuery {
-	d.where += fmt.Sprintf("%v %v $%v", cond, op, d.argCount+1)
-	d.argCount++
-	d.argsList = append(d.argsList, val)
-	return d
+	return d.Filter(Op(cond, op, val))
 }
 
+// Same as Where
 func (d DynamicQuery) And(cond, op string, val interface{}) DynamicQuery {
-	d.where += fmt.Sprintf(" AND %v %v $%v", cond, op, d.argCount+1)
-	d.argCount++
-	d.argsList = append(d.argsList, val)
+	return d.Where(cond, op, val)
+}
+
+// ANDs c to the WHERE clause. Use Or to build
+// alternatives, e.g. Filter(Or(IsNull("a"), Op("a", ">", 1)))
+func (d DynamicQuery) Filter(c Cond) DynamicQuery {
+	d.where = append(d.where[:len(d.where):len(d.where)], c)
 	return d
 }
 
-func (d DynamicQuery) Returning(col string) DynamicQuery {
-	d.ret = fmt.Sprintf(" RETURNING %v", col)
+// Turns an INSERT into an upsert on the unique cols.
+// Follow with DoUpdate or DoNothing.
+func (d DynamicQuery) OnConflict(cols ...string) DynamicQuery {
+	for _, c := range cols {
+		d = d.ref(c)
+	}
+	d.conflict = cols
 	return d
 }
 
-func (d DynamicQuery) Query() (string, []interface{}) {
-	if d.operation == "UPDATE" {
-		q := fmt.Sprintf("UPDATE %v SET ", d.table)
-		for i, v := range d.insertList {
-			if i == 0 {
-				q += fmt.Sprintf("%v = $%v", v, i+1)
-			} else {
-				q += fmt.Sprintf(", %v = $%v", v, i+1)
-			}
+// On conflict, sets cols to the values that were
+// being inserted (EXCLUDED.col)
+func (d DynamicQuery) DoUpdate(cols ...string) DynamicQuery {
+	for _, c := range cols {
+		d = d.ref(c)
+	}
+	d.conflictSet = cols
+	return d
+}
+
+func (d DynamicQuery) DoNothing() DynamicQuery {
+	d.doNothing = true
+	return d
+}
+
+func (d DynamicQuery) Returning(cols ...string) DynamicQuery {
+	for _, c := range cols {
+		if !selectRe.MatchString(c) {
+			return d.fail(fmt.Errorf("invalid returning column %q", c))
 		}
-		if d.where != "" {
-			q += fmt.Sprintf(" WHERE %v", d.where)
+	}
+	d.ret = append(d.ret[:len(d.ret):len(d.ret)], cols...)
+	return d
+}
+
+// Records a reference to col. References are checked
+// against the Allow list when the query is built, so
+// Allow can be called at any point.
+func (d DynamicQuery) ref(col string) DynamicQuery {
+	if !identRe.MatchString(col) {
+		return d.fail(fmt.Errorf("invalid column %q", col))
+	}
+	d.refs = append(d.refs[:len(d.refs):len(d.refs)], col)
+	return d
+}
+
+func checkCol(col string, allowed map[string]bool) error {
+	if !identRe.MatchString(col) {
+		return fmt.Errorf("invalid column %q", col)
+	}
+	if allowed != nil && !allowed[col] {
+		return fmt.Errorf("column %q is not allowed", col)
+	}
+	return nil
+}
+
+// Collects the query args, numbering their placeholders
+type argList struct {
+	args []interface{}
+}
+
+func (a *argList) add(v interface{}) string {
+	a.args = append(a.args, v)
+	return fmt.Sprintf("$%v", len(a.args))
+}
+
+// Renders conds joined by AND
+func (d DynamicQuery) renderConds(conds []Cond, a *argList) (string, error) {
+	parts := make([]string, 0, len(conds))
+	for _, c := range conds {
+		s, err := c.render(a, d.allowed)
+		if err != nil {
+			return "", err
 		}
-		q += ";"
-		return q, d.argsList
+		parts = append(parts, s)
 	}
-	if d.operation == "INSERT" {
-		valuesList := ""
-		params := ""
-		for i, v := range d.insertList {
-			if i > 0 {
-				valuesList += ", "
-				params += ", "
-			}
-			valuesList += v
-			params += fmt.Sprintf("$%v", i+1)
+	return strings.Join(parts, " AND "), nil
+}
+
+func (d DynamicQuery) renderWhere(a *argList) (string, error) {
+	if len(d.where) == 0 {
+		return "", nil
+	}
+	w, err := d.renderConds(d.where, a)
+	return " WHERE " + w, err
+}
+
+// Builds the query and its args
+func (d DynamicQuery) Build() (string, []interface{}, error) {
+	if len(d.errs) > 0 {
+		return "", nil, d.errs[0]
+	}
+	for _, c := range d.refs {
+		if err := checkCol(c, d.allowed); err != nil {
+			return "", nil, err
 		}
-		return fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)%v;", d.table, valuesList, params, d.ret), d.argsList
 	}
-	if d.operation == "SELECT" {
-		q := fmt.Sprintf("SELECT %v FROM %v", d.selectList, d.table)
-		if d.where != "" {
-			q += fmt.Sprintf(" WHERE %v", d.where)
+	a := &argList{args: []interface{}{}}
+	var q string
+	switch d.operation {
+	case "SELECT":
+		sel := "*"
+		if len(d.selectList) > 0 {
+			sel = strings.Join(d.selectList, ", ")
+		}
+		q = fmt.Sprintf("SELECT %v FROM %v", sel, d.table)
+		for _, j := range d.joins {
+			s, err := j.render(a, d.allowed)
+			if err != nil {
+				return "", nil, err
+			}
+			q += " " + s
+		}
+		w, err := d.renderWhere(a)
+		if err != nil {
+			return "", nil, err
+		}
+		q += w
+		if len(d.group) > 0 {
+			q += " GROUP BY " + strings.Join(d.group, ", ")
+		}
+		if len(d.having) > 0 {
+			h, err := d.renderConds(d.having, a)
+			if err != nil {
+				return "", nil, err
+			}
+			q += " HAVING " + h
+		}
+		if len(d.order) > 0 {
+			q += " ORDER BY " + strings.Join(d.order, ", ")
 		}
-		if d.group != "" {
-			q += fmt.Sprintf(" GROUP BY %v", d.group)
+		if d.limit != nil {
+			q += " LIMIT " + a.add(*d.limit)
 		}
-		q += ";"
-		return q, d.argsList
+		if d.offset != nil {
+			q += " OFFSET " + a.add(*d.offset)
+		}
+	case "INSERT":
+		if len(d.sets) == 0 {
+			return "", nil, ErrNothingToSet
+		}
+		cols := make([]string, 0, len(d.sets))
+		vals := make([]string, 0, len(d.sets))
+		for _, s := range d.sets {
+			v, err := s.expr.render(a, d.allowed)
+			if err != nil {
+				return "", nil, err
+			}
+			cols = append(cols, s.col)
+			vals = append(vals, v)
+		}
+		q = fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", d.table, strings.Join(cols, ", "), strings.Join(vals, ", "))
+		if d.conflict != nil {
+			q += fmt.Sprintf(" ON CONFLICT (%v)", strings.Join(d.conflict, ", "))
+			switch {
+			case d.doNothing:
+				q += " DO NOTHING"
+			case len(d.conflictSet) > 0:
+				set := make([]string, 0, len(d.conflictSet))
+				for _, c := range d.conflictSet {
+					set = append(set, fmt.Sprintf("%v = EXCLUDED.%v", c, c))
+				}
+				q += " DO UPDATE SET " + strings.Join(set, ", ")
+			default:
+				return "", nil, errors.New("ON CONFLICT needs DoUpdate or DoNothing")
+			}
+		}
+	case "UPDATE":
+		if len(d.sets) == 0 {
+			return "", nil, ErrNothingToSet
+		}
+		set := make([]string, 0, len(d.sets))
+		for _, s := range d.sets {
+			v, err := s.expr.render(a, d.allowed)
+			if err != nil {
+				return "", nil, err
+			}
+			set = append(set, fmt.Sprintf("%v = %v", s.col, v))
+		}
+		q = fmt.Sprintf("UPDATE %v SET %v", d.table, strings.Join(set, ", "))
+		w, err := d.renderWhere(a)
+		if err != nil {
+			return "", nil, err
+		}
+		q += w
+	case "DELETE":
+		q = fmt.Sprintf("DELETE FROM %v", d.table)
+		w, err := d.renderWhere(a)
+		if err != nil {
+			return "", nil, err
+		}
+		q += w
+	default:
+		return "", nil, fmt.Errorf("unknown operation %q", d.operation)
+	}
+	if len(d.ret) > 0 && d.operation != "SELECT" {
+		q += " RETURNING " + strings.Join(d.ret, ", ")
 	}
-	return "", nil
+	return q + ";", a.args, nil
+}
+
+// Like Build. Panics if the query is invalid, which
+// can only happen if the calling code passes a bad
+// identifier, operator or expression.
+func (d DynamicQuery) Query() (string, []interface{}) {
+	q, args, err := d.Build()
+	if err != nil {
+		panic(fmt.Sprintf("db: %v", err.Error()))
+	}
+	return q, args
+}
+
+// A predicate for Filter and Having
+type Cond struct {
+	render func(a *argList, allowed map[string]bool) (string, error)
+}
+
+// col op val, e.g. Op("created", ">=", from)
+func Op(col, op string, val interface{}) Cond {
+	return Cond{func(a *argList, allowed map[string]bool) (string, error) {
+		if err := checkCol(col, allowed); err != nil {
+			return "", err
+		}
+		if !operators[strings.ToUpper(op)] {
+			return "", fmt.Errorf("invalid operator %q", op)
+		}
+		return fmt.Sprintf("%v %v %v", col, op, a.add(val)), nil
+	}}
+}
+
+func in(col, op string, vals interface{}) Cond {
+	return Cond{func(a *argList, allowed map[string]bool) (string, error) {
+		if err := checkCol(col, allowed); err != nil {
+			return "", err
+		}
+		v := reflect.ValueOf(vals)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return "", fmt.Errorf("%v needs a slice, got %T", op, vals)
+		}
+		if v.Len() == 0 {
+			// x IN () is invalid SQL
+			if op == "IN" {
+				return "FALSE", nil
+			}
+			return "TRUE", nil
+		}
+		params := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			params = append(params, a.add(v.Index(i).Interface()))
+		}
+		return fmt.Sprintf("%v %v (%v)", col, op, strings.Join(params, ", ")), nil
+	}}
+}
+
+// col IN (vals...), vals must be a slice
+func In(col string, vals interface{}) Cond {
+	return in(col, "IN", vals)
+}
+
+func NotIn(col string, vals interface{}) Cond {
+	return in(col, "NOT IN", vals)
+}
+
+func IsNull(col string) Cond {
+	return Expr(col + " IS NULL").checking(col)
+}
+
+func IsNotNull(col string) Cond {
+	return Expr(col + " IS NOT NULL").checking(col)
+}
+
+// A trusted expression in which ? stands for each
+// of args, e.g. Expr("(rank, id) < (?, ?)", r, id).
+// ?? is a literal ?, for the jsonb operators ?, ?|
+// and ?&, e.g. Expr("body ?? ?", key).
+func Expr(expr string, args ...interface{}) Cond {
+	return Cond{func(a *argList, allowed map[string]bool) (string, error) {
+		if n := strings.Count(strings.ReplaceAll(expr, "??", ""), "?"); n != len(args) {
+			return "", fmt.Errorf("expression %q needs %v args, got %v", expr, n, len(args))
+		}
+		q := ""
+		i := 0
+		for j, chunk := range strings.Split(expr, "??") {
+			if j > 0 {
+				q += "?"
+			}
+			parts := strings.Split(chunk, "?")
+			q += parts[0]
+			for _, part := range parts[1:] {
+				q += a.add(args[i]) + part
+				i++
+			}
+		}
+		return q, nil
+	}}
+}
+
+// Checks col before rendering c
+func (c Cond) checking(col string) Cond {
+	return Cond{func(a *argList, allowed map[string]bool) (string, error) {
+		if err := checkCol(col, allowed); err != nil {
+			return "", err
+		}
+		return c.render(a, allowed)
+	}}
+}
+
+func group(sep string, conds []Cond) Cond {
+	return Cond{func(a *argList, allowed map[string]bool) (string, error) {
+		if len(conds) == 0 {
+			// Identity of the group
+			if sep == " OR " {
+				return "FALSE", nil
+			}
+			return "TRUE", nil
+		}
+		parts := make([]string, 0, len(conds))
+		for _, c := range conds {
+			s, err := c.render(a, allowed)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, s)
+		}
+		return "(" + strings.Join(parts, sep) + ")", nil
+	}}
+}
+
+// (c1 OR c2 ...)
+func Or(conds ...Cond) Cond {
+	return group(" OR ", conds)
+}
+
+// (c1 AND c2 ...)
+func And(conds ...Cond) Cond {
+	return group(" AND ", conds)
+}
+
+func Not(c Cond) Cond {
+	return Cond{func(a *argList, allowed map[string]bool) (string, error) {
+		s, err := c.render(a, allowed)
+		return "NOT (" + s + ")", err
+	}}
 }
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name  string
+		query DynamicQuery
+		sql   string
+		args  []interface{}
+	}{
+		{
+			"update numbers set before where",
+			Update("users").Where("id", "=", 1).Set("name", "a").Set("email", "b"),
+			"UPDATE users SET name = $1, email = $2 WHERE id = $3;",
+			[]interface{}{"a", "b", 1},
+		},
+		{
+			"update with expression and returning",
+			Update("drafts").Where("id", "=", 1).SetExpr("version", "version + ?", 1).Returning("version"),
+			"UPDATE drafts SET version = version + $1 WHERE id = $2 RETURNING version;",
+			[]interface{}{1, 1},
+		},
+		{
+			"insert",
+			Insert("events").Set("event_type", "game_start").Set("uid", 2).Returning("id"),
+			"INSERT INTO events (event_type, uid) VALUES ($1, $2) RETURNING id;",
+			[]interface{}{"game_start", 2},
+		},
+		{
+			"upsert",
+			Insert("ratings").Set("level_id", 1).Set("uid", 2).Set("stars", 5).OnConflict("level_id", "uid").DoUpdate("stars"),
+			"INSERT INTO ratings (level_id, uid, stars) VALUES ($1, $2, $3) ON CONFLICT (level_id, uid) DO UPDATE SET stars = EXCLUDED.stars;",
+			[]interface{}{1, 2, 5},
+		},
+		{
+			"insert or ignore",
+			Insert("tags").Set("name", "ice").OnConflict("name").DoNothing(),
+			"INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO NOTHING;",
+			[]interface{}{"ice"},
+		},
+		{
+			"delete",
+			Delete("drafts").Where("id", "=", 1).And("uid", "=", 2),
+			"DELETE FROM drafts WHERE id = $1 AND uid = $2;",
+			[]interface{}{1, 2},
+		},
+		{
+			"select all by default",
+			SelectFrom("levels"),
+			"SELECT * FROM levels;",
+			[]interface{}{},
+		},
+		{
+			"or groups, in and is null",
+			SelectFrom("levels").Select("id", "name").
+				Filter(Or(IsNull("updated"), Op("updated", "<", "2022-01-01"))).
+				Filter(In("theme", []int{1, 2})).
+				Where("uid", "=", 3),
+			"SELECT id, name FROM levels WHERE (updated IS NULL OR updated < $1) AND theme IN ($2, $3) AND uid = $4;",
+			[]interface{}{"2022-01-01", 1, 2, 3},
+		},
+		{
+			"empty in",
+			SelectFrom("levels").Filter(In("id", []int{})).Filter(NotIn("id", []int{})),
+			"SELECT * FROM levels WHERE FALSE AND TRUE;",
+			[]interface{}{},
+		},
+		{
+			"joins",
+			SelectFrom("collection c").Select("c.*", "u.name user_name").
+				Join("users u", "c.uid", "u.id").LeftJoin("collection_levels cl", "cl.collection_id", "c.id").
+				Where("c.id", "=", 1),
+			"SELECT c.*, u.name user_name FROM collection c INNER JOIN users u ON c.uid = u.id LEFT JOIN collection_levels cl ON cl.collection_id = c.id WHERE c.id = $1;",
+			[]interface{}{1},
+		},
+		{
+			"join expressions are numbered before where",
+			SelectFrom("levels t").SelectExpr("t.*, ts_rank(t.ts, q) AS rank").
+				Where("t.uid", "=", 3).
+				JoinExpr("CROSS JOIN gin_query(?) q", "ice"),
+			"SELECT t.*, ts_rank(t.ts, q) AS rank FROM levels t CROSS JOIN gin_query($1) q WHERE t.uid = $2;",
+			[]interface{}{"ice", 3},
+		},
+		{
+			"group, having, order, limit and offset",
+			SelectFrom("events").SelectExpr("level_id, COUNT(*) plays").
+				Offset(20).Limit(10).OrderBy("plays DESC").
+				Having(Expr("COUNT(*) > ?", 5)).GroupBy("level_id").
+				Where("event_type", "=", "game_start"),
+			"SELECT level_id, COUNT(*) plays FROM events WHERE event_type = $1 GROUP BY level_id HAVING COUNT(*) > $2 ORDER BY plays DESC LIMIT $3 OFFSET $4;",
+			[]interface{}{"game_start", 5, 10, 20},
+		},
+		{
+			"escaped jsonb operators",
+			SelectFrom("events").Filter(Expr("body ?? ? AND body ??| ?", "levelId", "{a,b}")),
+			"SELECT * FROM events WHERE body ? $1 AND body ?| $2;",
+			[]interface{}{"levelId", "{a,b}"},
+		},
+		{
+			"not",
+			SelectFrom("levels").Filter(Not(And(Op("a", "=", 1), Op("b", "=", 2)))),
+			"SELECT * FROM levels WHERE NOT ((a = $1 AND b = $2));",
+			[]interface{}{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := tt.query.Build()
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if sql != tt.sql {
+				t.Errorf("expected\n%v\ngot\n%v", tt.sql, sql)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("expected args %v, got %v", tt.args, args)
+			}
+		})
+	}
+}
+
+func TestBuildRejectsInvalidQueries(t *testing.T) {
+	tests := map[string]DynamicQuery{
+		"table":           SelectFrom("levels; DROP TABLE users"),
+		"select column":   SelectFrom("levels").Select("id, (SELECT password FROM users)"),
+		"where column":    SelectFrom("levels").Where("id = 1 OR 1", "=", 1),
+		"operator":        SelectFrom("levels").Where("id", "= 1 OR id =", 1),
+		"order by":        SelectFrom("levels").OrderBy("id; DELETE FROM levels"),
+		"set column":      Update("levels").Set("name = 'x', uid", 1),
+		"join":            SelectFrom("levels l").Join("users u", "l.uid", "u.id OR TRUE"),
+		"not allowed":     SelectFrom("levels").OrderBy("password DESC").Allow("id", "created"),
+		"allow after use": SelectFrom("levels").Allow("id").Where("uid", "=", 1),
+		"nothing to set":  Update("levels").Where("id", "=", 1),
+		"conflict action": Insert("tags").Set("name", "a").OnConflict("name"),
+		"expression args": SelectFrom("levels").Filter(Expr("id = ?")),
+		"escaped args":    SelectFrom("levels").Filter(Expr("body ?? key", 1)),
+		"in non slice":    SelectFrom("levels").Filter(In("id", 1)),
+	}
+	for name, q := range tests {
+		if sql, _, err := q.Build(); err == nil {
+			t.Errorf("%v: expected an error, got %v", name, sql)
+		}
+	}
+}
+
+func TestQueryPanicsOnInvalidIdentifiers(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected Query to panic")
+		}
+	}()
+	SelectFrom("levels").Where("1=1 --", "=", 1).Query()
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Key of the advisory lock held while migrating, so
+// two instances booting at once don't race each other
+const migrationLockKey = 7_311_001
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied sql.NullTime
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+const migrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);`
+
+// Reads the migrations in fsys, sorted by version.
+// Every version must have both an up and a down file.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, f := range files {
+		m := migrationFile.FindStringSubmatch(f.Name())
+		if m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(fsys, f.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %v has two names: %v and %v", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+	res := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%v needs both up and down files", m.Version, m.Name)
+		}
+		res = append(res, *m)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+func (c *client) appliedMigrations() (map[int]time.Time, error) {
+	if _, err := c.Client.Exec(migrationsTable); err != nil {
+		return nil, err
+	}
+	rows := []struct {
+		Version int       `db:"version"`
+		Applied time.Time `db:"applied"`
+	}{}
+	err := c.Client.Select(&rows, "SELECT version, applied FROM schema_migrations;")
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r.Applied
+	}
+	return applied, nil
+}
+
+// Runs a migration step in its own transaction while
+// holding the migration lock. The step is skipped if
+// another instance already did it.
+func (c *client) migrateStep(m Migration, up bool) (bool, error) {
+	tx, err := c.Client.Beginx()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1);", migrationLockKey); err != nil {
+		return false, err
+	}
+	var n int
+	if err := tx.Get(&n, "SELECT COUNT(*) FROM schema_migrations WHERE version = $1;", m.Version); err != nil {
+		return false, err
+	}
+	if (n > 0) == up {
+		return false, nil
+	}
+	script := m.Down
+	if up {
+		script = m.Up
+	}
+	if _, err := tx.Exec(script); err != nil {
+		return false, fmt.Errorf("migration %04d_%v: %v", m.Version, m.Name, err.Error())
+	}
+	if up {
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2);", m.Version, m.Name)
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = $1;", m.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Applies every pending migration in fsys, in order.
+// Returns the migrations that were applied.
+func (c *client) MigrateUp(fsys fs.FS) ([]Migration, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := c.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	done := []Migration{}
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		ran, err := c.migrateStep(m, true)
+		if err != nil {
+			return done, err
+		}
+		if ran {
+			done = append(done, m)
+		}
+	}
+	return done, nil
+}
+
+// Reverts the last steps applied migrations, newest
+// first. Returns the migrations that were reverted.
+func (c *client) MigrateDown(fsys fs.FS, steps int) ([]Migration, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := c.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	done := []Migration{}
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		ran, err := c.migrateStep(m, false)
+		if err != nil {
+			return done, err
+		}
+		if ran {
+			done = append(done, m)
+		}
+	}
+	return done, nil
+}
+
+// Lists every migration in fsys and when it was applied
+func (c *client) MigrationStatus(fsys fs.FS) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := c.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Migration: m}
+		if t, ok := applied[m.Version]; ok {
+			s.Applied = sql.NullTime{Time: t, Valid: true}
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Implemented by both *sqlx.DB and *sqlx.Tx, so
+// model code can run inside or outside a transaction
+type Queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+}
+
+// Runs fn inside a transaction. The transaction is
+// committed if fn returns nil and rolled back otherwise
+// (also if fn panics).
+func (c *client) Tx(fn func(tx Queryer) error) error {
+	tx, err := c.Client.Beginx()
+	if err != nil {
+		return fmt.Errorf("could not start transaction: %v", err.Error())
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,103 @@
+package mail
+
+import (
+	"fmt"
+	"io"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// A Mailer delivers messages to users.
+// The mailer in use is chosen with the
+// MAILER config parameter (log or smtp).
+type Mailer interface {
+	Send(m Message) error
+}
+
+// Writes messages to an io.Writer instead of
+// delivering them. Meant for local development
+// and tests.
+type LogMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogMailer(w io.Writer) *LogMailer {
+	return &LogMailer{w: w}
+}
+
+func (l *LogMailer) Send(m Message) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := fmt.Fprintf(l.w, "📧 [%v] To: %v\nSubject: %v\n\n%v\n\n", time.Now().Format(time.RFC3339), m.To, m.Subject, m.Body)
+	return err
+}
+
+// Delivers messages through an SMTP server
+type SMTPMailer struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+func (s *SMTPMailer) Send(m Message) error {
+	msg := fmt.Sprintf("From: %v\r\nTo: %v\r\nSubject: %v\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%v\r\n",
+		s.From, m.To, m.Subject, strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return smtp.SendMail(s.Addr, s.Auth, s.From, []string{m.To}, []byte(msg))
+}
+
+var Sender Mailer = NewLogMailer(os.Stdout)
+
+// Configures Sender from the environment. MAILER=smtp
+// uses SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD
+// and MAIL_FROM. Otherwise messages are logged to
+// MAIL_LOG_FILE, or stdout if it is not set.
+func Setup() error {
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" || os.Getenv("MAIL_FROM") == "" {
+			return fmt.Errorf("MAILER=smtp requires SMTP_HOST and MAIL_FROM")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		var auth smtp.Auth
+		if os.Getenv("SMTP_USER") != "" {
+			auth = smtp.PlainAuth("", os.Getenv("SMTP_USER"), os.Getenv("SMTP_PASSWORD"), host)
+		}
+		Sender = &SMTPMailer{
+			Addr: fmt.Sprintf("%v:%v", host, port),
+			From: os.Getenv("MAIL_FROM"),
+			Auth: auth,
+		}
+	case "", "log":
+		path := os.Getenv("MAIL_LOG_FILE")
+		if path == "" {
+			Sender = NewLogMailer(os.Stdout)
+			return nil
+		}
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("could not open mail log: %v", err.Error())
+		}
+		Sender = NewLogMailer(f)
+	default:
+		return fmt.Errorf("unknown mailer %v", os.Getenv("MAILER"))
+	}
+	return nil
+}
+
+func Send(m Message) error {
+	return Sender.Send(m)
+}
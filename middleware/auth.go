@@ -1,11 +1,15 @@
 package middleware
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"github.com/sofferjacob/maker_api/conf"
+	"github.com/sofferjacob/maker_api/models"
 )
 
 const BEARER_SCHEMA string = "Bearer"
@@ -18,14 +22,17 @@ func RequireAuth() gin.HandlerFunc {
 			return
 		}
 		token := authHeader[len(BEARER_SCHEMA)+1:]
-		tk, err := jwt.ParseWithClaims(token, &jwt.StandardClaims{}, func(token *jwt.Token) (interface{}, error) {
+		tk, err := jwt.ParseWithClaims(token, &models.Claims{}, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
 			return []byte(os.Getenv("AUTH_KEY")), nil
 		})
 		if err != nil {
 			c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden", "message": err.Error()})
 			return
 		}
-		claims, ok := tk.Claims.(*jwt.StandardClaims)
+		claims, ok := tk.Claims.(*models.Claims)
 		if !ok {
 			c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden", "message": "claim extraction failed"})
 			return
@@ -34,6 +41,73 @@ func RequireAuth() gin.HandlerFunc {
 			c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden", "message": "expired token"})
 			return
 		}
+		// Tokens are tied to a session, which is
+		// revoked on logout
+		sessionId, err := strconv.Atoi(claims.Id)
+		if err != nil {
+			c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden", "message": "token has no session"})
+			return
+		}
+		active, err := models.IsSessionActive(sessionId)
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"error": "could not verify session", "message": err.Error()})
+			return
+		}
+		if !active {
+			c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden", "message": "revoked token"})
+			return
+		}
 		c.Set("user-claims", claims)
 	}
 }
+
+// Rejects users that haven't verified their email when
+// the REQUIRE_VERIFIED_EMAIL policy is enabled. Must run
+// after RequireAuth.
+func RequireVerified() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !conf.Bool("REQUIRE_VERIFIED_EMAIL", false) {
+			return
+		}
+		claims, ok := userClaims(c)
+		if !ok {
+			return
+		}
+		uid, _ := strconv.Atoi(claims.Subject)
+		verified, err := models.IsVerified(uid)
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"error": "could not check verification", "message": err.Error()})
+			return
+		}
+		if !verified {
+			c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden", "message": "email must be verified"})
+			return
+		}
+	}
+}
+
+// Only lets users with one of the given roles through.
+// Must run after RequireAuth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := userClaims(c)
+		if !ok {
+			return
+		}
+		if !claims.HasRole(roles...) {
+			c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden", "message": "insufficient role"})
+			return
+		}
+	}
+}
+
+// Returns the claims set by RequireAuth, aborting
+// the request if there are none
+func userClaims(c *gin.Context) (*models.Claims, bool) {
+	claimsObj, _ := c.Get("user-claims")
+	claims, ok := claimsObj.(*models.Claims)
+	if !ok {
+		c.AbortWithStatusJSON(403, gin.H{"error": "Forbidden", "message": "a valid token must be provided"})
+	}
+	return claims, ok
+}
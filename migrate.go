@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sofferjacob/maker_api/db"
+	"github.com/sofferjacob/maker_api/migrations"
+)
+
+const migrateUsage = `usage: maker_api migrate <command>
+
+commands:
+  up         apply all pending migrations
+  down [n]   revert the last n applied migrations (default 1)
+  status     list migrations and when they were applied`
+
+// Runs the migrate subcommand. Expects the
+// database to be connected.
+func migrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		return 2
+	}
+	switch args[0] {
+	case "up":
+		applied, err := db.Client.MigrateUp(migrations.FS)
+		for _, m := range applied {
+			fmt.Printf("⬆️  %04d_%v\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err.Error())
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("✅ Database is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Printf("❌ Error: invalid number of steps %v\n", args[1])
+				return 2
+			}
+			steps = n
+		}
+		reverted, err := db.Client.MigrateDown(migrations.FS, steps)
+		for _, m := range reverted {
+			fmt.Printf("⬇️  %04d_%v\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err.Error())
+			return 1
+		}
+	case "status":
+		status, err := db.Client.MigrationStatus(migrations.FS)
+		if err != nil {
+			fmt.Printf("❌ Error: %v\n", err.Error())
+			return 1
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.Applied.Valid {
+				applied = s.Applied.Time.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30v %v\n", s.Version, s.Name, applied)
+		}
+	default:
+		fmt.Println(migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,14 @@
+DROP VIEW IF EXISTS trending_collections;
+DROP VIEW IF EXISTS trending_levels;
+DROP VIEW IF EXISTS leaderboard;
+DROP FUNCTION IF EXISTS query_gin(anyelement, TEXT);
+DROP TABLE IF EXISTS drafts;
+DROP TABLE IF EXISTS course_data;
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS collection_levels;
+DROP TABLE IF EXISTS levels;
+DROP TABLE IF EXISTS collection;
+DROP TABLE IF EXISTS users;
+DROP FUNCTION IF EXISTS on_level_create();
+DROP FUNCTION IF EXISTS on_course_data_update();
+DROP FUNCTION IF EXISTS on_draft_update();
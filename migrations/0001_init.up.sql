@@ -2,6 +2,9 @@
 -- Made for PostgreSQL
 -- Jacobo Soffer Levy | A01028653
 -- 18/05/2022
+-- Migration 1 is the schema that used to be
+-- loaded from sql/init.sql on every boot, so
+-- it is safe to run on databases created that way.
 CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     name VARCHAR(50) NOT NULL,
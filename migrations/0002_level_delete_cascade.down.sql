@@ -0,0 +1,15 @@
+-- Events of deleted levels have to go before
+-- the foreign key can be added back
+DELETE FROM events WHERE level_id IS NOT NULL
+    AND level_id NOT IN (SELECT id FROM levels);
+ALTER TABLE events ADD CONSTRAINT events_level_id_fkey
+    FOREIGN KEY (level_id) REFERENCES levels(id);
+ALTER TABLE collection_levels DROP CONSTRAINT collection_levels_level_id_fkey,
+    ADD CONSTRAINT collection_levels_level_id_fkey FOREIGN KEY (level_id)
+    REFERENCES levels(id);
+ALTER TABLE drafts DROP CONSTRAINT drafts_level_id_fkey,
+    ADD CONSTRAINT drafts_level_id_fkey FOREIGN KEY (level_id)
+    REFERENCES levels(id);
+ALTER TABLE course_data DROP CONSTRAINT course_data_level_id_fkey,
+    ADD CONSTRAINT course_data_level_id_fkey FOREIGN KEY (level_id)
+    REFERENCES levels(id);
@@ -0,0 +1,13 @@
+-- Deleting a level removes its course data, drafts
+-- and collection links. Events are an analytics log
+-- and keep the level id after the level is gone.
+ALTER TABLE course_data DROP CONSTRAINT IF EXISTS course_data_level_id_fkey,
+    ADD CONSTRAINT course_data_level_id_fkey FOREIGN KEY (level_id)
+    REFERENCES levels(id) ON DELETE CASCADE;
+ALTER TABLE drafts DROP CONSTRAINT IF EXISTS drafts_level_id_fkey,
+    ADD CONSTRAINT drafts_level_id_fkey FOREIGN KEY (level_id)
+    REFERENCES levels(id) ON DELETE CASCADE;
+ALTER TABLE collection_levels DROP CONSTRAINT IF EXISTS collection_levels_level_id_fkey,
+    ADD CONSTRAINT collection_levels_level_id_fkey FOREIGN KEY (level_id)
+    REFERENCES levels(id) ON DELETE CASCADE;
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_level_id_fkey;
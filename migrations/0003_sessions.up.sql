@@ -0,0 +1,17 @@
+-- Every login opens a session. Access tokens carry
+-- the session id and refresh tokens are stored as
+-- sha256 hashes, rotated on every refresh.
+CREATE TABLE IF NOT EXISTS sessions (
+    id SERIAL PRIMARY KEY,
+    uid INT NOT NULL,
+    refresh_hash TEXT UNIQUE NOT NULL,
+    previous_hash TEXT,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    refreshed TIMESTAMP,
+    expires TIMESTAMP NOT NULL,
+    revoked TIMESTAMP,
+    FOREIGN KEY (uid) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS sessions_uid_idx ON sessions (uid);
+CREATE INDEX IF NOT EXISTS sessions_previous_hash_idx ON sessions (previous_hash);
@@ -0,0 +1,14 @@
+-- Single use tokens mailed to users (e.g. password
+-- resets). Only the sha256 hash is stored.
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id SERIAL PRIMARY KEY,
+    uid INT NOT NULL,
+    purpose VARCHAR(20) NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires TIMESTAMP NOT NULL,
+    used TIMESTAMP,
+    FOREIGN KEY (uid) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS user_tokens_uid_idx ON user_tokens (uid, purpose);
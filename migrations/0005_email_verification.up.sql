@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS verified BOOLEAN NOT NULL DEFAULT false;
+-- Accounts made before verification existed can keep
+-- publishing, the default only applies to new accounts
+UPDATE users SET verified = true;
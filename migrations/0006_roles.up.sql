@@ -0,0 +1,2 @@
+-- One of user, moderator or admin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
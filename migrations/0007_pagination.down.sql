@@ -0,0 +1,29 @@
+DROP INDEX IF EXISTS events_leaderboard_idx;
+DROP INDEX IF EXISTS collection_levels_collection_id_idx;
+DROP INDEX IF EXISTS collection_uid_id_idx;
+DROP INDEX IF EXISTS drafts_uid_id_idx;
+DROP INDEX IF EXISTS levels_uid_id_idx;
+
+DROP VIEW IF EXISTS leaderboard;
+CREATE VIEW leaderboard AS
+    SELECT e.level_id, e.uid, e.time, e.timestamp, u.name FROM events e
+        INNER JOIN users u ON e.uid = u.id
+        WHERE e.event_type = 'game_finish'
+        ORDER BY e.time;
+
+CREATE OR REPLACE FUNCTION query_gin(_rowtype anyelement, q TEXT)
+    RETURNS SETOF anyelement
+    LANGUAGE PLPGSQL
+    AS
+$$
+DECLARE
+    query_str TEXT;
+    query_ts tsquery;
+BEGIN
+    SELECT REPLACE(q, ' ', ' <2> ') INTO query_str;
+    EXECUTE format('SELECT to_tsquery(%L, %L)', 'spanish', query_str) INTO query_ts;
+    RETURN QUERY EXECUTE format('SELECT * FROM %s WHERE ts @@ %L ORDER BY ts_rank(ts, %L) DESC', pg_typeof(_rowtype), query_ts, query_ts);
+END
+$$;
+
+DROP FUNCTION IF EXISTS gin_query(TEXT);
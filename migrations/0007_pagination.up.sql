@@ -0,0 +1,40 @@
+-- Lists are paginated by keyset (see models.Page)
+
+-- The tsquery query_gin searches with, so search
+-- results can be ranked and paginated outside it
+CREATE OR REPLACE FUNCTION gin_query(q TEXT)
+    RETURNS tsquery
+    LANGUAGE SQL
+    STABLE
+    AS
+$$
+    SELECT to_tsquery('spanish', REPLACE(q, ' ', ' <2> '));
+$$;
+
+CREATE OR REPLACE FUNCTION query_gin(_rowtype anyelement, q TEXT)
+    RETURNS SETOF anyelement
+    LANGUAGE PLPGSQL
+    AS
+$$
+DECLARE
+    query_ts tsquery;
+BEGIN
+    query_ts := gin_query(q);
+    RETURN QUERY EXECUTE format('SELECT * FROM %s WHERE ts @@ %L ORDER BY ts_rank(ts, %L) DESC', pg_typeof(_rowtype), query_ts, query_ts);
+END
+$$;
+
+-- Events have no natural order within the same
+-- time, so the leaderboard exposes the event id
+CREATE OR REPLACE VIEW leaderboard AS
+    SELECT e.level_id, e.uid, e.time, e.timestamp, u.name, e.id FROM events e
+        INNER JOIN users u ON e.uid = u.id
+        WHERE e.event_type = 'game_finish'
+        ORDER BY e.time;
+
+CREATE INDEX IF NOT EXISTS levels_uid_id_idx ON levels (uid, id);
+CREATE INDEX IF NOT EXISTS drafts_uid_id_idx ON drafts (uid, id);
+CREATE INDEX IF NOT EXISTS collection_uid_id_idx ON collection (uid, id);
+CREATE INDEX IF NOT EXISTS collection_levels_collection_id_idx ON collection_levels (collection_id, id);
+CREATE INDEX IF NOT EXISTS events_leaderboard_idx ON events (level_id, time, id)
+    WHERE event_type = 'game_finish';
@@ -0,0 +1,4 @@
+DROP VIEW IF EXISTS top_rated_levels;
+DROP VIEW IF EXISTS level_ratings;
+DROP FUNCTION IF EXISTS bayesian_rating(BIGINT, FLOAT8);
+DROP TABLE IF EXISTS level_votes;
@@ -0,0 +1,45 @@
+-- Every user has at most one vote per level, a like or
+-- dislike and/or a 1-5 star rating. NULL means the user
+-- didn't give that part of the vote.
+CREATE TABLE IF NOT EXISTS level_votes (
+    level_id INT NOT NULL,
+    uid INT NOT NULL,
+    liked BOOLEAN,
+    stars SMALLINT CHECK (stars BETWEEN 1 AND 5),
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMP,
+    PRIMARY KEY (level_id, uid),
+    FOREIGN KEY (level_id) REFERENCES levels(id) ON DELETE CASCADE,
+    FOREIGN KEY (uid) REFERENCES users(id)
+);
+
+-- Bayesian average of a level's stars: its average pulled
+-- towards the average of every rating, weighted as 5
+-- ratings, so levels with a handful of ratings don't top
+-- the ranking. Keep in sync with models.BayesianRating.
+CREATE OR REPLACE FUNCTION bayesian_rating(ratings BIGINT, avg_stars FLOAT8)
+    RETURNS FLOAT8
+    LANGUAGE SQL
+    STABLE
+    AS
+$$
+    SELECT (5 * (SELECT COALESCE(AVG(stars), 3)::float8 FROM level_votes) + ratings * avg_stars) / (5 + ratings);
+$$;
+
+CREATE OR REPLACE VIEW level_ratings AS
+    SELECT r.*, bayesian_rating(r.ratings, r.avg_stars) score FROM (
+        SELECT level_id,
+            COUNT(*) FILTER (WHERE liked) likes,
+            COUNT(*) FILTER (WHERE NOT liked) dislikes,
+            COUNT(stars) ratings,
+            COALESCE(AVG(stars), 0)::float8 avg_stars
+        FROM level_votes
+        GROUP BY level_id
+    ) r;
+
+-- Rated levels, best first
+CREATE OR REPLACE VIEW top_rated_levels AS
+    SELECT r.likes, r.dislikes, r.ratings, r.avg_stars, r.score, l.* FROM level_ratings r
+        INNER JOIN levels l ON r.level_id = l.id
+        WHERE r.ratings > 0
+        ORDER BY r.score DESC, l.id DESC;
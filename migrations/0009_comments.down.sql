@@ -0,0 +1,2 @@
+DROP VIEW IF EXISTS comment_data;
+DROP TABLE IF EXISTS comments;
@@ -0,0 +1,36 @@
+-- Comments belong to either a level or a collection.
+-- Replies have the target of the comment they reply
+-- to and are deleted with it.
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    uid INT NOT NULL,
+    level_id INT,
+    collection_id INT,
+    parent_id INT,
+    body TEXT NOT NULL,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMP,
+    FOREIGN KEY (uid) REFERENCES users(id),
+    FOREIGN KEY (level_id) REFERENCES levels(id) ON DELETE CASCADE,
+    FOREIGN KEY (collection_id) REFERENCES collection(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE,
+    CHECK ((level_id IS NULL) <> (collection_id IS NULL))
+);
+
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS ts tsvector
+    GENERATED ALWAYS AS (to_tsvector('spanish', body)) STORED;
+
+CREATE INDEX IF NOT EXISTS ts_comment_idx ON comments USING GIN (ts);
+CREATE INDEX IF NOT EXISTS comments_level_id_idx ON comments (level_id, id)
+    WHERE parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS comments_collection_id_idx ON comments (collection_id, id)
+    WHERE parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS comments_parent_id_idx ON comments (parent_id, id);
+
+-- Comments with the name of their author
+-- and their number of direct replies
+CREATE OR REPLACE VIEW comment_data AS
+    SELECT c.*, u.name user_name,
+        (SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id) replies
+    FROM comments c
+    INNER JOIN users u ON c.uid = u.id;
@@ -0,0 +1,4 @@
+DROP VIEW IF EXISTS level_difficulty;
+DROP FUNCTION IF EXISTS community_difficulty(INT, BIGINT, BIGINT, BIGINT, BIGINT, FLOAT8, FLOAT8);
+DROP INDEX IF EXISTS events_level_uid_idx;
+DROP TABLE IF EXISTS difficulty_votes;
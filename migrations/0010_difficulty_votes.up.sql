@@ -0,0 +1,75 @@
+-- Players who finished a level (have a game_finish
+-- event on it) can vote on its difficulty, 1 to 5
+CREATE TABLE IF NOT EXISTS difficulty_votes (
+    level_id INT NOT NULL,
+    uid INT NOT NULL,
+    difficulty SMALLINT NOT NULL CHECK (difficulty BETWEEN 1 AND 5),
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMP,
+    PRIMARY KEY (level_id, uid),
+    FOREIGN KEY (level_id) REFERENCES levels(id) ON DELETE CASCADE,
+    FOREIGN KEY (uid) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS events_level_uid_idx ON events (level_id, uid)
+    WHERE event_type = 'game_finish';
+
+-- Blends the difficulty votes, with the creator's
+-- difficulty counting as 2 votes, with the completion
+-- rate and the level's average time relative to the
+-- average of every level. Signals without data are left
+-- out. Keep in sync with models.CommunityDifficulty.
+CREATE OR REPLACE FUNCTION community_difficulty(
+    creator INT, votes BIGINT, vote_sum BIGINT, starts BIGINT,
+    finishes BIGINT, avg_time FLOAT8, overall_avg_time FLOAT8)
+    RETURNS FLOAT8
+    LANGUAGE PLPGSQL
+    IMMUTABLE
+    AS
+$$
+DECLARE
+    score FLOAT8;
+    weight FLOAT8 := 0.5;
+BEGIN
+    score := 0.5 * (2 * LEAST(GREATEST(creator, 1), 5) + vote_sum) / (2 + votes);
+    IF starts > 0 THEN
+        score := score + 0.3 * (1 + 4 * (1 - LEAST(finishes::float8 / starts, 1)));
+        weight := weight + 0.3;
+    END IF;
+    IF avg_time > 0 AND overall_avg_time > 0 THEN
+        score := score + 0.2 * LEAST(GREATEST(3 * avg_time / overall_avg_time, 1), 5);
+        weight := weight + 0.2;
+    END IF;
+    RETURN score / weight;
+END
+$$;
+
+CREATE OR REPLACE VIEW level_difficulty AS
+    WITH plays AS (
+        SELECT level_id,
+            COUNT(*) FILTER (WHERE event_type = 'game_start') starts,
+            COUNT(*) FILTER (WHERE event_type = 'game_finish') finishes,
+            COALESCE(AVG(time) FILTER (WHERE event_type = 'game_finish'), 0)::float8 avg_time
+        FROM events
+        WHERE level_id IS NOT NULL
+        GROUP BY level_id
+    ), votes AS (
+        SELECT level_id, COUNT(*) votes, SUM(difficulty) vote_sum
+        FROM difficulty_votes
+        GROUP BY level_id
+    ), overall AS (
+        SELECT COALESCE(AVG(avg_time) FILTER (WHERE avg_time > 0), 0)::float8 avg_time FROM plays
+    )
+    SELECT l.id level_id,
+        COALESCE(v.votes, 0) votes,
+        COALESCE(v.vote_sum::float8 / v.votes, 0) avg_vote,
+        COALESCE(p.starts, 0) starts,
+        COALESCE(p.finishes, 0) finishes,
+        COALESCE(p.avg_time, 0) avg_time,
+        community_difficulty(l.difficulty, COALESCE(v.votes, 0), COALESCE(v.vote_sum, 0),
+            COALESCE(p.starts, 0), COALESCE(p.finishes, 0), COALESCE(p.avg_time, 0),
+            o.avg_time) community_difficulty
+    FROM levels l
+    LEFT JOIN votes v ON v.level_id = l.id
+    LEFT JOIN plays p ON p.level_id = l.id
+    CROSS JOIN overall o;
@@ -0,0 +1,3 @@
+DROP VIEW IF EXISTS tag_counts;
+DROP TABLE IF EXISTS level_tags;
+DROP TABLE IF EXISTS tags;
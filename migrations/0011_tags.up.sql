@@ -0,0 +1,27 @@
+-- Tags are lowercase words joined by dashes (see
+-- models.NormalizeTag). Anyone can tag their levels
+-- with new tags; moderators curate the ones the
+-- app suggests.
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(30) UNIQUE NOT NULL,
+    curated BOOLEAN NOT NULL DEFAULT FALSE,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS level_tags (
+    level_id INT NOT NULL,
+    tag_id INT NOT NULL,
+    PRIMARY KEY (level_id, tag_id),
+    FOREIGN KEY (level_id) REFERENCES levels(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS level_tags_tag_id_idx ON level_tags (tag_id, level_id);
+CREATE INDEX IF NOT EXISTS tags_name_prefix_idx ON tags (name varchar_pattern_ops);
+
+-- Tags with the number of levels using them
+CREATE OR REPLACE VIEW tag_counts AS
+    SELECT g.*, COUNT(lt.level_id) levels FROM tags g
+        LEFT JOIN level_tags lt ON lt.tag_id = g.id
+        GROUP BY g.id;
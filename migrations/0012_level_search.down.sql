@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS levels_created_idx;
+DROP INDEX IF EXISTS levels_theme_idx;
+DROP VIEW IF EXISTS level_search;
@@ -0,0 +1,13 @@
+-- Levels with the stats a level search can filter
+-- and sort by. Unrated levels have a zero score.
+CREATE OR REPLACE VIEW level_search AS
+    SELECT l.*, d.community_difficulty, d.starts plays,
+        COALESCE(r.ratings, 0) ratings,
+        COALESCE(r.avg_stars, 0) avg_stars,
+        COALESCE(r.score, 0) score
+    FROM levels l
+    INNER JOIN level_difficulty d ON d.level_id = l.id
+    LEFT JOIN level_ratings r ON r.level_id = l.id;
+
+CREATE INDEX IF NOT EXISTS levels_theme_idx ON levels (theme);
+CREATE INDEX IF NOT EXISTS levels_created_idx ON levels (created);
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS collection_name_trgm_idx;
+DROP INDEX IF EXISTS users_name_trgm_idx;
+DROP INDEX IF EXISTS levels_name_trgm_idx;
+
+DROP FUNCTION IF EXISTS prefix_query(TEXT, TEXT);
+
+CREATE OR REPLACE FUNCTION gin_query(q TEXT)
+    RETURNS tsquery
+    LANGUAGE SQL
+    STABLE
+    AS
+$$
+    SELECT to_tsquery('spanish', REPLACE(q, ' ', ' <2> '));
+$$;
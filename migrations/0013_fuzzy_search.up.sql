@@ -0,0 +1,34 @@
+-- Searches parse user input with websearch_to_tsquery,
+-- which accepts any text: quoted phrases, "or" and -word
+-- exclusions instead of raising syntax errors
+CREATE OR REPLACE FUNCTION gin_query(q TEXT)
+    RETURNS tsquery
+    LANGUAGE SQL
+    STABLE
+    AS
+$$
+    SELECT websearch_to_tsquery('spanish', q);
+$$;
+
+-- Matches the words of ts starting like the words of q, for
+-- autocomplete. Weights (e.g. 'A') restrict the match to the
+-- words with those weights. Punctuation is dropped, so the
+-- words are always valid tsquery terms.
+CREATE OR REPLACE FUNCTION prefix_query(q TEXT, weights TEXT DEFAULT '')
+    RETURNS tsquery
+    LANGUAGE SQL
+    STABLE
+    AS
+$$
+    SELECT COALESCE(to_tsquery('spanish', string_agg(quote_literal(w) || ':*' || weights, ' & ')), ''::tsquery)
+    FROM regexp_split_to_table(lower(q), '[^[:alnum:]]+') w
+    WHERE w <> '';
+$$;
+
+-- Searches that match nothing fall back to the
+-- trigram similarity of names
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS levels_name_trgm_idx ON levels USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_name_trgm_idx ON users USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS collection_name_trgm_idx ON collection USING GIN (name gin_trgm_ops);
@@ -0,0 +1,79 @@
+CREATE OR REPLACE FUNCTION prefix_query(q TEXT, weights TEXT DEFAULT '')
+    RETURNS tsquery
+    LANGUAGE SQL
+    STABLE
+    AS
+$$
+    SELECT COALESCE(to_tsquery('spanish', string_agg(quote_literal(w) || ':*' || weights, ' & ')), ''::tsquery)
+    FROM regexp_split_to_table(lower(q), '[^[:alnum:]]+') w
+    WHERE w <> '';
+$$;
+
+DROP FUNCTION IF EXISTS gin_query(TEXT, TEXT);
+CREATE OR REPLACE FUNCTION gin_query(q TEXT)
+    RETURNS tsquery
+    LANGUAGE SQL
+    STABLE
+    AS
+$$
+    SELECT websearch_to_tsquery('spanish', q);
+$$;
+
+DROP VIEW IF EXISTS level_search;
+DROP VIEW IF EXISTS top_rated_levels;
+DROP VIEW IF EXISTS trending_collections;
+DROP VIEW IF EXISTS trending_levels;
+
+ALTER TABLE levels DROP COLUMN IF EXISTS ts;
+ALTER TABLE levels DROP COLUMN IF EXISTS language;
+ALTER TABLE levels ADD COLUMN ts tsvector
+    GENERATED ALWAYS AS (setweight(to_tsvector('spanish', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('spanish', coalesce(description, '')), 'B')) STORED;
+CREATE INDEX IF NOT EXISTS ts_level_idx ON levels USING GIN (ts);
+
+ALTER TABLE collection DROP COLUMN IF EXISTS ts;
+ALTER TABLE collection DROP COLUMN IF EXISTS language;
+ALTER TABLE collection ADD COLUMN ts tsvector
+    GENERATED ALWAYS AS (setweight(to_tsvector('spanish', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('spanish', coalesce(description, '')), 'B')) STORED;
+CREATE INDEX IF NOT EXISTS ts_collection_idx ON collection USING GIN (ts);
+
+ALTER TABLE users DROP COLUMN IF EXISTS ts;
+ALTER TABLE users DROP COLUMN IF EXISTS language;
+ALTER TABLE users ADD COLUMN ts tsvector
+    GENERATED ALWAYS AS (to_tsvector('spanish', name)) STORED;
+CREATE INDEX IF NOT EXISTS ts_user_idx ON users USING GIN (ts);
+
+CREATE VIEW trending_levels AS
+    SELECT DISTINCT count(e.id) OVER (
+        PARTITION BY e.level_id
+    ) plays, l.* FROM events e
+    INNER JOIN levels l ON e.level_id = l.id
+    WHERE event_type = 'game_start'
+    ORDER BY plays DESC;
+
+CREATE VIEW trending_collections AS
+    SELECT DISTINCT c.*,
+        sum(tl.plays) OVER (
+            PARTITION BY c.id
+        ) collection_plays FROM trending_levels tl
+        RIGHT JOIN collection_levels cl ON tl.id = cl.level_id
+        INNER JOIN collection c ON cl.collection_id = c.id
+        ORDER BY collection_plays DESC;
+
+CREATE VIEW top_rated_levels AS
+    SELECT r.likes, r.dislikes, r.ratings, r.avg_stars, r.score, l.* FROM level_ratings r
+        INNER JOIN levels l ON r.level_id = l.id
+        WHERE r.ratings > 0
+        ORDER BY r.score DESC, l.id DESC;
+
+CREATE VIEW level_search AS
+    SELECT l.*, d.community_difficulty, d.starts plays,
+        COALESCE(r.ratings, 0) ratings,
+        COALESCE(r.avg_stars, 0) avg_stars,
+        COALESCE(r.score, 0) score
+    FROM levels l
+    INNER JOIN level_difficulty d ON d.level_id = l.id
+    LEFT JOIN level_ratings r ON r.level_id = l.id;
+
+DROP FUNCTION IF EXISTS ts_config(TEXT);
@@ -0,0 +1,108 @@
+-- Levels, collections and users are searched in the language
+-- they are written in, one of es, en or pt. The ts columns
+-- stem words with the text search config of the row's
+-- language, and add the unstemmed words (weight D) so
+-- searches in other languages still find exact words.
+CREATE OR REPLACE FUNCTION ts_config(lang TEXT)
+    RETURNS regconfig
+    LANGUAGE SQL
+    IMMUTABLE
+    AS
+$$
+    SELECT CASE lang
+        WHEN 'en' THEN 'english'::regconfig
+        WHEN 'pt' THEN 'portuguese'::regconfig
+        ELSE 'spanish'::regconfig
+    END;
+$$;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS language VARCHAR(2) NOT NULL DEFAULT 'es'
+    CHECK (language IN ('es', 'en', 'pt'));
+ALTER TABLE collection ADD COLUMN IF NOT EXISTS language VARCHAR(2) NOT NULL DEFAULT 'es'
+    CHECK (language IN ('es', 'en', 'pt'));
+ALTER TABLE levels ADD COLUMN IF NOT EXISTS language VARCHAR(2) NOT NULL DEFAULT 'es'
+    CHECK (language IN ('es', 'en', 'pt'));
+
+-- Generated columns can't be altered, and the views
+-- selecting l.* and c.* depend on the old ones
+DROP VIEW IF EXISTS level_search;
+DROP VIEW IF EXISTS top_rated_levels;
+DROP VIEW IF EXISTS trending_collections;
+DROP VIEW IF EXISTS trending_levels;
+
+ALTER TABLE users DROP COLUMN IF EXISTS ts;
+ALTER TABLE users ADD COLUMN ts tsvector
+    GENERATED ALWAYS AS (to_tsvector(ts_config(language), name) ||
+    setweight(to_tsvector('simple', name), 'D')) STORED;
+CREATE INDEX IF NOT EXISTS ts_user_idx ON users USING GIN (ts);
+
+ALTER TABLE collection DROP COLUMN IF EXISTS ts;
+ALTER TABLE collection ADD COLUMN ts tsvector
+    GENERATED ALWAYS AS (setweight(to_tsvector(ts_config(language), coalesce(name, '')), 'A') ||
+    setweight(to_tsvector(ts_config(language), coalesce(description, '')), 'B') ||
+    setweight(to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(description, '')), 'D')) STORED;
+CREATE INDEX IF NOT EXISTS ts_collection_idx ON collection USING GIN (ts);
+
+ALTER TABLE levels DROP COLUMN IF EXISTS ts;
+ALTER TABLE levels ADD COLUMN ts tsvector
+    GENERATED ALWAYS AS (setweight(to_tsvector(ts_config(language), coalesce(name, '')), 'A') ||
+    setweight(to_tsvector(ts_config(language), coalesce(description, '')), 'B') ||
+    setweight(to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(description, '')), 'D')) STORED;
+CREATE INDEX IF NOT EXISTS ts_level_idx ON levels USING GIN (ts);
+
+CREATE VIEW trending_levels AS
+    SELECT DISTINCT count(e.id) OVER (
+        PARTITION BY e.level_id
+    ) plays, l.* FROM events e
+    INNER JOIN levels l ON e.level_id = l.id
+    WHERE event_type = 'game_start'
+    ORDER BY plays DESC;
+
+CREATE VIEW trending_collections AS
+    SELECT DISTINCT c.*,
+        sum(tl.plays) OVER (
+            PARTITION BY c.id
+        ) collection_plays FROM trending_levels tl
+        RIGHT JOIN collection_levels cl ON tl.id = cl.level_id
+        INNER JOIN collection c ON cl.collection_id = c.id
+        ORDER BY collection_plays DESC;
+
+CREATE VIEW top_rated_levels AS
+    SELECT r.likes, r.dislikes, r.ratings, r.avg_stars, r.score, l.* FROM level_ratings r
+        INNER JOIN levels l ON r.level_id = l.id
+        WHERE r.ratings > 0
+        ORDER BY r.score DESC, l.id DESC;
+
+CREATE VIEW level_search AS
+    SELECT l.*, d.community_difficulty, d.starts plays,
+        COALESCE(r.ratings, 0) ratings,
+        COALESCE(r.avg_stars, 0) avg_stars,
+        COALESCE(r.score, 0) score
+    FROM levels l
+    INNER JOIN level_difficulty d ON d.level_id = l.id
+    LEFT JOIN level_ratings r ON r.level_id = l.id;
+
+-- Queries are parsed in the language the client picks,
+-- and also unstemmed to match words of any language
+DROP FUNCTION IF EXISTS gin_query(TEXT);
+CREATE OR REPLACE FUNCTION gin_query(q TEXT, lang TEXT DEFAULT 'es')
+    RETURNS tsquery
+    LANGUAGE SQL
+    STABLE
+    AS
+$$
+    SELECT websearch_to_tsquery(ts_config(lang), q) || websearch_to_tsquery('simple', q);
+$$;
+
+-- Prefixes aren't stemmed, as stemming a partial word
+-- can cut it short, and aren't tied to a language
+CREATE OR REPLACE FUNCTION prefix_query(q TEXT, weights TEXT DEFAULT '')
+    RETURNS tsquery
+    LANGUAGE SQL
+    STABLE
+    AS
+$$
+    SELECT COALESCE(to_tsquery('simple', string_agg(quote_literal(w) || ':*' || weights, ' & ')), ''::tsquery)
+    FROM regexp_split_to_table(lower(q), '[^[:alnum:]]+') w
+    WHERE w <> '';
+$$;
@@ -0,0 +1,52 @@
+CREATE OR REPLACE FUNCTION query_gin(_rowtype anyelement, q TEXT)
+    RETURNS SETOF anyelement
+    LANGUAGE PLPGSQL
+    AS
+$$
+DECLARE
+    query_ts tsquery;
+BEGIN
+    query_ts := gin_query(q);
+    RETURN QUERY EXECUTE format('SELECT * FROM %s WHERE ts @@ %L ORDER BY ts_rank(ts, %L) DESC', pg_typeof(_rowtype), query_ts, query_ts);
+END
+$$;
+
+DROP VIEW IF EXISTS level_search;
+DROP VIEW IF EXISTS top_rated_levels;
+DROP VIEW IF EXISTS trending_collections;
+DROP VIEW IF EXISTS trending_levels;
+
+ALTER TABLE levels DROP COLUMN IF EXISTS share_token;
+ALTER TABLE levels DROP COLUMN IF EXISTS visibility;
+
+CREATE VIEW trending_levels AS
+    SELECT DISTINCT count(e.id) OVER (
+        PARTITION BY e.level_id
+    ) plays, l.* FROM events e
+    INNER JOIN levels l ON e.level_id = l.id
+    WHERE event_type = 'game_start'
+    ORDER BY plays DESC;
+
+CREATE VIEW trending_collections AS
+    SELECT DISTINCT c.*,
+        sum(tl.plays) OVER (
+            PARTITION BY c.id
+        ) collection_plays FROM trending_levels tl
+        RIGHT JOIN collection_levels cl ON tl.id = cl.level_id
+        INNER JOIN collection c ON cl.collection_id = c.id
+        ORDER BY collection_plays DESC;
+
+CREATE VIEW top_rated_levels AS
+    SELECT r.likes, r.dislikes, r.ratings, r.avg_stars, r.score, l.* FROM level_ratings r
+        INNER JOIN levels l ON r.level_id = l.id
+        WHERE r.ratings > 0
+        ORDER BY r.score DESC, l.id DESC;
+
+CREATE VIEW level_search AS
+    SELECT l.*, d.community_difficulty, d.starts plays,
+        COALESCE(r.ratings, 0) ratings,
+        COALESCE(r.avg_stars, 0) avg_stars,
+        COALESCE(r.score, 0) score
+    FROM levels l
+    INNER JOIN level_difficulty d ON d.level_id = l.id
+    LEFT JOIN level_ratings r ON r.level_id = l.id;
@@ -0,0 +1,64 @@
+-- Public levels are listed and searchable. Unlisted levels
+-- can be read by anyone with their share link, and private
+-- levels only by their creator (and moderators).
+ALTER TABLE levels ADD COLUMN IF NOT EXISTS visibility VARCHAR(10) NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'unlisted', 'private'));
+-- Only unlisted levels have one
+ALTER TABLE levels ADD COLUMN IF NOT EXISTS share_token TEXT UNIQUE;
+
+-- Views on levels only list public ones, and are
+-- recreated so l.* includes the new columns
+DROP VIEW IF EXISTS level_search;
+DROP VIEW IF EXISTS top_rated_levels;
+DROP VIEW IF EXISTS trending_collections;
+DROP VIEW IF EXISTS trending_levels;
+
+CREATE VIEW trending_levels AS
+    SELECT DISTINCT count(e.id) OVER (
+        PARTITION BY e.level_id
+    ) plays, l.* FROM events e
+    INNER JOIN levels l ON e.level_id = l.id
+    WHERE event_type = 'game_start' AND l.visibility = 'public'
+    ORDER BY plays DESC;
+
+CREATE VIEW trending_collections AS
+    SELECT DISTINCT c.*,
+        sum(tl.plays) OVER (
+            PARTITION BY c.id
+        ) collection_plays FROM trending_levels tl
+        RIGHT JOIN collection_levels cl ON tl.id = cl.level_id
+        INNER JOIN collection c ON cl.collection_id = c.id
+        ORDER BY collection_plays DESC;
+
+CREATE VIEW top_rated_levels AS
+    SELECT r.likes, r.dislikes, r.ratings, r.avg_stars, r.score, l.* FROM level_ratings r
+        INNER JOIN levels l ON r.level_id = l.id
+        WHERE r.ratings > 0 AND l.visibility = 'public'
+        ORDER BY r.score DESC, l.id DESC;
+
+CREATE VIEW level_search AS
+    SELECT l.*, d.community_difficulty, d.starts plays,
+        COALESCE(r.ratings, 0) ratings,
+        COALESCE(r.avg_stars, 0) avg_stars,
+        COALESCE(r.score, 0) score
+    FROM levels l
+    INNER JOIN level_difficulty d ON d.level_id = l.id
+    LEFT JOIN level_ratings r ON r.level_id = l.id
+    WHERE l.visibility = 'public';
+
+CREATE OR REPLACE FUNCTION query_gin(_rowtype anyelement, q TEXT)
+    RETURNS SETOF anyelement
+    LANGUAGE PLPGSQL
+    AS
+$$
+DECLARE
+    query_ts tsquery;
+    visible TEXT := '';
+BEGIN
+    query_ts := gin_query(q);
+    IF pg_typeof(_rowtype) = 'levels'::regtype THEN
+        visible := ' AND visibility = ''public''';
+    END IF;
+    RETURN QUERY EXECUTE format('SELECT * FROM %s WHERE ts @@ %L%s ORDER BY ts_rank(ts, %L) DESC', pg_typeof(_rowtype), query_ts, visible, query_ts);
+END
+$$;
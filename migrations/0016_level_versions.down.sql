@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS level_version_update_trigger ON level_versions;
+DROP FUNCTION IF EXISTS on_level_version_update();
+DROP TABLE IF EXISTS level_versions;
@@ -0,0 +1,38 @@
+-- Every course published for a level, numbered from 1.
+-- Versions are never changed, rolling back publishes a
+-- copy of the old version as a new one.
+CREATE TABLE IF NOT EXISTS level_versions (
+    id SERIAL PRIMARY KEY,
+    level_id INT NOT NULL,
+    version INT NOT NULL,
+    map_data jsonb NOT NULL,
+    restored_from INT,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (level_id, version),
+    FOREIGN KEY (level_id) REFERENCES levels(id) ON DELETE CASCADE
+);
+
+CREATE OR REPLACE FUNCTION on_level_version_update()
+    RETURNS TRIGGER
+    LANGUAGE PLPGSQL
+    AS
+$$
+BEGIN
+    RAISE EXCEPTION 'level versions can''t be modified';
+END
+$$;
+
+DROP TRIGGER IF EXISTS level_version_update_trigger ON level_versions;
+
+CREATE TRIGGER level_version_update_trigger
+    BEFORE UPDATE
+    ON level_versions
+    FOR EACH ROW
+    EXECUTE PROCEDURE on_level_version_update();
+
+-- The current course of existing levels is their first version
+INSERT INTO level_versions (level_id, version, map_data, created)
+    SELECT l.id, 1, c.map_data, COALESCE(l.updated, l.created)
+    FROM levels l
+    INNER JOIN course_data c ON c.level_id = l.id
+    ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS levels_source_level_id_idx;
+ALTER TABLE levels DROP COLUMN IF EXISTS source_version, DROP COLUMN IF EXISTS source_level_id;
+ALTER TABLE drafts DROP COLUMN IF EXISTS source_version, DROP COLUMN IF EXISTS source_level_id;
@@ -0,0 +1,13 @@
+-- Forks of another user's level remember the level and
+-- the version they were copied from, and keep them once
+-- they are published. The links are cleared if the
+-- source level is deleted.
+ALTER TABLE drafts
+    ADD COLUMN IF NOT EXISTS source_level_id INT REFERENCES levels(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS source_version INT;
+ALTER TABLE levels
+    ADD COLUMN IF NOT EXISTS source_level_id INT REFERENCES levels(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS source_version INT;
+
+CREATE INDEX IF NOT EXISTS levels_source_level_id_idx ON levels (source_level_id, id)
+    WHERE source_level_id IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE levels DROP COLUMN IF EXISTS revision;
+ALTER TABLE drafts DROP COLUMN IF EXISTS revision;
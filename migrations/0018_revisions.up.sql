@@ -0,0 +1,6 @@
+-- Incremented by every update of a draft or level, so
+-- clients can make updates conditional on the revision
+-- they read (If-Match) instead of overwriting changes
+-- made elsewhere
+ALTER TABLE drafts ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 1;
+ALTER TABLE levels ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 1;
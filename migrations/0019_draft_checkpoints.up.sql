@@ -0,0 +1,15 @@
+-- Snapshots of the course of a draft, so creators can go
+-- back to an earlier state. Saves record the course they
+-- replace as an unnamed checkpoint, only the newest ones
+-- are kept. Named checkpoints are kept until deleted.
+CREATE TABLE IF NOT EXISTS draft_checkpoints (
+    id SERIAL PRIMARY KEY,
+    draft_id INT NOT NULL,
+    revision INT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    course_data jsonb NOT NULL,
+    created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (draft_id) REFERENCES drafts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS draft_checkpoints_draft_id_idx ON draft_checkpoints (draft_id, id DESC);
@@ -0,0 +1 @@
+ALTER TABLE events DROP CONSTRAINT events_level_id_fkey;
@@ -0,0 +1,9 @@
+-- Deleting a level also deletes its events, so stats,
+-- difficulty and trending don't read levels that are
+-- gone. Events of levels deleted before this have to
+-- go before the foreign key can be added back.
+DELETE FROM events WHERE level_id IS NOT NULL
+    AND level_id NOT IN (SELECT id FROM levels);
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_level_id_fkey,
+    ADD CONSTRAINT events_level_id_fkey FOREIGN KEY (level_id)
+    REFERENCES levels(id) ON DELETE CASCADE;
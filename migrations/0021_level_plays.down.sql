@@ -0,0 +1,33 @@
+CREATE OR REPLACE VIEW level_difficulty AS
+    WITH plays AS (
+        SELECT level_id,
+            COUNT(*) FILTER (WHERE event_type = 'game_start') starts,
+            COUNT(*) FILTER (WHERE event_type = 'game_finish') finishes,
+            COALESCE(AVG(time) FILTER (WHERE event_type = 'game_finish'), 0)::float8 avg_time
+        FROM events
+        WHERE level_id IS NOT NULL
+        GROUP BY level_id
+    ), votes AS (
+        SELECT level_id, COUNT(*) votes, SUM(difficulty) vote_sum
+        FROM difficulty_votes
+        GROUP BY level_id
+    ), overall AS (
+        SELECT COALESCE(AVG(avg_time) FILTER (WHERE avg_time > 0), 0)::float8 avg_time FROM plays
+    )
+    SELECT l.id level_id,
+        COALESCE(v.votes, 0) votes,
+        COALESCE(v.vote_sum::float8 / v.votes, 0) avg_vote,
+        COALESCE(p.starts, 0) starts,
+        COALESCE(p.finishes, 0) finishes,
+        COALESCE(p.avg_time, 0) avg_time,
+        community_difficulty(l.difficulty, COALESCE(v.votes, 0), COALESCE(v.vote_sum, 0),
+            COALESCE(p.starts, 0), COALESCE(p.finishes, 0), COALESCE(p.avg_time, 0),
+            o.avg_time) community_difficulty
+    FROM levels l
+    LEFT JOIN votes v ON v.level_id = l.id
+    LEFT JOIN plays p ON p.level_id = l.id
+    CROSS JOIN overall o;
+
+DROP TRIGGER IF EXISTS play_event_trigger ON events;
+DROP FUNCTION IF EXISTS on_play_event;
+DROP TABLE IF EXISTS level_plays;
@@ -0,0 +1,93 @@
+-- Play counts of each level, kept by a trigger on
+-- events so level_difficulty doesn't aggregate the
+-- whole event log on every read
+CREATE TABLE IF NOT EXISTS level_plays (
+    level_id INT PRIMARY KEY,
+    starts BIGINT NOT NULL DEFAULT 0,
+    finishes BIGINT NOT NULL DEFAULT 0,
+    -- Finishes with a time, AVG skips NULL times
+    timed_finishes BIGINT NOT NULL DEFAULT 0,
+    time_sum BIGINT NOT NULL DEFAULT 0,
+    FOREIGN KEY (level_id) REFERENCES levels(id) ON DELETE CASCADE
+);
+
+INSERT INTO level_plays (level_id, starts, finishes, timed_finishes, time_sum)
+    SELECT level_id,
+        COUNT(*) FILTER (WHERE event_type = 'game_start'),
+        COUNT(*) FILTER (WHERE event_type = 'game_finish'),
+        COUNT(time) FILTER (WHERE event_type = 'game_finish'),
+        COALESCE(SUM(time) FILTER (WHERE event_type = 'game_finish'), 0)
+    FROM events
+    WHERE level_id IS NOT NULL
+    GROUP BY level_id
+    ON CONFLICT DO NOTHING;
+
+CREATE OR REPLACE FUNCTION on_play_event()
+    RETURNS TRIGGER
+    LANGUAGE PLPGSQL
+    AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.level_id IS NULL OR NEW.event_type NOT IN ('game_start', 'game_finish') THEN
+            RETURN NEW;
+        END IF;
+        INSERT INTO level_plays AS p (level_id, starts, finishes, timed_finishes, time_sum)
+            VALUES (NEW.level_id,
+                (NEW.event_type = 'game_start')::int,
+                (NEW.event_type = 'game_finish')::int,
+                (NEW.event_type = 'game_finish' AND NEW.time IS NOT NULL)::int,
+                CASE WHEN NEW.event_type = 'game_finish' THEN COALESCE(NEW.time, 0) ELSE 0 END)
+            ON CONFLICT (level_id) DO UPDATE SET
+                starts = p.starts + EXCLUDED.starts,
+                finishes = p.finishes + EXCLUDED.finishes,
+                timed_finishes = p.timed_finishes + EXCLUDED.timed_finishes,
+                time_sum = p.time_sum + EXCLUDED.time_sum;
+        RETURN NEW;
+    END IF;
+    IF OLD.level_id IS NULL OR OLD.event_type NOT IN ('game_start', 'game_finish') THEN
+        RETURN OLD;
+    END IF;
+    UPDATE level_plays SET
+        starts = starts - (OLD.event_type = 'game_start')::int,
+        finishes = finishes - (OLD.event_type = 'game_finish')::int,
+        timed_finishes = timed_finishes - (OLD.event_type = 'game_finish' AND OLD.time IS NOT NULL)::int,
+        time_sum = time_sum - CASE WHEN OLD.event_type = 'game_finish' THEN COALESCE(OLD.time, 0) ELSE 0 END
+    WHERE level_id = OLD.level_id;
+    RETURN OLD;
+END
+$$;
+
+DROP TRIGGER IF EXISTS play_event_trigger ON events;
+
+CREATE TRIGGER play_event_trigger
+    AFTER INSERT OR DELETE
+    ON events
+    FOR EACH ROW
+    EXECUTE PROCEDURE on_play_event();
+
+CREATE OR REPLACE VIEW level_difficulty AS
+    WITH plays AS (
+        SELECT level_id, starts, finishes,
+            COALESCE(time_sum::float8 / NULLIF(timed_finishes, 0), 0)::float8 avg_time
+        FROM level_plays
+    ), votes AS (
+        SELECT level_id, COUNT(*) votes, SUM(difficulty) vote_sum
+        FROM difficulty_votes
+        GROUP BY level_id
+    ), overall AS (
+        SELECT COALESCE(AVG(avg_time) FILTER (WHERE avg_time > 0), 0)::float8 avg_time FROM plays
+    )
+    SELECT l.id level_id,
+        COALESCE(v.votes, 0) votes,
+        COALESCE(v.vote_sum::float8 / v.votes, 0) avg_vote,
+        COALESCE(p.starts, 0) starts,
+        COALESCE(p.finishes, 0) finishes,
+        COALESCE(p.avg_time, 0) avg_time,
+        community_difficulty(l.difficulty, COALESCE(v.votes, 0), COALESCE(v.vote_sum, 0),
+            COALESCE(p.starts, 0), COALESCE(p.finishes, 0), COALESCE(p.avg_time, 0),
+            o.avg_time) community_difficulty
+    FROM levels l
+    LEFT JOIN votes v ON v.level_id = l.id
+    LEFT JOIN plays p ON p.level_id = l.id
+    CROSS JOIN overall o;
@@ -0,0 +1,3 @@
+ALTER TABLE collection_levels DROP CONSTRAINT collection_levels_collection_id_fkey,
+    ADD CONSTRAINT collection_levels_collection_id_fkey FOREIGN KEY (collection_id)
+    REFERENCES collection(id);
@@ -0,0 +1,5 @@
+-- Deleting a collection removes its level links,
+-- like deleting a level does
+ALTER TABLE collection_levels DROP CONSTRAINT IF EXISTS collection_levels_collection_id_fkey,
+    ADD CONSTRAINT collection_levels_collection_id_fkey FOREIGN KEY (collection_id)
+    REFERENCES collection(id) ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+CREATE OR REPLACE VIEW tag_counts AS
+    SELECT g.*, COUNT(lt.level_id) levels FROM tags g
+        LEFT JOIN level_tags lt ON lt.tag_id = g.id
+        GROUP BY g.id;
@@ -0,0 +1,7 @@
+-- Only public levels count, so the counts don't leak
+-- hidden levels and match the levels listed by tag
+CREATE OR REPLACE VIEW tag_counts AS
+    SELECT g.*, COUNT(l.id) levels FROM tags g
+        LEFT JOIN level_tags lt ON lt.tag_id = g.id
+        LEFT JOIN levels l ON l.id = lt.level_id AND l.visibility = 'public'
+        GROUP BY g.id;
@@ -0,0 +1,12 @@
+// Numbered SQL migrations, applied in order by
+// db.Client.MigrateUp. Each version has an
+// NNNN_name.up.sql file and a matching
+// NNNN_name.down.sql file that reverts it.
+// Applied migrations can't be edited, schema
+// changes always go in a new migration.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// A snapshot of the course of a draft. Saves record the
+// course they replace as an unnamed checkpoint, see
+// autosave, and users can name the current course to
+// keep it.
+type Checkpoint struct {
+	Id      int `db:"id" json:"id"`
+	DraftId int `db:"draft_id" json:"draftId"`
+	// Revision of the draft with this course
+	Revision   int                    `db:"revision" json:"revision"`
+	Name       string                 `db:"name" json:"name,omitempty"`
+	CourseData map[string]interface{} `db:"-" json:"courseData,omitempty"`
+	Created    time.Time              `db:"created" json:"created"`
+}
+
+const (
+	// Unnamed checkpoints kept per draft, older
+	// ones are deleted
+	maxAutosaves = 50
+	// Saves this soon after the last unnamed
+	// checkpoint don't record another one
+	autosaveWindow      = 2 * time.Minute
+	maxNamedCheckpoints = 20
+)
+
+var (
+	ErrCheckpointNotFound = errors.New("checkpoint not found")
+	ErrTooManyCheckpoints = errors.New("drafts can have up to 20 named checkpoints")
+)
+
+// Records the course of d, which a save is about to
+// replace, as an unnamed checkpoint. Unless force is set,
+// saves within autosaveWindow of the last unnamed
+// checkpoint record nothing, so a burst of saves only
+// keeps the course from before it.
+func autosave(s Store, d Draft, force bool) error {
+	last, err := s.Checkpoints().Latest(d.Id)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		if reflect.DeepEqual(last.CourseData, d.CourseData) {
+			return nil
+		}
+		if !force && last.Name == "" && time.Since(last.Created) < autosaveWindow {
+			return nil
+		}
+	}
+	c := Checkpoint{DraftId: d.Id, Revision: d.Revision, CourseData: d.CourseData}
+	if _, err := s.Checkpoints().Create(&c); err != nil {
+		return err
+	}
+	return s.Checkpoints().Prune(d.Id, maxAutosaves)
+}
+
+func getCheckpoint(s Store, draftId, id int) (Checkpoint, error) {
+	res, err := s.Checkpoints().Get(draftId, id)
+	if err == sql.ErrNoRows {
+		return Checkpoint{}, ErrCheckpointNotFound
+	}
+	return res, err
+}
+
+// Returns a page of the checkpoints of the draft, newest
+// first, without their course data
+func GetDraftCheckpoints(draftId int, p Page) ([]Checkpoint, string, error) {
+	return store.Checkpoints().List(draftId, p)
+}
+
+// Returns the checkpoint of the draft with its course data
+func GetDraftCheckpoint(draftId, id int) (Checkpoint, error) {
+	return getCheckpoint(store, draftId, id)
+}
+
+// Saves the current course of the draft as a named
+// checkpoint and returns it
+func CreateCheckpoint(draftId int, name string) (Checkpoint, error) {
+	var res Checkpoint
+	err := store.Tx(func(s Store) error {
+		named, err := s.Checkpoints().CountNamed(draftId)
+		if err != nil {
+			return err
+		}
+		if named >= maxNamedCheckpoints {
+			return ErrTooManyCheckpoints
+		}
+		d, err := s.Drafts().Get(draftId)
+		if err != nil {
+			return err
+		}
+		res = Checkpoint{DraftId: draftId, Revision: d.Revision, Name: name, CourseData: d.CourseData}
+		res.Id, err = s.Checkpoints().Create(&res)
+		return err
+	})
+	return res, err
+}
+
+// Replaces the course of the draft owned by uid with the
+// checkpoint's and returns the draft's new revision. The
+// course it replaces is always recorded, so restores can
+// be undone (redo) by restoring that checkpoint.
+func RestoreCheckpoint(draftId, uid, id int) (int, error) {
+	var res int
+	err := store.Tx(func(s Store) error {
+		c, err := getCheckpoint(s, draftId, id)
+		if err != nil {
+			return err
+		}
+		cur, err := s.Drafts().Get(draftId)
+		if err != nil {
+			return err
+		}
+		if err := autosave(s, cur, true); err != nil {
+			return err
+		}
+		d := Draft{Id: draftId, Uid: uid, CourseData: c.CourseData}
+		if err := s.Drafts().Update(&d); err != nil {
+			return err
+		}
+		res = d.Revision
+		return nil
+	})
+	return res, err
+}
+
+func DeleteCheckpoint(draftId, id int) error {
+	return store.Tx(func(s Store) error {
+		if _, err := getCheckpoint(s, draftId, id); err != nil {
+			return err
+		}
+		return s.Checkpoints().Delete(draftId, id)
+	})
+}
@@ -0,0 +1,34 @@
+package models
+
+import "github.com/golang-jwt/jwt"
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
+}
+
+// Access token claims
+type Claims struct {
+	Role string `json:"role"`
+	jwt.StandardClaims
+}
+
+func (c *Claims) HasRole(roles ...string) bool {
+	for _, r := range roles {
+		if c.Role == r {
+			return true
+		}
+	}
+	return false
+}
+
+// Moderators and admins can edit and delete
+// levels and collections of any user
+func (c *Claims) CanModerate() bool {
+	return c.HasRole(RoleModerator, RoleAdmin)
+}
@@ -3,10 +3,7 @@ package models
 import (
 	"database/sql"
 	"errors"
-	"fmt"
 	"time"
-
-	"github.com/sofferjacob/maker_api/db"
 )
 
 type Collection struct {
@@ -17,6 +14,8 @@ type Collection struct {
 	Created     time.Time    `db:"created" json:"created"`
 	Updated     sql.NullTime `db:"updated" json:"updated"`
 	Ts          string       `db:"ts"`
+	// Defaults to the language of the creator
+	Language string `db:"language" json:"language" binding:"omitempty,oneof=es en pt"`
 }
 
 type CollectionData struct {
@@ -29,81 +28,73 @@ func (c *Collection) Create() (int, error) {
 		return 0, errors.New("missing required fields (uid, name)")
 	}
 	var id int
-	query := "INSERT INTO collection (uid, name, description) VALUES ($1, $2, $3) RETURNING id;"
-	err := db.Client.Client.Get(&id, query, c.Uid, c.Name, c.Description)
+	err := store.Tx(func(s Store) error {
+		var err error
+		if c.Language, err = contentLanguage(s, c.Uid, c.Language); err != nil {
+			return err
+		}
+		id, err = s.Collections().Create(c)
+		return err
+	})
 	return id, err
 }
 
 func GetCollection(id int) (CollectionData, error) {
-	query := "SELECT c.*, u.name user_name FROM collection c INNER JOIN users u ON uid = u.id WHERE c.id = $1;"
-	res := CollectionData{}
-	err := db.Client.Client.Get(&res, query, id)
-	return res, err
+	return store.Collections().Get(id)
 }
 
-func GetUserCollections(uid int) ([]CollectionData, error) {
-	query := "SELECT c.*, u.name user_name FROM collection c INNER JOIN users u ON uid = u.id WHERE c.uid = $1;"
-	res := []CollectionData{}
-	err := db.Client.Client.Select(&res, query, uid)
-	return res, err
+// Returns a page of the user's collections, newest first
+func GetUserCollections(uid int, p Page) ([]CollectionData, string, error) {
+	return store.Collections().GetByUser(uid, p)
 }
 
 func (c *Collection) Delete() error {
 	if c.Id == 0 || c.Uid == 0 {
 		return errors.New("missing required fields id and uid")
 	}
-	query := "DELETE FROM collection WHERE id = $1 AND uid = $2;"
-	_, err := db.Client.Client.Exec(query, c.Id, c.Uid)
-	return err
+	return store.Collections().Delete(c.Id, c.Uid)
 }
 
 func (c *Collection) Update() error {
 	if c.Id == 0 || c.Uid == 0 {
 		return errors.New("missing required fields Id or Uid")
 	}
-	if c.Name == "" && c.Description == "" {
+	if c.Name == "" && c.Description == "" && c.Language == "" {
 		return nil
 	}
-	i := 1
-	args := []interface{}{}
-	addComma := false
-	query := "UPDATE collection SET "
-	if c.Name != "" {
-		query += fmt.Sprintf("name = $%v", i)
-		i++
-		addComma = true
-		args = append(args, c.Name)
+	if c.Language != "" && !ValidLanguage(c.Language) {
+		return ErrInvalidLanguage
 	}
-	if c.Description != "" {
-		if addComma {
-			query += ","
-		}
-		query += fmt.Sprintf(" description = $%v", i)
-		i++
-		args = append(args, c.Description)
-	}
-	query += fmt.Sprintf(" WHERE id=$%v AND uid=$%v", i, i+1)
-	args = append(args, c.Id, c.Uid)
-	_, err := db.Client.Client.Exec(query, args...)
-	return err
+	return store.Collections().Update(c)
 }
 
-func QueryCollectionsFTS(query string) ([]Collection, error) {
-	res := []Collection{}
-	err := db.Client.Client.Select(&res, "SELECT * FROM query_gin(null::collection, $1);", query)
-	return res, err
+// Searches collections in the language lang,
+// DefaultLanguage if empty
+func QueryCollectionsFTS(query, lang string, p Page) ([]Collection, string, error) {
+	lang, err := queryLanguage(lang)
+	if err != nil {
+		return nil, "", err
+	}
+	return store.Collections().Query(query, lang, p)
 }
 
 func IsOwnCollection(collectionId, uid int) (bool, error) {
-	query := "SELECT uid FROM collection WHERE id = $1;"
-	var cuid int
-	err := db.Client.Client.Get(&cuid, query, collectionId)
+	cuid, err := store.Collections().Owner(collectionId)
 	return cuid == uid, err
 }
 
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// Returns the uid of the collection's creator,
+// or ErrCollectionNotFound
+func GetCollectionOwner(collectionId int) (int, error) {
+	uid, err := store.Collections().Owner(collectionId)
+	if err == sql.ErrNoRows {
+		return 0, ErrCollectionNotFound
+	}
+	return uid, err
+}
+
 func TrendingCollections() ([]Collection, error) {
-	query := "SELECT id, name, description, uid, created, updated FROM trending_collections;"
-	res := []Collection{}
-	err := db.Client.Client.Select(&res, query)
-	return res, err
+	return store.Collections().Trending()
 }
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrCommentTarget = errors.New("a comment needs either a levelId or a collectionId")
+
+// A comment on a level or a collection. Exactly one
+// of LevelId and CollectionId is set. Replies have
+// the target of their parent.
+type Comment struct {
+	Id           int          `db:"id" json:"id"`
+	Uid          int          `db:"uid" json:"uid"`
+	LevelId      *int         `db:"level_id" json:"levelId"`
+	CollectionId *int         `db:"collection_id" json:"collectionId"`
+	ParentId     *int         `db:"parent_id" json:"parentId"`
+	Body         string       `db:"body" json:"body"`
+	Created      time.Time    `db:"created" json:"created"`
+	Updated      sql.NullTime `db:"updated" json:"updated"`
+	Ts           string       `db:"ts" json:"-"`
+}
+
+type CommentData struct {
+	Comment
+	UserName string `db:"user_name" json:"userName"`
+	// Number of direct replies
+	Replies int `db:"replies" json:"replies"`
+}
+
+// Creates the comment as v. Replies take the target of
+// the comment they reply to, which must match the target
+// set on c, if any. Returns ErrLevelNotFound if v can't
+// read the level and ErrCollectionNotFound if the
+// collection doesn't exist.
+func (c *Comment) Create(v Viewer) (int, error) {
+	if c.Uid == 0 || c.Body == "" {
+		return 0, errors.New("missing required fields uid, body")
+	}
+	var id int
+	err := store.Tx(func(s Store) error {
+		if c.ParentId != nil {
+			parent, err := s.Comments().Get(*c.ParentId)
+			if err != nil {
+				return err
+			}
+			if (c.LevelId != nil && !sameId(c.LevelId, parent.LevelId)) ||
+				(c.CollectionId != nil && !sameId(c.CollectionId, parent.CollectionId)) {
+				return ErrCommentTarget
+			}
+			c.LevelId = parent.LevelId
+			c.CollectionId = parent.CollectionId
+		}
+		if (c.LevelId == nil) == (c.CollectionId == nil) {
+			return ErrCommentTarget
+		}
+		if c.LevelId != nil {
+			if _, err := readableLevel(s, *c.LevelId, v); err != nil {
+				return err
+			}
+		}
+		if c.CollectionId != nil {
+			_, err := s.Collections().Owner(*c.CollectionId)
+			if err == sql.ErrNoRows {
+				return ErrCollectionNotFound
+			}
+			if err != nil {
+				return err
+			}
+		}
+		var err error
+		id, err = s.Comments().Create(c)
+		return err
+	})
+	return id, err
+}
+
+func sameId(a, b *int) bool {
+	return a != nil && b != nil && *a == *b
+}
+
+// Returns the comment if v can read its target,
+// sql.ErrNoRows otherwise
+func GetComment(id int, v Viewer) (CommentData, error) {
+	c, err := store.Comments().Get(id)
+	if err != nil {
+		return CommentData{}, err
+	}
+	if c.LevelId != nil {
+		if _, err := readableLevel(store, *c.LevelId, v); err == ErrLevelNotFound {
+			return CommentData{}, sql.ErrNoRows
+		} else if err != nil {
+			return CommentData{}, err
+		}
+	}
+	return c, nil
+}
+
+// Updates the body of the comment c.Id owned by c.Uid
+func (c *Comment) Update() error {
+	if c.Id == 0 || c.Uid == 0 || c.Body == "" {
+		return errors.New("missing required fields id, uid, body")
+	}
+	return store.Comments().Update(c)
+}
+
+// Deletes the comment and its replies
+func DeleteComment(id int) error {
+	if id == 0 {
+		return errors.New("missing required field id")
+	}
+	return store.Comments().Delete(id)
+}
+
+// Returns the owner of the level or
+// collection the comment is on
+func (c Comment) TargetOwner() (int, error) {
+	if c.LevelId != nil {
+		return store.Levels().Owner(*c.LevelId)
+	}
+	if c.CollectionId != nil {
+		return store.Collections().Owner(*c.CollectionId)
+	}
+	return 0, ErrCommentTarget
+}
+
+// Returns a page of the level's comments, newest
+// first. Replies are listed with GetCommentReplies.
+func GetLevelComments(levelId int, v Viewer, p Page) ([]CommentData, string, error) {
+	if _, err := readableLevel(store, levelId, v); err != nil {
+		return nil, "", err
+	}
+	return store.Comments().ByLevel(levelId, p)
+}
+
+// Like GetLevelComments, for a collection
+func GetCollectionComments(collectionId int, p Page) ([]CommentData, string, error) {
+	return store.Comments().ByCollection(collectionId, p)
+}
+
+// Returns a page of the comment's replies,
+// in the order they were posted
+func GetCommentReplies(id int, v Viewer, p Page) ([]CommentData, string, error) {
+	if _, err := GetComment(id, v); err != nil {
+		return nil, "", err
+	}
+	return store.Comments().Replies(id, p)
+}
+
+func QueryCommentsFTS(query, lang string, p Page) ([]CommentData, string, error) {
+	lang, err := queryLanguage(lang)
+	if err != nil {
+		return nil, "", err
+	}
+	return store.Comments().Query(query, lang, p)
+}
@@ -2,10 +2,6 @@ package models
 
 import (
 	"errors"
-
-	"encoding/json"
-
-	"github.com/sofferjacob/maker_api/db"
 )
 
 type CourseData struct {
@@ -18,33 +14,19 @@ func (c *CourseData) Create() error {
 	if c.LevelId == 0 {
 		return errors.New("missing required field LevelId")
 	}
-	query := "INSERT INTO course_data (level_id, map_data) VALUES ($1, $2);"
-	cd, err := json.Marshal(c.MapData)
-	if err != nil {
-		return err
-	}
-	_, err = db.Client.Client.Exec(query, c.LevelId, cd)
-	return err
+	return store.Levels().CreateCourseData(c)
 }
 
 func (c *CourseData) Update() error {
 	if c.LevelId == 0 {
 		return errors.New("missing required field LevelId")
 	}
-	cd, err := json.Marshal(c.MapData)
-	if err != nil {
-		return err
-	}
-	query := "UPDATE course_data SET map_data = $1 WHERE level_id = $2"
-	_, err = db.Client.Client.Exec(query, cd, c.LevelId)
-	return err
+	return store.Levels().UpdateCourseData(c)
 }
 
 func (c *CourseData) Delete() error {
 	if c.LevelId == 0 {
 		return errors.New("missing required field LevelId")
 	}
-	query := "DELETE FROM course_data WHERE level_id = $1"
-	_, err := db.Client.Client.Exec(query, c.MapData)
-	return err
+	return store.Levels().DeleteCourseData(c.LevelId)
 }
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A difference between two JSON documents. Op and Path
+// are those of a JSON Patch (RFC 6902) operation, so a
+// diff applied in order turns the old document into the
+// new one. Old is the value replaced or removed. Value
+// is always written, as add and replace need it even
+// when it is null.
+type Change struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Old   interface{} `json:"old,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// Escapes a key as a JSON Pointer (RFC 6901) token
+func pointerToken(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// The structural differences between the decoded JSON
+// documents a and b. Objects are compared key by key and
+// arrays index by index, anything else is replaced whole.
+func DiffJSON(a, b interface{}) []Change {
+	return diffJSON("", a, b, []Change{})
+}
+
+func diffJSON(path string, a, b interface{}, res []Change) []Change {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		if b, ok := b.(map[string]interface{}); ok {
+			return diffObjects(path, a, b, res)
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok {
+			return diffArrays(path, a, b, res)
+		}
+	}
+	if reflect.DeepEqual(a, b) {
+		return res
+	}
+	return append(res, Change{Op: "replace", Path: path, Old: a, Value: b})
+}
+
+func diffObjects(path string, a, b map[string]interface{}, res []Change) []Change {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := path + "/" + pointerToken(k)
+		av, inA := a[k]
+		bv, inB := b[k]
+		switch {
+		case !inA:
+			res = append(res, Change{Op: "add", Path: p, Value: bv})
+		case !inB:
+			res = append(res, Change{Op: "remove", Path: p, Old: av})
+		default:
+			res = diffJSON(p, av, bv, res)
+		}
+	}
+	return res
+}
+
+// Extra elements are removed from the end,
+// so the indexes of the diff stay valid
+func diffArrays(path string, a, b []interface{}, res []Change) []Change {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		res = diffJSON(path+"/"+strconv.Itoa(i), a[i], b[i], res)
+	}
+	for i := len(a); i < len(b); i++ {
+		res = append(res, Change{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: b[i]})
+	}
+	for i := len(a) - 1; i >= len(b); i-- {
+		res = append(res, Change{Op: "remove", Path: path + "/" + strconv.Itoa(i), Old: a[i]})
+	}
+	return res
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	MinDifficulty = 1
+	MaxDifficulty = 5
+)
+
+var (
+	ErrNotFinished       = errors.New("only players who finished the level can vote on its difficulty")
+	ErrInvalidDifficulty = errors.New("difficulty must be between 1 and 5")
+	ErrOwnLevelVote      = errors.New("can't vote on your own level")
+)
+
+// A finisher's vote on the difficulty of a level
+type DifficultyVote struct {
+	LevelId    int          `db:"level_id" json:"levelId"`
+	Uid        int          `db:"uid" json:"uid"`
+	Difficulty int          `db:"difficulty" json:"difficulty"`
+	Created    time.Time    `db:"created" json:"created"`
+	Updated    sql.NullTime `db:"updated" json:"updated"`
+}
+
+// The difficulty of a level according to its players,
+// along with the data it is computed from
+type LevelDifficulty struct {
+	// See CommunityDifficulty
+	Value       float64 `db:"community_difficulty" json:"value"`
+	Votes       int     `db:"votes" json:"votes"`
+	AverageVote float64 `db:"avg_vote" json:"averageVote"`
+	Starts      int     `db:"starts" json:"starts"`
+	Finishes    int     `db:"finishes" json:"finishes"`
+	AvgTime     float64 `db:"avg_time" json:"avgTime"`
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Min(math.Max(v, min), max)
+}
+
+// Blends the difficulty votes, in which the creator's
+// difficulty counts as 2 votes, with the completion rate
+// and the level's average time relative to overallAvgTime,
+// the average of every level. Signals without data are
+// left out. Same as the community_difficulty SQL function.
+func CommunityDifficulty(creator int, d LevelDifficulty, overallAvgTime float64) float64 {
+	voteSum := d.AverageVote * float64(d.Votes)
+	score := 0.5 * (2*clamp(float64(creator), MinDifficulty, MaxDifficulty) + voteSum) / float64(2+d.Votes)
+	weight := 0.5
+	if d.Starts > 0 {
+		rate := math.Min(float64(d.Finishes)/float64(d.Starts), 1)
+		score += 0.3 * (1 + 4*(1-rate))
+		weight += 0.3
+	}
+	if d.AvgTime > 0 && overallAvgTime > 0 {
+		score += 0.2 * clamp(3*d.AvgTime/overallAvgTime, MinDifficulty, MaxDifficulty)
+		weight += 0.2
+	}
+	return score / weight
+}
+
+// Creates or changes the user's difficulty vote.
+// Returns ErrNotFinished if the user hasn't finished
+// the level, ErrOwnLevelVote if it is the user's level
+// and ErrLevelNotFound if viewer can't read it.
+func (v *DifficultyVote) Save(viewer Viewer) error {
+	if v.LevelId == 0 || v.Uid == 0 {
+		return errors.New("missing required fields LevelId, Uid")
+	}
+	if v.Difficulty < MinDifficulty || v.Difficulty > MaxDifficulty {
+		return ErrInvalidDifficulty
+	}
+	return store.Tx(func(s Store) error {
+		level, err := readableLevel(s, v.LevelId, viewer)
+		if err != nil {
+			return err
+		}
+		if level.Uid == v.Uid {
+			return ErrOwnLevelVote
+		}
+		finished, err := s.Difficulty().Finished(v.LevelId, v.Uid)
+		if err != nil {
+			return err
+		}
+		if !finished {
+			return ErrNotFinished
+		}
+		return s.Difficulty().Set(v)
+	})
+}
+
+// Returns the difficulty vote of v on the level
+func GetDifficultyVote(levelId int, v Viewer) (DifficultyVote, error) {
+	if _, err := readableLevel(store, levelId, v); err != nil {
+		return DifficultyVote{}, err
+	}
+	return store.Difficulty().Get(levelId, v.Uid)
+}
+
+// Deletes the difficulty vote of v on the level
+func DeleteDifficultyVote(levelId int, v Viewer) error {
+	if levelId == 0 || v.Uid == 0 {
+		return errors.New("missing required fields levelId, uid")
+	}
+	if _, err := readableLevel(store, levelId, v); err != nil {
+		return err
+	}
+	return store.Difficulty().Delete(levelId, v.Uid)
+}
+
+func GetLevelDifficulty(levelId int) (LevelDifficulty, error) {
+	return store.Difficulty().Level(levelId)
+}
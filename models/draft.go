@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx/types"
-	"github.com/sofferjacob/maker_api/db"
 	"github.com/sofferjacob/maker_api/tracking"
 )
 
@@ -23,6 +22,11 @@ type Draft struct {
 	Car        int                    `db:"car" json:"car" binding:"required"`
 	Soundtrack int                    `db:"soundtrack" json:"soundtrack" binding:"required"`
 	Uid        int                    `db:"uid" json:"uid"`
+	// Set on forks of another user's level
+	SourceLevelId *int `db:"source_level_id" json:"sourceLevelId,omitempty"`
+	SourceVersion *int `db:"source_version" json:"sourceVersion,omitempty"`
+	// Incremented by every update, see ErrRevisionConflict
+	Revision int `db:"revision" json:"revision"`
 }
 
 type DbDraft struct {
@@ -36,8 +40,15 @@ type DbDraft struct {
 	Car        int            `db:"car" json:"car" binding:"required"`
 	Soundtrack int            `db:"soundtrack" json:"soundtrack" binding:"required"`
 	Uid        int            `db:"uid" json:"uid"`
+	// Set to NULL if the source is deleted
+	SourceLevelId *int `db:"source_level_id" json:"sourceLevelId"`
+	SourceVersion *int `db:"source_version" json:"sourceVersion"`
+	Revision      int  `db:"revision" json:"revision"`
 }
 
+// Returned when a user changes another user's draft
+var ErrNotDraftOwner = errors.New("forbidden")
+
 func (db *DbDraft) LoadToDraft(d *Draft) {
 	cd := map[string]interface{}{}
 	json.Unmarshal(db.CourseData, &cd)
@@ -51,6 +62,9 @@ func (db *DbDraft) LoadToDraft(d *Draft) {
 	d.Uid = db.Uid
 	d.Car = db.Car
 	d.Soundtrack = db.Soundtrack
+	d.SourceLevelId = db.SourceLevelId
+	d.SourceVersion = db.SourceVersion
+	d.Revision = db.Revision
 }
 
 func (d *Draft) CourseDataDb() (types.JSONText, error) {
@@ -61,71 +75,56 @@ func (d *Draft) Create() (int, error) {
 	if d.Name == "" || d.Uid == 0 || d.Car == 0 || d.Soundtrack == 0 {
 		return -1, errors.New("missing required field name, uid, car, soundtrack")
 	}
-	qb := db.Insert("drafts").Set("name", d.Name).Set("uid", d.Uid).
-		Set("car", d.Car).Set("soundtrack", d.Soundtrack)
-	if d.LevelId != 0 {
-		qb = qb.Set("level_id", d.LevelId)
-	}
-	if d.CourseData != nil {
-		cd, err := d.CourseDataDb()
-		if err != nil {
-			return -1, err
-		}
-		qb = qb.Set("course_data", cd)
-	}
-	if d.Theme != 0 {
-		qb = qb.Set("theme", d.Theme)
-	}
-
-	query, args := qb.Returning("id").Query()
-	var id int
-	err := db.Client.Client.Get(&id, query, args...)
-	return id, err
+	return store.Drafts().Create(d)
 }
 
+// Updates the non-zero fields of the draft owned by d.Uid
+// and sets d.Revision to the new revision. If d.Revision
+// is set, returns ErrRevisionConflict unless it is the
+// current revision. Returns sql.ErrNoRows if the draft
+// doesn't exist or belongs to another user.
+// The course it replaces is recorded as a checkpoint.
 func (d *Draft) Update() error {
 	if d.Id == 0 || d.Uid == 0 {
 		return errors.New("missing required field id")
 	}
-	qb := db.Update("drafts")
-	if d.Name != "" {
-		qb = qb.Set("name", d.Name)
-	}
-	if d.Theme != 0 {
-		qb = qb.Set("theme", d.Theme)
+	if d.Name == "" && d.Theme == 0 && d.Car == 0 && d.Soundtrack == 0 && d.CourseData == nil {
+		return nil
 	}
-	if d.Car != 0 {
-		qb = qb.Set("car", d.Car)
-	}
-	if d.Soundtrack != 0 {
-		qb = qb.Set("soundtrack", d.Soundtrack)
-	}
-	if d.CourseData != nil {
-		cdE, err := json.Marshal(d.CourseData)
-		cd := types.JSONText(cdE)
-		if err != nil {
-			return fmt.Errorf("could not encode course data: %v", err.Error())
+	return store.Tx(func(s Store) error {
+		if d.CourseData != nil {
+			cur, err := s.Drafts().Get(d.Id)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+			if err == nil && cur.Uid == d.Uid {
+				if err := autosave(s, cur, false); err != nil {
+					return err
+				}
+			}
 		}
-		fmt.Println(cd)
-		qb = qb.Set("course_data", cd)
-	}
-	query, args := qb.Where("id", "=", d.Id).And("uid", "=", d.Uid).
-		Query()
-	fmt.Println(query)
-	fmt.Println(args)
-	_, err := db.Client.Client.Exec(query, args...)
-	return err
+		err := s.Drafts().Update(d)
+		if err != sql.ErrNoRows {
+			return err
+		}
+		cur, err := s.Drafts().Get(d.Id)
+		if err == nil && cur.Uid == d.Uid && d.Revision != 0 {
+			return ErrRevisionConflict
+		}
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		return sql.ErrNoRows
+	})
 }
 
 func (d *Draft) Get() error {
 	if d.Id == 0 {
 		return errors.New("missing required field LevelId")
 	}
-	res := DbDraft{}
-	query := "SELECT * FROM drafts WHERE id = $1"
-	err := db.Client.Client.Get(&res, query, d.Id)
+	res, err := store.Drafts().Get(d.Id)
 	if err == nil {
-		res.LoadToDraft(d)
+		*d = res
 	}
 	return err
 }
@@ -134,11 +133,9 @@ func (d *Draft) FromLevelId() error {
 	if d.LevelId == 0 {
 		return errors.New("missing required field LevelId")
 	}
-	res := DbDraft{}
-	query := "SELECT * FROM drafts WHERE level_id = $1"
-	err := db.Client.Client.Get(&res, query, d.LevelId)
+	res, err := store.Drafts().GetByLevel(d.LevelId)
 	if err == nil {
-		res.LoadToDraft(d)
+		*d = res
 	}
 	return err
 }
@@ -147,38 +144,27 @@ func (d *Draft) Delete() error {
 	if d.Id == 0 {
 		return errors.New("missing required param id")
 	}
-	query := "DELETE FROM drafts WHERE id = $1;"
-	_, err := db.Client.Client.Exec(query, d.Id)
-	return err
+	return store.Drafts().Delete(d.Id)
 }
 
 func (d *Draft) SafeDelete() error {
 	if d.Id == 0 || d.Uid == 0 {
 		return errors.New("missing required param id, uid")
 	}
-	query := "DELETE FROM drafts WHERE id = $1 AND uid = $2;"
-	_, err := db.Client.Client.Exec(query, d.Id, d.Uid)
-	return err
+	return store.Drafts().DeleteOwned(d.Id, d.Uid)
 }
 
-func GetUserDrafts(uid int) ([]Draft, error) {
-	query := "SELECT * FROM drafts WHERE uid = $1;"
-	res := []DbDraft{}
-	err := db.Client.Client.Select(&res, query, uid)
-	arr := make([]Draft, 0, len(res))
-	for _, v := range res {
-		d := Draft{}
-		v.LoadToDraft(&d)
-		arr = append(arr, d)
-	}
-	return arr, err
+// Returns a page of the user's drafts, newest first
+func GetUserDrafts(uid int, p Page) ([]Draft, string, error) {
+	return store.Drafts().GetByUser(uid, p)
 }
 
 // Returns the draft for the level.
 // If no draft exists, returns a new
 // draft. Can also be used to fork a
-// level
-func GetLevelDraft(levelId, uid int) (Draft, error) {
+// level the viewer can read
+func GetLevelDraft(levelId int, v Viewer) (Draft, error) {
+	uid := v.Uid
 	d := Draft{LevelId: levelId}
 	err := d.FromLevelId()
 	if err != nil && err != sql.ErrNoRows {
@@ -187,7 +173,7 @@ func GetLevelDraft(levelId, uid int) (Draft, error) {
 		return d, nil
 	}
 	level := Level{Id: levelId}
-	err = level.Get()
+	err = level.Get(v)
 	if err != nil {
 		return Draft{}, err
 	}
@@ -205,12 +191,20 @@ func GetLevelDraft(levelId, uid int) (Draft, error) {
 	}
 	if uid == level.Uid {
 		draft.LevelId = levelId
+	} else {
+		version, err := store.Versions().Latest(levelId)
+		if err != nil {
+			return Draft{}, err
+		}
+		draft.SourceLevelId = &levelId
+		draft.SourceVersion = &version
 	}
 	id, err := draft.Create()
 	if err != nil {
 		return Draft{}, err
 	}
 	draft.Id = id
+	draft.Revision = 1
 	// We track here bc the route
 	// doesn't know if a draft
 	// was created
@@ -220,5 +214,59 @@ func GetLevelDraft(levelId, uid int) (Draft, error) {
 		DraftId:   id,
 	}
 	event.Send()
+	if draft.SourceLevelId != nil {
+		event := tracking.Event{
+			EventType: "level_clone",
+			Uid:       uid,
+			LevelId:   levelId,
+			DraftId:   id,
+		}
+		event.Send()
+	}
 	return draft, nil
 }
+
+// Applies patch to the course data of the draft owned by
+// uid, at the given revision, and returns the updated
+// draft. Returns ErrRevisionConflict along with the
+// current draft if the revision is stale, ErrNotDraftOwner
+// if uid doesn't own it and a PatchError if the patched
+// course isn't an object.
+func PatchDraft(id, uid, revision int, patch func(interface{}) (interface{}, error)) (Draft, error) {
+	var res Draft
+	err := store.Tx(func(s Store) error {
+		cur, err := s.Drafts().Get(id)
+		if err != nil {
+			return err
+		}
+		if cur.Uid != uid {
+			return ErrNotDraftOwner
+		}
+		res = cur
+		if revision != cur.Revision {
+			return ErrRevisionConflict
+		}
+		if err := autosave(s, cur, false); err != nil {
+			return err
+		}
+		patched, err := patch(copyJSON(cur.CourseData))
+		if err != nil {
+			return err
+		}
+		cd, ok := patched.(map[string]interface{})
+		if !ok {
+			return &PatchError{Msg: "course data must be an object"}
+		}
+		d := Draft{Id: id, Uid: uid, CourseData: cd, Revision: cur.Revision}
+		if err := s.Drafts().Update(&d); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrRevisionConflict
+			}
+			return err
+		}
+		res.CourseData = cd
+		res.Revision = d.Revision
+		return nil
+	})
+	return res, err
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Languages levels, collections and users can be written in.
+// Searches stem their words with the text search config of
+// the language (see ts_config).
+const (
+	LanguageSpanish    = "es"
+	LanguageEnglish    = "en"
+	LanguagePortuguese = "pt"
+	DefaultLanguage    = LanguageSpanish
+)
+
+var ErrInvalidLanguage = errors.New("language must be es, en or pt")
+
+func ValidLanguage(lang string) bool {
+	return lang == LanguageSpanish || lang == LanguageEnglish || lang == LanguagePortuguese
+}
+
+// The language of a search, DefaultLanguage if empty
+func queryLanguage(lang string) (string, error) {
+	if lang == "" {
+		return DefaultLanguage, nil
+	}
+	if !ValidLanguage(lang) {
+		return "", ErrInvalidLanguage
+	}
+	return lang, nil
+}
+
+// The language of new content by uid: lang if set,
+// otherwise the language of the user's profile
+func contentLanguage(s Store, uid int, lang string) (string, error) {
+	if lang != "" {
+		if !ValidLanguage(lang) {
+			return "", ErrInvalidLanguage
+		}
+		return lang, nil
+	}
+	u, err := s.Users().Get(uid)
+	if err == sql.ErrNoRows || u.Language == "" {
+		// Creating the content fails if the user doesn't exist
+		return DefaultLanguage, nil
+	}
+	return u.Language, err
+}
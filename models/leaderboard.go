@@ -2,8 +2,6 @@ package models
 
 import (
 	"time"
-
-	"github.com/sofferjacob/maker_api/db"
 )
 
 type Leaderboard struct {
@@ -12,11 +10,14 @@ type Leaderboard struct {
 	Time      int       `db:"time" json:"time"`
 	Timestamp time.Time `db:"timestamp" json:"timestamp"`
 	Name      string    `db:"name" json:"userName"`
+	// Id of the game_finish event
+	Id int `db:"id" json:"-"`
 }
 
-func GetLeaderboard(levelId int) ([]Leaderboard, error) {
-	query := "SELECT * FROM leaderboard WHERE level_id = $1 LIMIT 10;"
-	res := []Leaderboard{}
-	err := db.Client.Client.Select(&res, query, levelId)
-	return res, err
+// Returns a page of the level's fastest times
+func GetLeaderboard(levelId int, v Viewer, p Page) ([]Leaderboard, string, error) {
+	if _, err := readableLevel(store, levelId, v); err != nil {
+		return nil, "", err
+	}
+	return store.Levels().Leaderboard(levelId, p)
 }
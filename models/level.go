@@ -4,9 +4,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
-
-	"github.com/sofferjacob/maker_api/db"
 )
 
 type Level struct {
@@ -21,6 +20,25 @@ type Level struct {
 	Car         int                    `db:"car" json:"car" binding:"required"`
 	Soundtrack  int                    `db:"soundtrack" json:"soundtrack" binding:"required"`
 	CourseData  map[string]interface{} `db:"course_data" json:"courseData" binding:"required"`
+	// Defaults to the language of the creator
+	Language string `db:"language" json:"language" binding:"omitempty,oneof=es en pt"`
+	// Defaults to VisibilityPublic
+	Visibility string `db:"visibility" json:"visibility" binding:"omitempty,oneof=public unlisted private"`
+	// Only set for unlisted levels, see ShareLink
+	ShareToken string `db:"-" json:"shareToken,omitempty"`
+	// Incremented by every update, see ErrRevisionConflict
+	Revision int `db:"revision" json:"revision"`
+	// The level and version this one is a remix of
+	SourceLevelId *int `db:"source_level_id" json:"sourceLevelId,omitempty"`
+	SourceVersion *int `db:"source_version" json:"sourceVersion,omitempty"`
+	// Only set by Get and GetInfo, and used by Create
+	Tags []string `db:"-" json:"tags,omitempty"`
+	// Only set by GetInfo and TrendingLevels
+	Rating *LevelRating `db:"-" json:"rating,omitempty"`
+	// Only set by GetInfo
+	CommunityDifficulty *LevelDifficulty `db:"-" json:"communityDifficulty,omitempty"`
+	// Number of public remixes, only set by GetInfo
+	Remixes *int `db:"-" json:"remixes,omitempty"`
 }
 
 type DBLevel struct {
@@ -36,6 +54,13 @@ type DBLevel struct {
 	Car         int             `db:"car" json:"car" binding:"required"`
 	Soundtrack  int             `db:"soundtrack" json:"soundtrack" binding:"required"`
 	CourseData  json.RawMessage `db:"course_data" json:"courseData" binding:"required"`
+	Language    string          `db:"language" json:"language"`
+	Visibility  string          `db:"visibility" json:"visibility"`
+	ShareToken  sql.NullString  `db:"share_token" json:"-"`
+	// Set to NULL if the source is deleted
+	SourceLevelId *int `db:"source_level_id" json:"sourceLevelId"`
+	SourceVersion *int `db:"source_version" json:"sourceVersion"`
+	Revision      int  `db:"revision" json:"revision"`
 }
 
 func (db *DBLevel) ToLevel(l *Level) {
@@ -52,27 +77,65 @@ func (db *DBLevel) ToLevel(l *Level) {
 	l.Car = db.Car
 	l.Soundtrack = db.Soundtrack
 	l.CourseData = cd
+	l.Language = db.Language
+	l.Visibility = db.Visibility
+	l.ShareToken = db.ShareToken.String
+	l.SourceLevelId = db.SourceLevelId
+	l.SourceVersion = db.SourceVersion
+	l.Revision = db.Revision
+}
+
+// Defaults the visibility of a new level, and
+// makes the share token of unlisted levels
+func (l *Level) setVisibility() error {
+	if l.Visibility == "" {
+		l.Visibility = VisibilityPublic
+	}
+	if !ValidVisibility(l.Visibility) {
+		return ErrInvalidVisibility
+	}
+	token, err := newShareToken(l.Visibility)
+	l.ShareToken = token
+	return err
 }
 
+// Creates the level and its course data
+// in a single transaction
 func (l *Level) Create() (int, error) {
 	if l.Difficulty == 0 || l.Name == "" || l.Description == "" || l.Uid == 0 || l.Theme == 0 || l.CourseData == nil || l.Car == 0 || l.Soundtrack == 0 {
 		return -1, errors.New("missing required struct fields")
 	}
-	query := "INSERT INTO levels (difficulty, name, description, uid, theme, car, soundtrack) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;"
-	var id int
-	err := db.Client.Client.Get(&id, query, l.Difficulty, l.Name, l.Description, l.Uid, l.Theme, l.Car, l.Soundtrack)
+	tags, err := levelTags(l.Tags)
 	if err != nil {
 		return -1, err
 	}
-	courseData := CourseData{LevelId: id, MapData: l.CourseData}
-	err = courseData.Create()
+	if err := l.setVisibility(); err != nil {
+		return -1, err
+	}
+	var id int
+	err = store.Tx(func(s Store) error {
+		var err error
+		if l.Language, err = contentLanguage(s, l.Uid, l.Language); err != nil {
+			return err
+		}
+		id, err = s.Levels().Create(l)
+		if err != nil {
+			return err
+		}
+		if err := createCourse(s, id, l.CourseData); err != nil {
+			return err
+		}
+		return s.Tags().SetLevelTags(id, tags)
+	})
 	if err != nil {
-		db.Client.Client.Exec("DELETE FROM levels WHERE id = $1;", id)
 		return -1, err
 	}
 	return id, nil
 }
 
+// Publishes the draft as a new level. The level, its
+// course data and the deletion of the draft happen
+// in a single transaction.
 func (l *Level) CreateFromDraft(d Draft) (int, error) {
 	var name string
 	if l.Name != "" {
@@ -85,122 +148,193 @@ func (l *Level) CreateFromDraft(d Draft) (int, error) {
 	if l.Difficulty == 0 || l.Description == "" || l.Uid == 0 || l.Theme == 0 || d.CourseData == nil {
 		return -1, errors.New("missing required struct fields")
 	}
-	query := "INSERT INTO levels (difficulty, name, description, uid, theme, car, soundtrack) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;"
-	var id int
-	err := db.Client.Client.Get(&id, query, l.Difficulty, name, l.Description, l.Uid, l.Theme, d.Car, d.Soundtrack)
+	tags, err := levelTags(l.Tags)
 	if err != nil {
 		return -1, err
 	}
-	courseData := CourseData{LevelId: id, MapData: d.CourseData}
-	err = courseData.Create()
+	level := *l
+	level.Name = name
+	level.Car = d.Car
+	level.Soundtrack = d.Soundtrack
+	level.SourceLevelId = d.SourceLevelId
+	level.SourceVersion = d.SourceVersion
+	if err := level.setVisibility(); err != nil {
+		return -1, err
+	}
+	var id int
+	err = store.Tx(func(s Store) error {
+		var err error
+		if level.Language, err = contentLanguage(s, level.Uid, level.Language); err != nil {
+			return err
+		}
+		id, err = s.Levels().Create(&level)
+		if err != nil {
+			return err
+		}
+		if err := createCourse(s, id, d.CourseData); err != nil {
+			return err
+		}
+		if err := s.Tags().SetLevelTags(id, tags); err != nil {
+			return err
+		}
+		if err := s.Drafts().Delete(d.Id); err != nil {
+			return fmt.Errorf("could not delete draft: %v", err.Error())
+		}
+		return nil
+	})
 	if err != nil {
-		db.Client.Client.Exec("DELETE FROM levels WHERE id = $1;", id)
 		return -1, err
 	}
-	d.Delete()
 	return id, nil
 }
 
-func (l *Level) Get() error {
+// Reads the level and its course data. Returns
+// ErrLevelNotFound if v can't read the level.
+func (l *Level) Get(v Viewer) error {
 	if l.Id == 0 {
 		return errors.New("missing required field Id")
 	}
-	res := DBLevel{}
-	query := "SELECT l.*, c.map_data course_data FROM levels l INNER JOIN course_data c ON l.id = c.level_id WHERE l.id = $1;"
-	err := db.Client.Client.Get(&res, query, l.Id)
-	res.ToLevel(l)
+	res, err := store.Levels().Get(l.Id)
+	if err == sql.ErrNoRows || (err == nil && !res.VisibleTo(v)) {
+		return ErrLevelNotFound
+	}
+	*l = res
+	if err != nil {
+		return err
+	}
+	l.Tags, err = store.Tags().LevelTags(l.Id)
 	return err
 }
 
-func (l *Level) GetInfo() error {
+// Like Get, without the course data and with
+// the level's stats
+func (l *Level) GetInfo(v Viewer) error {
 	if l.Id == 0 {
 		return errors.New("missing required field Id")
 	}
-	d := DBLevel{}
-	query := "SELECT * FROM levels WHERE id = $1;"
-	err := db.Client.Client.Get(&d, query, l.Id)
-	d.ToLevel(l)
+	res, err := store.Levels().GetInfo(l.Id)
+	if err == sql.ErrNoRows || (err == nil && !res.VisibleTo(v)) {
+		return ErrLevelNotFound
+	}
+	*l = res
+	if err != nil {
+		return err
+	}
+	l.Tags, err = store.Tags().LevelTags(l.Id)
+	if err != nil {
+		return err
+	}
+	rating, err := store.Votes().Rating(l.Id)
+	if err != nil {
+		return err
+	}
+	l.Rating = &rating
+	difficulty, err := store.Difficulty().Level(l.Id)
+	if err != nil {
+		return err
+	}
+	l.CommunityDifficulty = &difficulty
+	remixes, err := store.Levels().RemixCount(l.Id)
+	l.Remixes = &remixes
 	return err
 }
 
-func GetUserLevels(uid int) ([]Level, error) {
-	query := "SELECT * FROM levels WHERE uid = $1;"
-	res := []DBLevel{}
-	err := db.Client.Client.Select(&res, query, uid)
-	levels := make([]Level, len(res))
-	for i, v := range res {
-		v.ToLevel(&levels[i])
-	}
-	return levels, err
+// Returns a page of the user's levels, newest first.
+// Unlisted and private levels are only returned to
+// the user and moderators.
+func GetUserLevels(uid int, v Viewer, p Page) ([]Level, string, error) {
+	return store.Levels().GetByUser(uid, v.Uid == uid || v.Moderator, p)
 }
 
+// Updates the level owned by l.Uid and its course data
+// in a single transaction. New course data is saved as
+// a new version of the level. l.Revision is set to the
+// new revision. If it was set, returns ErrRevisionConflict
+// unless it was the current revision. Returns sql.ErrNoRows if the
+// user doesn't own the level.
 func (l *Level) Update() error {
 	if l.Id == 0 || l.Uid == 0 {
 		return errors.New("missing required fields Id, Uid")
 	}
-	query := db.Update("levels")
-	if l.Name != "" {
-		query = query.Set("name", l.Name)
-	}
-	if l.Difficulty != 0 {
-		query = query.Set("difficulty", l.Difficulty)
-	}
-	if l.Description != "" {
-		query = query.Set("description", l.Description)
+	if l.Language != "" && !ValidLanguage(l.Language) {
+		return ErrInvalidLanguage
 	}
-	if l.Theme != 0 {
-		query = query.Set("theme", l.Theme)
+	if l.Visibility != "" {
+		if err := l.setVisibility(); err != nil {
+			return err
+		}
 	}
-	queryStr, args := query.Where("id", "=", l.Id).
-		And("uid", "=", l.Uid).Query()
-	_, err := db.Client.Client.Exec(queryStr, args...)
-	if err != nil {
+	return store.Tx(func(s Store) error {
+		err := s.Levels().Update(l)
+		if err == sql.ErrNoRows && l.Revision != 0 {
+			if owner, err := s.Levels().Owner(l.Id); err == nil && owner == l.Uid {
+				return ErrRevisionConflict
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if l.CourseData == nil {
+			return nil
+		}
+		_, err = publish(s, l.Id, l.CourseData, nil)
 		return err
-	}
-	if l.CourseData != nil {
-		cd := CourseData{LevelId: l.Id, MapData: l.CourseData}
-		err = cd.Update()
-	}
-	return err
+	})
 }
 
-func UpdateLevelFomDraft(levelId int, draft Draft) error {
-	levelQuery := "SELECT uid FROM levels WHERE id = $1;"
-	var levelUid int
-	err := db.Client.Client.Get(&levelUid, levelQuery, levelId)
+// Publishes the draft over the level if the level is
+// still at revision (0 matches any). Returns the new
+// revision of the level.
+func UpdateLevelFomDraft(levelId int, draft Draft, revision int) (int, error) {
+	levelUid, err := store.Levels().Owner(levelId)
+	if err == sql.ErrNoRows {
+		return 0, ErrLevelNotFound
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if levelUid != draft.Uid || draft.CourseData == nil {
-		return errors.New("invalid draft")
+		return 0, errors.New("invalid draft")
 	}
-	level := Level{Id: levelId, Uid: levelUid, Name: draft.Name, CourseData: draft.CourseData, Car: draft.Car, Soundtrack: draft.Soundtrack}
-	return level.Update()
+	level := Level{Id: levelId, Uid: levelUid, Name: draft.Name, CourseData: draft.CourseData, Car: draft.Car, Soundtrack: draft.Soundtrack, Revision: revision}
+	err = level.Update()
+	return level.Revision, err
 }
 
-func QueryLevelFTS(query string) ([]Level, error) {
-	res := []DBLevel{}
-	err := db.Client.Client.Select(&res, "SELECT * FROM query_gin(null::levels, $1);", query)
-	if err != nil {
-		return nil, err
+// Deletes the level owned by uid, along with
+// its course data, drafts and collection links
+func DeleteLevel(levelId, uid int) error {
+	if levelId == 0 || uid == 0 {
+		return errors.New("missing required fields levelId, uid")
 	}
-	levels := make([]Level, 0, len(res))
-	for _, v := range res {
-		l := Level{}
-		v.ToLevel(&l)
-		levels = append(levels, l)
-	}
-	return levels, err
+	return store.Levels().Delete(levelId, uid)
 }
 
-func DeleteLevel(levelId int) error {
-	// TODO, maybe with a procedure
-	return errors.New("not implemented")
+// Returns the uid of the level's creator,
+// or ErrLevelNotFound
+func GetLevelOwner(levelId int) (int, error) {
+	uid, err := store.Levels().Owner(levelId)
+	if err == sql.ErrNoRows {
+		return 0, ErrLevelNotFound
+	}
+	return uid, err
 }
 
-func TrendingLevels() ([]Level, error) {
-	query := "SELECT id, difficulty, name, description, uid, created, updated, theme FROM trending_levels;"
-	res := []Level{}
-	err := db.Client.Client.Select(&res, query)
-	return res, err
+const (
+	TrendingByPlays  = "plays"
+	TrendingByRating = "rating"
+)
+
+var ErrInvalidTrendingSort = errors.New("trending levels can be sorted by plays or rating")
+
+// Returns the most played levels, or the best rated
+// ones if by is TrendingByRating
+func TrendingLevels(by string) ([]Level, error) {
+	switch by {
+	case TrendingByPlays:
+		return store.Levels().Trending()
+	case TrendingByRating:
+		return store.Levels().TopRated()
+	}
+	return nil, ErrInvalidTrendingSort
 }
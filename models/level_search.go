@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Sort orders of a level search
+const (
+	// Best matches first. Without a text query
+	// it is the same as SortNewest.
+	SortRelevance = "relevance"
+	SortNewest    = "newest"
+	// Most game_start events first
+	SortMostPlayed = "plays"
+	// Highest Bayesian rating first
+	SortTopRated = "rating"
+)
+
+var ErrInvalidSort = errors.New("levels can be sorted by relevance, newest, plays or rating")
+
+// Narrows a level search. Zero values don't filter.
+type LevelFilter struct {
+	// Range of the community difficulty
+	MinDifficulty float64
+	MaxDifficulty float64
+	// Levels must have every tag
+	Tags []string
+	// Levels must have one of the values
+	Themes      []int
+	Cars        []int
+	Soundtracks []int
+	Creator     int
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	UpdatedFrom time.Time
+	UpdatedTo   time.Time
+	MinPlays    int
+	// Minimum average of the star ratings
+	MinRating float64
+}
+
+// A value of a facet and the number of
+// levels of the search that have it
+type FacetCount struct {
+	Value int `db:"value" json:"value"`
+	Count int `db:"count" json:"count"`
+}
+
+// Counts of the levels matching a search by theme, car,
+// soundtrack and rounded community difficulty. Each facet
+// is counted without its own filter, so clients can show
+// how many levels picking another value would add.
+type LevelFacets struct {
+	Themes       []FacetCount `json:"themes"`
+	Cars         []FacetCount `json:"cars"`
+	Soundtracks  []FacetCount `json:"soundtracks"`
+	Difficulties []FacetCount `json:"difficulties"`
+}
+
+// Facets of a level search
+const (
+	FacetTheme      = "theme"
+	FacetCar        = "car"
+	FacetSoundtrack = "soundtrack"
+	FacetDifficulty = "difficulty"
+)
+
+// Drops the filter of the facet, see LevelFacets
+func (f LevelFilter) without(facet string) LevelFilter {
+	switch facet {
+	case FacetTheme:
+		f.Themes = nil
+	case FacetCar:
+		f.Cars = nil
+	case FacetSoundtrack:
+		f.Soundtracks = nil
+	case FacetDifficulty:
+		f.MinDifficulty, f.MaxDifficulty = 0, 0
+	}
+	return f
+}
+
+func (f *LevelFilter) normalize() error {
+	tags, err := NormalizeTags(f.Tags)
+	f.Tags = tags
+	return err
+}
+
+func validSort(sort string) bool {
+	return sort == SortRelevance || sort == SortNewest || sort == SortMostPlayed || sort == SortTopRated
+}
+
+// Searches levels whose name or description match query,
+// or every level if query is empty. The query is parsed
+// in the language lang, DefaultLanguage if empty. Sorts
+// by relevance if sort is empty.
+func QueryLevelFTS(query, lang string, f LevelFilter, sort string, p Page) ([]Level, string, error) {
+	if sort == "" {
+		sort = SortRelevance
+	}
+	if !validSort(sort) {
+		return nil, "", ErrInvalidSort
+	}
+	if sort == SortRelevance && query == "" {
+		sort = SortNewest
+	}
+	if err := f.normalize(); err != nil {
+		return nil, "", err
+	}
+	lang, err := queryLanguage(lang)
+	if err != nil {
+		return nil, "", err
+	}
+	return store.Levels().Query(query, lang, f, sort, p)
+}
+
+// Returns the facet counts of a level search
+func GetLevelFacets(query, lang string, f LevelFilter) (LevelFacets, error) {
+	if err := f.normalize(); err != nil {
+		return LevelFacets{}, err
+	}
+	lang, err := queryLanguage(lang)
+	if err != nil {
+		return LevelFacets{}, err
+	}
+	res := LevelFacets{}
+	facets := map[string]*[]FacetCount{
+		FacetTheme:      &res.Themes,
+		FacetCar:        &res.Cars,
+		FacetSoundtrack: &res.Soundtracks,
+		FacetDifficulty: &res.Difficulties,
+	}
+	for facet, counts := range facets {
+		c, err := store.Levels().Facet(query, lang, f.without(facet), facet)
+		if err != nil {
+			return LevelFacets{}, err
+		}
+		*counts = c
+	}
+	return res, nil
+}
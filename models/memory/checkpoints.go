@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type checkpoints struct {
+	*Store
+}
+
+type checkpoint struct {
+	models.Checkpoint
+	courseData json.RawMessage
+}
+
+func (c checkpoint) load() (models.Checkpoint, error) {
+	res := c.Checkpoint
+	err := json.Unmarshal(c.courseData, &res.CourseData)
+	return res, err
+}
+
+func (r checkpoints) Create(c *models.Checkpoint) (int, error) {
+	defer r.lock()()
+	if _, ok := r.data.drafts[c.DraftId]; !ok {
+		return 0, fkError("draft_checkpoints", "draft_checkpoints_draft_id_fkey")
+	}
+	cd, err := json.Marshal(c.CourseData)
+	if err != nil {
+		return 0, err
+	}
+	row := checkpoint{Checkpoint: *c, courseData: cd}
+	row.CourseData = nil
+	row.Id = r.data.nextId("draft_checkpoints")
+	row.Created = time.Now()
+	r.data.checkpoints[row.Id] = row
+	return row.Id, nil
+}
+
+func (r checkpoints) Get(draftId, id int) (models.Checkpoint, error) {
+	defer r.lock()()
+	row, ok := r.data.checkpoints[id]
+	if !ok || row.DraftId != draftId {
+		return models.Checkpoint{}, sql.ErrNoRows
+	}
+	return row.load()
+}
+
+// Ids of the draft's checkpoints, ascending. If
+// named is set, only those with a name or without
+// one, otherwise all of them.
+func (r checkpoints) ids(draftId int, named *bool) []int {
+	res := []int{}
+	for _, id := range sortedIds(r.data.checkpoints) {
+		c := r.data.checkpoints[id]
+		if c.DraftId == draftId && (named == nil || *named == (c.Name != "")) {
+			res = append(res, id)
+		}
+	}
+	return res
+}
+
+func (r checkpoints) Latest(draftId int) (models.Checkpoint, error) {
+	defer r.lock()()
+	ids := r.ids(draftId, nil)
+	if len(ids) == 0 {
+		return models.Checkpoint{}, sql.ErrNoRows
+	}
+	return r.data.checkpoints[ids[len(ids)-1]].load()
+}
+
+func (r checkpoints) List(draftId int, p models.Page) ([]models.Checkpoint, string, error) {
+	defer r.lock()()
+	res := []models.Checkpoint{}
+	for _, id := range newestFirst(r.ids(draftId, nil), p) {
+		res = append(res, r.data.checkpoints[id].Checkpoint)
+	}
+	res, next := models.Paginate(res, p, func(c models.Checkpoint) models.Cursor { return models.Cursor{Id: c.Id} })
+	return res, next, nil
+}
+
+func (r checkpoints) CountNamed(draftId int) (int, error) {
+	defer r.lock()()
+	named := true
+	return len(r.ids(draftId, &named)), nil
+}
+
+func (r checkpoints) Prune(draftId, keep int) error {
+	defer r.lock()()
+	named := false
+	ids := r.ids(draftId, &named)
+	for i := 0; i < len(ids)-keep; i++ {
+		delete(r.data.checkpoints, ids[i])
+	}
+	return nil
+}
+
+func (r checkpoints) Delete(draftId, id int) error {
+	defer r.lock()()
+	if row, ok := r.data.checkpoints[id]; ok && row.DraftId == draftId {
+		delete(r.data.checkpoints, id)
+	}
+	return nil
+}
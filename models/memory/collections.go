@@ -0,0 +1,210 @@
+package memory
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type collections struct {
+	*Store
+}
+
+func (r collections) Create(c *models.Collection) (int, error) {
+	defer r.lock()()
+	if _, ok := r.data.users[c.Uid]; !ok {
+		return 0, fkError("collection", "collection_uid_fkey")
+	}
+	row := models.Collection{
+		Id:          r.data.nextId("collection"),
+		Name:        c.Name,
+		Description: c.Description,
+		Uid:         c.Uid,
+		Created:     time.Now(),
+		Language:    c.Language,
+	}
+	r.data.collections[row.Id] = row
+	return row.Id, nil
+}
+
+func (d *data) collectionData(c models.Collection) models.CollectionData {
+	return models.CollectionData{Collection: c, UserName: d.users[c.Uid].Name}
+}
+
+func (r collections) Get(id int) (models.CollectionData, error) {
+	defer r.lock()()
+	c, ok := r.data.collections[id]
+	if !ok {
+		return models.CollectionData{}, sql.ErrNoRows
+	}
+	return r.data.collectionData(c), nil
+}
+
+func (r collections) GetByUser(uid int, p models.Page) ([]models.CollectionData, string, error) {
+	defer r.lock()()
+	ids := []int{}
+	for _, id := range sortedIds(r.data.collections) {
+		if r.data.collections[id].Uid == uid {
+			ids = append(ids, id)
+		}
+	}
+	res := []models.CollectionData{}
+	for _, id := range newestFirst(ids, p) {
+		res = append(res, r.data.collectionData(r.data.collections[id]))
+	}
+	res, next := models.Paginate(res, p, func(c models.CollectionData) models.Cursor { return models.Cursor{Id: c.Id} })
+	return res, next, nil
+}
+
+func (r collections) Update(c *models.Collection) error {
+	defer r.lock()()
+	row, ok := r.data.collections[c.Id]
+	if !ok || row.Uid != c.Uid {
+		return nil
+	}
+	if c.Name != "" {
+		row.Name = c.Name
+	}
+	if c.Description != "" {
+		row.Description = c.Description
+	}
+	if c.Language != "" {
+		row.Language = c.Language
+	}
+	r.data.collections[c.Id] = row
+	return nil
+}
+
+func (r collections) Delete(id, uid int) error {
+	defer r.lock()()
+	row, ok := r.data.collections[id]
+	if !ok || row.Uid != uid {
+		return nil
+	}
+	delete(r.data.collections, id)
+	// ON DELETE CASCADE
+	for lid, cl := range r.data.collectionLevels {
+		if cl.CollectionId == id {
+			delete(r.data.collectionLevels, lid)
+		}
+	}
+	for cid, c := range r.data.comments {
+		if c.CollectionId != nil && *c.CollectionId == id {
+			delete(r.data.comments, cid)
+		}
+	}
+	return nil
+}
+
+func (r collections) Owner(id int) (int, error) {
+	defer r.lock()()
+	c, ok := r.data.collections[id]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return c.Uid, nil
+}
+
+func (r collections) Query(query, lang string, p models.Page) ([]models.Collection, string, error) {
+	defer r.lock()()
+	hits := search(query, r.data.collectionDocs())
+	hits, next := models.Paginate(pageHits(hits, p), p, hit.cursor)
+	res := []models.Collection{}
+	for _, h := range hits {
+		res = append(res, r.data.collections[h.id])
+	}
+	return res, next, nil
+}
+
+func (d *data) collectionDocs() []doc {
+	docs := []doc{}
+	for _, id := range sortedIds(d.collections) {
+		c := d.collections[id]
+		docs = append(docs, doc{id, c.Name, []string{c.Name, c.Description}})
+	}
+	return docs
+}
+
+func (r collections) Suggest(prefix string, limit int) ([]models.Suggestion, error) {
+	defer r.lock()()
+	return suggest(prefix, r.data.collectionDocs(), limit), nil
+}
+
+// Collections with at least one level, sorted
+// by the plays of their levels
+func (r collections) Trending() ([]models.Collection, error) {
+	defer r.lock()()
+	plays := r.data.plays()
+	collectionPlays := map[int]int{}
+	for _, cl := range r.data.collectionLevels {
+		// trending_levels only has public levels
+		n := plays[cl.LevelId]
+		if !r.data.public(cl.LevelId) {
+			n = 0
+		}
+		collectionPlays[cl.CollectionId] += n
+	}
+	ids := []int{}
+	for _, id := range sortedIds(r.data.collections) {
+		if _, ok := collectionPlays[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return collectionPlays[ids[i]] > collectionPlays[ids[j]] })
+	res := make([]models.Collection, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, r.data.collections[id])
+	}
+	return res, nil
+}
+
+func (r collections) AddLevel(cl *models.CollectionLevels) error {
+	defer r.lock()()
+	if _, ok := r.data.collections[cl.CollectionId]; !ok {
+		return fkError("collection_levels", "collection_levels_collection_id_fkey")
+	}
+	if _, ok := r.data.levels[cl.LevelId]; !ok {
+		return fkError("collection_levels", "collection_levels_level_id_fkey")
+	}
+	row := models.CollectionLevels{
+		Id:           r.data.nextId("collection_levels"),
+		CollectionId: cl.CollectionId,
+		LevelId:      cl.LevelId,
+	}
+	r.data.collectionLevels[row.Id] = row
+	return nil
+}
+
+func (r collections) RemoveLevel(cl *models.CollectionLevels) error {
+	defer r.lock()()
+	for id, v := range r.data.collectionLevels {
+		if v.CollectionId == cl.CollectionId && v.LevelId == cl.LevelId {
+			delete(r.data.collectionLevels, id)
+		}
+	}
+	return nil
+}
+
+// Levels in the order they were added, the
+// cursor is the id of the collection link
+func (r collections) Levels(collectionId int, p models.Page) ([]models.Level, string, error) {
+	defer r.lock()()
+	links := []int{}
+	for _, id := range sortedIds(r.data.collectionLevels) {
+		cl := r.data.collectionLevels[id]
+		if cl.CollectionId != collectionId || (p.After != nil && id <= p.After.Id) {
+			continue
+		}
+		if _, ok := r.data.levels[cl.LevelId]; ok && r.data.public(cl.LevelId) && len(links) < p.Fetch() {
+			links = append(links, id)
+		}
+	}
+	links, next := models.Paginate(links, p, func(id int) models.Cursor { return models.Cursor{Id: id} })
+	res := []models.Level{}
+	for _, id := range links {
+		res = append(res, toLevel(r.data.levels[r.data.collectionLevels[id].LevelId]))
+	}
+	return res, next, nil
+}
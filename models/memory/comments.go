@@ -0,0 +1,174 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type comments struct {
+	*Store
+}
+
+// Like the comment_data view
+func (d *data) commentData(c models.Comment) models.CommentData {
+	res := models.CommentData{Comment: c, UserName: d.users[c.Uid].Name}
+	for _, r := range d.comments {
+		if r.ParentId != nil && *r.ParentId == c.Id {
+			res.Replies++
+		}
+	}
+	return res
+}
+
+// Deletes the comment and, like ON DELETE CASCADE,
+// its replies
+func (d *data) deleteComment(id int) {
+	delete(d.comments, id)
+	for rid, r := range d.comments {
+		if r.ParentId != nil && *r.ParentId == id {
+			d.deleteComment(rid)
+		}
+	}
+}
+
+// Copies an optional id, so rows don't share
+// pointers with callers
+func optionalId(id *int) *int {
+	if id == nil {
+		return nil
+	}
+	v := *id
+	return &v
+}
+
+func (r comments) Create(c *models.Comment) (int, error) {
+	defer r.lock()()
+	if _, ok := r.data.users[c.Uid]; !ok {
+		return 0, fkError("comments", "comments_uid_fkey")
+	}
+	if c.LevelId != nil {
+		if _, ok := r.data.levels[*c.LevelId]; !ok {
+			return 0, fkError("comments", "comments_level_id_fkey")
+		}
+	}
+	if c.CollectionId != nil {
+		if _, ok := r.data.collections[*c.CollectionId]; !ok {
+			return 0, fkError("comments", "comments_collection_id_fkey")
+		}
+	}
+	if c.ParentId != nil {
+		if _, ok := r.data.comments[*c.ParentId]; !ok {
+			return 0, fkError("comments", "comments_parent_id_fkey")
+		}
+	}
+	row := models.Comment{
+		Id:           r.data.nextId("comments"),
+		Uid:          c.Uid,
+		LevelId:      optionalId(c.LevelId),
+		CollectionId: optionalId(c.CollectionId),
+		ParentId:     optionalId(c.ParentId),
+		Body:         c.Body,
+		Created:      time.Now(),
+	}
+	r.data.comments[row.Id] = row
+	return row.Id, nil
+}
+
+func (r comments) Get(id int) (models.CommentData, error) {
+	defer r.lock()()
+	c, ok := r.data.comments[id]
+	if !ok {
+		return models.CommentData{}, sql.ErrNoRows
+	}
+	return r.data.commentData(c), nil
+}
+
+// Top level comments matching keep, newest first
+func (r comments) topLevel(keep func(c models.Comment) bool, p models.Page) ([]models.CommentData, string, error) {
+	defer r.lock()()
+	ids := []int{}
+	for _, id := range sortedIds(r.data.comments) {
+		c := r.data.comments[id]
+		if c.ParentId == nil && keep(c) {
+			ids = append(ids, id)
+		}
+	}
+	res := []models.CommentData{}
+	for _, id := range newestFirst(ids, p) {
+		res = append(res, r.data.commentData(r.data.comments[id]))
+	}
+	res, next := models.Paginate(res, p, func(c models.CommentData) models.Cursor { return models.Cursor{Id: c.Id} })
+	return res, next, nil
+}
+
+func (r comments) ByLevel(levelId int, p models.Page) ([]models.CommentData, string, error) {
+	return r.topLevel(func(c models.Comment) bool {
+		return c.LevelId != nil && *c.LevelId == levelId
+	}, p)
+}
+
+func (r comments) ByCollection(collectionId int, p models.Page) ([]models.CommentData, string, error) {
+	return r.topLevel(func(c models.Comment) bool {
+		return c.CollectionId != nil && *c.CollectionId == collectionId
+	}, p)
+}
+
+func (r comments) Replies(id int, p models.Page) ([]models.CommentData, string, error) {
+	defer r.lock()()
+	res := []models.CommentData{}
+	for _, rid := range sortedIds(r.data.comments) {
+		c := r.data.comments[rid]
+		if c.ParentId == nil || *c.ParentId != id || (p.After != nil && rid <= p.After.Id) {
+			continue
+		}
+		if len(res) == p.Fetch() {
+			break
+		}
+		res = append(res, r.data.commentData(c))
+	}
+	res, next := models.Paginate(res, p, func(c models.CommentData) models.Cursor { return models.Cursor{Id: c.Id} })
+	return res, next, nil
+}
+
+func (r comments) Update(c *models.Comment) error {
+	defer r.lock()()
+	row, ok := r.data.comments[c.Id]
+	if !ok || row.Uid != c.Uid {
+		return sql.ErrNoRows
+	}
+	row.Body = c.Body
+	row.Updated = sql.NullTime{Time: time.Now(), Valid: true}
+	r.data.comments[c.Id] = row
+	return nil
+}
+
+func (r comments) Delete(id int) error {
+	defer r.lock()()
+	if _, ok := r.data.comments[id]; !ok {
+		return sql.ErrNoRows
+	}
+	r.data.deleteComment(id)
+	return nil
+}
+
+func (r comments) Query(query, lang string, p models.Page) ([]models.CommentData, string, error) {
+	defer r.lock()()
+	hits := []hit{}
+	for _, id := range sortedIds(r.data.comments) {
+		c := r.data.comments[id]
+		if c.LevelId != nil && !r.data.public(*c.LevelId) {
+			continue
+		}
+		if rank, ok := match(query, c.Body); ok {
+			hits = append(hits, hit{id, rank})
+		}
+	}
+	hits, next := models.Paginate(pageHits(hits, p), p, hit.cursor)
+	res := []models.CommentData{}
+	for _, h := range hits {
+		res = append(res, r.data.commentData(r.data.comments[h.id]))
+	}
+	return res, next, nil
+}
@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type difficulty struct {
+	*Store
+}
+
+func (r difficulty) Finished(levelId, uid int) (bool, error) {
+	defer r.lock()()
+	for _, e := range r.data.events {
+		if e.EventType == "game_finish" && e.LevelId == levelId && e.Uid == uid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r difficulty) Set(v *models.DifficultyVote) error {
+	defer r.lock()()
+	if _, ok := r.data.levels[v.LevelId]; !ok {
+		return fkError("difficulty_votes", "difficulty_votes_level_id_fkey")
+	}
+	if _, ok := r.data.users[v.Uid]; !ok {
+		return fkError("difficulty_votes", "difficulty_votes_uid_fkey")
+	}
+	k := voteKey{v.LevelId, v.Uid}
+	now := time.Now()
+	row, ok := r.data.difficultyVotes[k]
+	if !ok {
+		row = models.DifficultyVote{LevelId: v.LevelId, Uid: v.Uid, Created: now}
+	}
+	row.Difficulty = v.Difficulty
+	row.Updated = sql.NullTime{Time: now, Valid: true}
+	r.data.difficultyVotes[k] = row
+	return nil
+}
+
+func (r difficulty) Get(levelId, uid int) (models.DifficultyVote, error) {
+	defer r.lock()()
+	row, ok := r.data.difficultyVotes[voteKey{levelId, uid}]
+	if !ok {
+		return models.DifficultyVote{}, sql.ErrNoRows
+	}
+	return row, nil
+}
+
+func (r difficulty) Delete(levelId, uid int) error {
+	defer r.lock()()
+	k := voteKey{levelId, uid}
+	if _, ok := r.data.difficultyVotes[k]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.data.difficultyVotes, k)
+	return nil
+}
+
+func (r difficulty) Level(levelId int) (models.LevelDifficulty, error) {
+	defer r.lock()()
+	d, ok := r.data.difficulties()[levelId]
+	if !ok {
+		return models.LevelDifficulty{}, sql.ErrNoRows
+	}
+	return d, nil
+}
+
+// Like the level_difficulty view
+func (d *data) difficulties() map[int]models.LevelDifficulty {
+	res := map[int]models.LevelDifficulty{}
+	for id := range d.levels {
+		res[id] = models.LevelDifficulty{}
+	}
+	times := map[int][]int{}
+	for _, e := range d.events {
+		stats, ok := res[e.LevelId]
+		if !ok {
+			continue
+		}
+		switch e.EventType {
+		case "game_start":
+			stats.Starts++
+		case "game_finish":
+			stats.Finishes++
+			// NULL times are stored as 0
+			if e.Time != 0 {
+				times[e.LevelId] = append(times[e.LevelId], e.Time)
+			}
+		}
+		res[e.LevelId] = stats
+	}
+	sum, n := 0.0, 0
+	for id, ts := range times {
+		total := 0
+		for _, t := range ts {
+			total += t
+		}
+		stats := res[id]
+		stats.AvgTime = float64(total) / float64(len(ts))
+		res[id] = stats
+		sum += stats.AvgTime
+		n++
+	}
+	overall := 0.0
+	if n > 0 {
+		overall = sum / float64(n)
+	}
+	for k, v := range d.difficultyVotes {
+		stats := res[k.levelId]
+		stats.AverageVote = (stats.AverageVote*float64(stats.Votes) + float64(v.Difficulty)) / float64(stats.Votes+1)
+		stats.Votes++
+		res[k.levelId] = stats
+	}
+	for id, stats := range res {
+		stats.Value = models.CommunityDifficulty(d.levels[id].Difficulty, stats, overall)
+		res[id] = stats
+	}
+	return res
+}
@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type drafts struct {
+	*Store
+}
+
+func toDraft(row models.DbDraft) models.Draft {
+	d := models.Draft{}
+	row.LoadToDraft(&d)
+	return d
+}
+
+func (r drafts) Create(d *models.Draft) (int, error) {
+	defer r.lock()()
+	if _, ok := r.data.users[d.Uid]; !ok {
+		return -1, fkError("drafts", "drafts_uid_fkey")
+	}
+	row := models.DbDraft{
+		Id:         r.data.nextId("drafts"),
+		Name:       d.Name,
+		Created:    time.Now(),
+		Theme:      1,
+		Car:        d.Car,
+		Soundtrack: d.Soundtrack,
+		Uid:        d.Uid,
+		Revision:   1,
+	}
+	if d.LevelId != 0 {
+		if _, ok := r.data.levels[d.LevelId]; !ok {
+			return -1, fkError("drafts", "drafts_level_id_fkey")
+		}
+		for _, v := range r.data.drafts {
+			if int(v.LevelId.Int32) == d.LevelId {
+				return -1, uniqueError("drafts_level_id_key")
+			}
+		}
+		row.LevelId = sql.NullInt32{Int32: int32(d.LevelId), Valid: true}
+	}
+	if d.CourseData != nil {
+		cd, err := d.CourseDataDb()
+		if err != nil {
+			return -1, err
+		}
+		row.CourseData = cd
+	}
+	if d.Theme != 0 {
+		row.Theme = d.Theme
+	}
+	if d.SourceLevelId != nil {
+		if _, ok := r.data.levels[*d.SourceLevelId]; !ok {
+			return -1, fkError("drafts", "drafts_source_level_id_fkey")
+		}
+		row.SourceLevelId, row.SourceVersion = copyInt(d.SourceLevelId), copyInt(d.SourceVersion)
+	}
+	r.data.drafts[row.Id] = row
+	return row.Id, nil
+}
+
+func (r drafts) Update(d *models.Draft) error {
+	defer r.lock()()
+	row, ok := r.data.drafts[d.Id]
+	if !ok || row.Uid != d.Uid || (d.Revision != 0 && row.Revision != d.Revision) {
+		return sql.ErrNoRows
+	}
+	if d.Name != "" {
+		row.Name = d.Name
+	}
+	if d.Theme != 0 {
+		row.Theme = d.Theme
+	}
+	if d.Car != 0 {
+		row.Car = d.Car
+	}
+	if d.Soundtrack != 0 {
+		row.Soundtrack = d.Soundtrack
+	}
+	if d.CourseData != nil {
+		cd, err := json.Marshal(d.CourseData)
+		if err != nil {
+			return fmt.Errorf("could not encode course data: %v", err.Error())
+		}
+		row.CourseData = cd
+	}
+	row.Updated = sql.NullTime{Time: time.Now(), Valid: true}
+	row.Revision++
+	d.Revision = row.Revision
+	r.data.drafts[d.Id] = row
+	return nil
+}
+
+func (r drafts) Get(id int) (models.Draft, error) {
+	defer r.lock()()
+	row, ok := r.data.drafts[id]
+	if !ok {
+		return models.Draft{}, sql.ErrNoRows
+	}
+	return toDraft(row), nil
+}
+
+func (r drafts) GetByLevel(levelId int) (models.Draft, error) {
+	defer r.lock()()
+	for _, row := range r.data.drafts {
+		if int(row.LevelId.Int32) == levelId {
+			return toDraft(row), nil
+		}
+	}
+	return models.Draft{}, sql.ErrNoRows
+}
+
+func (r drafts) GetByUser(uid int, p models.Page) ([]models.Draft, string, error) {
+	defer r.lock()()
+	ids := []int{}
+	for _, id := range sortedIds(r.data.drafts) {
+		if r.data.drafts[id].Uid == uid {
+			ids = append(ids, id)
+		}
+	}
+	res := []models.Draft{}
+	for _, id := range newestFirst(ids, p) {
+		res = append(res, toDraft(r.data.drafts[id]))
+	}
+	res, next := models.Paginate(res, p, func(d models.Draft) models.Cursor { return models.Cursor{Id: d.Id} })
+	return res, next, nil
+}
+
+// Deletes the draft and, ON DELETE CASCADE,
+// its checkpoints
+func (d *data) deleteDraft(id int) {
+	delete(d.drafts, id)
+	for cid, c := range d.checkpoints {
+		if c.DraftId == id {
+			delete(d.checkpoints, cid)
+		}
+	}
+}
+
+func (r drafts) Delete(id int) error {
+	defer r.lock()()
+	r.data.deleteDraft(id)
+	return nil
+}
+
+func (r drafts) DeleteOwned(id, uid int) error {
+	defer r.lock()()
+	if row, ok := r.data.drafts[id]; ok && row.Uid == uid {
+		r.data.deleteDraft(id)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sofferjacob/maker_api/tracking"
+)
+
+type events struct {
+	*Store
+}
+
+func (r events) Insert(e *tracking.Event) error {
+	defer r.lock()()
+	if e.Uid != 0 {
+		if _, ok := r.data.users[e.Uid]; !ok {
+			return fkError("events", "events_uid_fkey")
+		}
+	}
+	if e.LevelId != 0 {
+		if _, ok := r.data.levels[e.LevelId]; !ok {
+			return fkError("events", "events_level_id_fkey")
+		}
+	}
+	row := event{Event: *e}
+	row.Id = r.data.nextId("events")
+	row.Timestamp = time.Now()
+	row.Body = nil
+	if e.Body != nil {
+		body, err := json.Marshal(e.Body)
+		if err != nil {
+			return fmt.Errorf("could not parse body: %v", err.Error())
+		}
+		row.body = body
+	}
+	r.data.events[row.Id] = row
+	return nil
+}
+
+// Returns the events of type eventType that match
+// the filter, oldest first
+func (r events) filter(eventType string, f tracking.StatsFilter) []event {
+	res := []event{}
+	for _, id := range sortedIds(r.data.events) {
+		e := r.data.events[id]
+		if e.EventType != eventType || e.LevelId != f.LevelId {
+			continue
+		}
+		if (!f.From.IsZero() && e.Timestamp.Before(f.From)) || (!f.To.IsZero() && e.Timestamp.After(f.To)) {
+			continue
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+// Like date(timestamp)
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Groups the events by date, in ascending order
+func byDate(events []event) ([]time.Time, map[time.Time][]event) {
+	groups := map[time.Time][]event{}
+	dates := []time.Time{}
+	for _, e := range events {
+		d := date(e.Timestamp)
+		if _, ok := groups[d]; !ok {
+			dates = append(dates, d)
+		}
+		groups[d] = append(groups[d], e)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates, groups
+}
+
+func (r events) LevelStarts(f tracking.StatsFilter) ([]tracking.LevelStartsResult, error) {
+	defer r.lock()()
+	dates, groups := byDate(r.filter("game_start", f))
+	res := []tracking.LevelStartsResult{}
+	for _, d := range dates {
+		res = append(res, tracking.LevelStartsResult{GameStarts: len(groups[d]), Date: d})
+	}
+	return res, nil
+}
+
+func (r events) LevelCompletes(f tracking.StatsFilter) ([]tracking.LevelCompleteResult, error) {
+	defer r.lock()()
+	dates, groups := byDate(r.filter("game_finish", f))
+	res := []tracking.LevelCompleteResult{}
+	for _, d := range dates {
+		res = append(res, tracking.LevelCompleteResult{GameComplete: len(groups[d]), Date: d})
+	}
+	return res, nil
+}
+
+func (r events) AvgTime(f tracking.StatsFilter) ([]tracking.AvgTimeResult, error) {
+	defer r.lock()()
+	dates, groups := byDate(r.filter("game_finish", f))
+	res := []tracking.AvgTimeResult{}
+	for _, d := range dates {
+		// AVG skips NULL times, which are stored as 0
+		sum, n := 0, 0
+		for _, e := range groups[d] {
+			if e.Time != 0 {
+				sum += e.Time
+				n++
+			}
+		}
+		avg := 0.0
+		if n > 0 {
+			avg = float64(sum) / float64(n)
+		}
+		res = append(res, tracking.AvgTimeResult{AvgTime: avg, Date: d})
+	}
+	return res, nil
+}
+
+func (r events) UniqueUsers(f tracking.StatsFilter) ([]tracking.UniqueUsersResult, error) {
+	defer r.lock()()
+	months := map[int]map[int]bool{}
+	for _, e := range r.filter("game_finish", f) {
+		m := int(e.Timestamp.Month())
+		if months[m] == nil {
+			months[m] = map[int]bool{}
+		}
+		if e.Uid != 0 {
+			months[m][e.Uid] = true
+		}
+	}
+	res := []tracking.UniqueUsersResult{}
+	for m := 1; m <= 12; m++ {
+		if users, ok := months[m]; ok {
+			res = append(res, tracking.UniqueUsersResult{UniqueUsers: len(users), Month: m})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,173 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+// A row of the level_search view
+type searchRow struct {
+	models.DBLevel
+	difficulty float64
+	plays      int
+	avgStars   float64
+	score      float64
+	// ts_rank of the text query
+	rank float64
+}
+
+func inInts(vals []int, v int) bool {
+	for _, x := range vals {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the rows of level_search that match
+// the text query and filters, sorted by id. Like
+// the search mode probe, the query falls back to
+// similar names when no filtered row matches it.
+func (d *data) searchLevels(query string, f models.LevelFilter) []searchRow {
+	difficulties := d.difficulties()
+	filtered := []searchRow{}
+	docs := []doc{}
+	for _, id := range sortedIds(d.levels) {
+		row := searchRow{DBLevel: d.levels[id], difficulty: difficulties[id].Value, plays: difficulties[id].Starts}
+		rating := d.rating(id)
+		if rating.Ratings > 0 {
+			row.avgStars, row.score = rating.Average, rating.Score
+		}
+		switch {
+		case !d.public(id),
+			f.MinDifficulty != 0 && row.difficulty < f.MinDifficulty,
+			f.MaxDifficulty != 0 && row.difficulty > f.MaxDifficulty,
+			!d.hasTags(id, f.Tags),
+			len(f.Themes) > 0 && !inInts(f.Themes, row.Theme),
+			len(f.Cars) > 0 && !inInts(f.Cars, row.Car),
+			len(f.Soundtracks) > 0 && !inInts(f.Soundtracks, row.Soundtrack),
+			f.Creator != 0 && row.Uid != f.Creator,
+			!f.CreatedFrom.IsZero() && row.Created.Before(f.CreatedFrom),
+			!f.CreatedTo.IsZero() && row.Created.After(f.CreatedTo),
+			// Comparisons with NULL are never true
+			!f.UpdatedFrom.IsZero() && (!row.Updated.Valid || row.Updated.Time.Before(f.UpdatedFrom)),
+			!f.UpdatedTo.IsZero() && (!row.Updated.Valid || row.Updated.Time.After(f.UpdatedTo)),
+			f.MinPlays != 0 && row.plays < f.MinPlays,
+			f.MinRating != 0 && row.avgStars < f.MinRating:
+			continue
+		}
+		filtered = append(filtered, row)
+		docs = append(docs, doc{id, row.Name, []string{row.Name, row.Description}})
+	}
+	if query == "" {
+		return filtered
+	}
+	ranks := map[int]float64{}
+	for _, h := range search(query, docs) {
+		ranks[h.id] = h.rank
+	}
+	res := []searchRow{}
+	for _, row := range filtered {
+		if rank, ok := ranks[row.Id]; ok {
+			row.rank = rank
+			res = append(res, row)
+		}
+	}
+	return res
+}
+
+func (d *data) levelDocs() []doc {
+	docs := []doc{}
+	for _, id := range sortedIds(d.levels) {
+		l := d.levels[id]
+		if !d.public(id) {
+			continue
+		}
+		docs = append(docs, doc{id, l.Name, []string{l.Name, l.Description}})
+	}
+	return docs
+}
+
+func (r levels) Suggest(prefix string, limit int) ([]models.Suggestion, error) {
+	defer r.lock()()
+	return suggest(prefix, r.data.levelDocs(), limit), nil
+}
+
+// The value a search sorts the row by
+func (row searchRow) sortKey(sort string) (float64, error) {
+	switch sort {
+	case models.SortRelevance:
+		return row.rank, nil
+	case models.SortNewest:
+		return float64(row.Id), nil
+	case models.SortMostPlayed:
+		return float64(row.plays), nil
+	case models.SortTopRated:
+		return row.score, nil
+	}
+	return 0, models.ErrInvalidSort
+}
+
+func (r levels) Query(query, lang string, f models.LevelFilter, sort string, p models.Page) ([]models.Level, string, error) {
+	defer r.lock()()
+	hits := []hit{}
+	for _, row := range r.data.searchLevels(query, f) {
+		key, err := row.sortKey(sort)
+		if err != nil {
+			return nil, "", err
+		}
+		hits = append(hits, hit{row.Id, key})
+	}
+	hits, next := models.Paginate(pageHits(hits, p), p, hit.cursor)
+	res := []models.Level{}
+	for _, h := range hits {
+		res = append(res, toLevel(r.data.levels[h.id]))
+	}
+	return res, next, nil
+}
+
+// The value of the facet for the row, false
+// if the row isn't counted (NULL)
+func (row searchRow) facet(facet string) (int, bool, error) {
+	switch facet {
+	case models.FacetTheme:
+		return row.Theme, true, nil
+	case models.FacetCar:
+		return row.Car, row.Car != 0, nil
+	case models.FacetSoundtrack:
+		return row.Soundtrack, row.Soundtrack != 0, nil
+	case models.FacetDifficulty:
+		// Like ROUND, halves round away from zero
+		return int(math.Round(row.difficulty)), true, nil
+	}
+	return 0, false, fmt.Errorf("unknown facet %q", facet)
+}
+
+func (r levels) Facet(query, lang string, f models.LevelFilter, facet string) ([]models.FacetCount, error) {
+	defer r.lock()()
+	counts := map[int]int{}
+	for _, row := range r.data.searchLevels(query, f) {
+		v, ok, err := row.facet(facet)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			counts[v]++
+		}
+	}
+	res := make([]models.FacetCount, 0, len(counts))
+	for v, n := range counts {
+		res = append(res, models.FacetCount{Value: v, Count: n})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Value < res[j].Value
+	})
+	return res, nil
+}
@@ -0,0 +1,413 @@
+package memory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type levels struct {
+	*Store
+}
+
+func toLevel(row models.DBLevel) models.Level {
+	l := models.Level{}
+	row.ToLevel(&l)
+	return l
+}
+
+// Rows don't share pointers with the models they
+// are made from
+func copyInt(n *int) *int {
+	if n == nil {
+		return nil
+	}
+	v := *n
+	return &v
+}
+
+// Empty strings are stored as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func (r levels) Create(l *models.Level) (int, error) {
+	defer r.lock()()
+	if _, ok := r.data.users[l.Uid]; !ok {
+		return 0, fkError("levels", "levels_uid_fkey")
+	}
+	row := models.DBLevel{
+		Id:          r.data.nextId("levels"),
+		Difficulty:  l.Difficulty,
+		Name:        l.Name,
+		Description: l.Description,
+		Uid:         l.Uid,
+		Created:     time.Now(),
+		Theme:       l.Theme,
+		Car:         l.Car,
+		Soundtrack:  l.Soundtrack,
+		Language:    l.Language,
+		Visibility:  l.Visibility,
+		ShareToken:  nullString(l.ShareToken),
+		Revision:    1,
+	}
+	if l.SourceLevelId != nil {
+		if _, ok := r.data.levels[*l.SourceLevelId]; !ok {
+			return 0, fkError("levels", "levels_source_level_id_fkey")
+		}
+		row.SourceLevelId, row.SourceVersion = copyInt(l.SourceLevelId), copyInt(l.SourceVersion)
+	}
+	r.data.levels[row.Id] = row
+	return row.Id, nil
+}
+
+func (r levels) Get(id int) (models.Level, error) {
+	defer r.lock()()
+	row, ok := r.data.levels[id]
+	cd, hasData := r.data.courseData[id]
+	if !ok || !hasData {
+		return models.Level{}, sql.ErrNoRows
+	}
+	row.CourseData = cd
+	return toLevel(row), nil
+}
+
+func (r levels) GetInfo(id int) (models.Level, error) {
+	defer r.lock()()
+	row, ok := r.data.levels[id]
+	if !ok {
+		return models.Level{}, sql.ErrNoRows
+	}
+	return toLevel(row), nil
+}
+
+func (r levels) GetByShareToken(token string) (models.Level, error) {
+	defer r.lock()()
+	for id, row := range r.data.levels {
+		cd, hasData := r.data.courseData[id]
+		if row.ShareToken.String == token && row.Visibility == models.VisibilityUnlisted && hasData {
+			row.CourseData = cd
+			return toLevel(row), nil
+		}
+	}
+	return models.Level{}, sql.ErrNoRows
+}
+
+// Levels listed in searches and the trending
+// and top rated views
+func (d *data) public(id int) bool {
+	return d.levels[id].Visibility == models.VisibilityPublic
+}
+
+func (r levels) GetByUser(uid int, all bool, p models.Page) ([]models.Level, string, error) {
+	defer r.lock()()
+	ids := []int{}
+	for _, id := range sortedIds(r.data.levels) {
+		if r.data.levels[id].Uid == uid && (all || r.data.public(id)) {
+			ids = append(ids, id)
+		}
+	}
+	res := []models.Level{}
+	for _, id := range newestFirst(ids, p) {
+		res = append(res, toLevel(r.data.levels[id]))
+	}
+	res, next := models.Paginate(res, p, func(l models.Level) models.Cursor { return models.Cursor{Id: l.Id} })
+	return res, next, nil
+}
+
+func (r levels) Update(l *models.Level) error {
+	defer r.lock()()
+	row, ok := r.data.levels[l.Id]
+	if !ok || row.Uid != l.Uid || (l.Revision != 0 && row.Revision != l.Revision) {
+		return sql.ErrNoRows
+	}
+	row.Revision++
+	l.Revision = row.Revision
+	row.Updated = sql.NullTime{Time: time.Now(), Valid: true}
+	if l.Name != "" {
+		row.Name = l.Name
+	}
+	if l.Difficulty != 0 {
+		row.Difficulty = l.Difficulty
+	}
+	if l.Description != "" {
+		row.Description = l.Description
+	}
+	if l.Theme != 0 {
+		row.Theme = l.Theme
+	}
+	if l.Car != 0 {
+		row.Car = l.Car
+	}
+	if l.Soundtrack != 0 {
+		row.Soundtrack = l.Soundtrack
+	}
+	if l.Language != "" {
+		row.Language = l.Language
+	}
+	if l.Visibility != "" {
+		row.Visibility = l.Visibility
+		if l.Visibility != models.VisibilityUnlisted {
+			row.ShareToken = sql.NullString{}
+		} else if !row.ShareToken.Valid {
+			row.ShareToken = nullString(l.ShareToken)
+		}
+	}
+	r.data.levels[l.Id] = row
+	return nil
+}
+
+func (r levels) Delete(id, uid int) error {
+	defer r.lock()()
+	row, ok := r.data.levels[id]
+	if !ok || row.Uid != uid {
+		return sql.ErrNoRows
+	}
+	delete(r.data.levels, id)
+	// ON DELETE CASCADE
+	delete(r.data.courseData, id)
+	for did, d := range r.data.drafts {
+		if int(d.LevelId.Int32) == id {
+			r.data.deleteDraft(did)
+		}
+	}
+	for cid, cl := range r.data.collectionLevels {
+		if cl.LevelId == id {
+			delete(r.data.collectionLevels, cid)
+		}
+	}
+	for k := range r.data.votes {
+		if k.levelId == id {
+			delete(r.data.votes, k)
+		}
+	}
+	for k := range r.data.difficultyVotes {
+		if k.levelId == id {
+			delete(r.data.difficultyVotes, k)
+		}
+	}
+	for k := range r.data.levelTags {
+		if k.levelId == id {
+			delete(r.data.levelTags, k)
+		}
+	}
+	for vid, v := range r.data.levelVersions {
+		if v.LevelId == id {
+			delete(r.data.levelVersions, vid)
+		}
+	}
+	for eid, e := range r.data.events {
+		if e.LevelId == id {
+			delete(r.data.events, eid)
+		}
+	}
+	// ON DELETE SET NULL
+	for lid, l := range r.data.levels {
+		if l.SourceLevelId != nil && *l.SourceLevelId == id {
+			l.SourceLevelId = nil
+			r.data.levels[lid] = l
+		}
+	}
+	for did, d := range r.data.drafts {
+		if d.SourceLevelId != nil && *d.SourceLevelId == id {
+			d.SourceLevelId = nil
+			r.data.drafts[did] = d
+		}
+	}
+	for cid, c := range r.data.comments {
+		if c.LevelId != nil && *c.LevelId == id {
+			delete(r.data.comments, cid)
+		}
+	}
+	return nil
+}
+
+func (r levels) Owner(id int) (int, error) {
+	defer r.lock()()
+	row, ok := r.data.levels[id]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return row.Uid, nil
+}
+
+func (r levels) remixIds(id int) []int {
+	ids := []int{}
+	for _, lid := range sortedIds(r.data.levels) {
+		l := r.data.levels[lid]
+		if l.SourceLevelId != nil && *l.SourceLevelId == id && r.data.public(lid) {
+			ids = append(ids, lid)
+		}
+	}
+	return ids
+}
+
+func (r levels) Remixes(id int, p models.Page) ([]models.Level, string, error) {
+	defer r.lock()()
+	res := []models.Level{}
+	for _, lid := range newestFirst(r.remixIds(id), p) {
+		res = append(res, toLevel(r.data.levels[lid]))
+	}
+	res, next := models.Paginate(res, p, func(l models.Level) models.Cursor { return models.Cursor{Id: l.Id} })
+	return res, next, nil
+}
+
+func (r levels) RemixCount(id int) (int, error) {
+	defer r.lock()()
+	return len(r.remixIds(id)), nil
+}
+
+func (r levels) SetShareToken(id int, token string) error {
+	defer r.lock()()
+	row, ok := r.data.levels[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	row.ShareToken = nullString(token)
+	r.data.levels[id] = row
+	return nil
+}
+
+// Number of game_start events of each level,
+// like the plays column of trending_levels
+func (d *data) plays() map[int]int {
+	plays := map[int]int{}
+	for _, e := range d.events {
+		if e.EventType == "game_start" && e.LevelId != 0 {
+			plays[e.LevelId]++
+		}
+	}
+	return plays
+}
+
+func (r levels) Trending() ([]models.Level, error) {
+	defer r.lock()()
+	plays := r.data.plays()
+	ids := []int{}
+	for _, id := range sortedIds(r.data.levels) {
+		if plays[id] > 0 && r.data.public(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return plays[ids[i]] > plays[ids[j]] })
+	res := make([]models.Level, 0, len(ids))
+	for _, id := range ids {
+		row := r.data.levels[id]
+		// The view query only selects these columns
+		res = append(res, models.Level{
+			Id:          row.Id,
+			Difficulty:  row.Difficulty,
+			Name:        row.Name,
+			Description: row.Description,
+			Uid:         row.Uid,
+			Created:     row.Created,
+			Updated:     row.Updated,
+			Theme:       row.Theme,
+			Visibility:  row.Visibility,
+		})
+	}
+	return res, nil
+}
+
+func (r levels) TopRated() ([]models.Level, error) {
+	defer r.lock()()
+	ratings := map[int]models.LevelRating{}
+	ids := []int{}
+	for _, id := range sortedIds(r.data.levels) {
+		if rating := r.data.rating(id); rating.Ratings > 0 && r.data.public(id) {
+			ratings[id] = rating
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := ratings[ids[i]].Score, ratings[ids[j]].Score
+		if a != b {
+			return a > b
+		}
+		return ids[i] > ids[j]
+	})
+	res := make([]models.Level, 0, len(ids))
+	for _, id := range ids {
+		l := toLevel(r.data.levels[id])
+		rating := ratings[id]
+		l.Rating = &rating
+		res = append(res, l)
+	}
+	return res, nil
+}
+
+func (r levels) Leaderboard(id int, p models.Page) ([]models.Leaderboard, string, error) {
+	defer r.lock()()
+	res := []models.Leaderboard{}
+	for _, eid := range sortedIds(r.data.events) {
+		e := r.data.events[eid]
+		u, ok := r.data.users[e.Uid]
+		if e.EventType != "game_finish" || e.LevelId != id || e.Time <= 0 || !ok {
+			continue
+		}
+		if a := p.After; a != nil && (e.Time < a.Time || (e.Time == a.Time && e.Id <= a.Id)) {
+			continue
+		}
+		res = append(res, models.Leaderboard{
+			LevelId:   e.LevelId,
+			Uid:       e.Uid,
+			Time:      e.Time,
+			Timestamp: e.Timestamp,
+			Name:      u.Name,
+			Id:        e.Id,
+		})
+	}
+	// Events are already sorted by id
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Time < res[j].Time })
+	if len(res) > p.Fetch() {
+		res = res[:p.Fetch()]
+	}
+	res, next := models.Paginate(res, p, func(l models.Leaderboard) models.Cursor {
+		return models.Cursor{Id: l.Id, Time: l.Time}
+	})
+	return res, next, nil
+}
+
+func (r levels) CreateCourseData(c *models.CourseData) error {
+	defer r.lock()()
+	if _, ok := r.data.levels[c.LevelId]; !ok {
+		return fkError("course_data", "course_data_level_id_fkey")
+	}
+	if _, ok := r.data.courseData[c.LevelId]; ok {
+		return uniqueError("course_data_level_id_key")
+	}
+	cd, err := json.Marshal(c.MapData)
+	if err != nil {
+		return err
+	}
+	r.data.courseData[c.LevelId] = cd
+	return nil
+}
+
+func (r levels) UpdateCourseData(c *models.CourseData) error {
+	defer r.lock()()
+	if _, ok := r.data.courseData[c.LevelId]; !ok {
+		return nil
+	}
+	cd, err := json.Marshal(c.MapData)
+	if err != nil {
+		return err
+	}
+	r.data.courseData[c.LevelId] = cd
+	// course_data_update_trigger
+	for id, d := range r.data.drafts {
+		if int(d.LevelId.Int32) == c.LevelId {
+			r.data.deleteDraft(id)
+		}
+	}
+	return nil
+}
+
+func (r levels) DeleteCourseData(levelId int) error {
+	defer r.lock()()
+	delete(r.data.courseData, levelId)
+	return nil
+}
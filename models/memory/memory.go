@@ -0,0 +1,204 @@
+// Package memory implements models.Store in memory, so
+// the models and routes can be tested without Postgres.
+// It mimics the constraints, cascades and triggers of
+// the database schema closely enough for tests, but it
+// doesn't try to reproduce Postgres full text search.
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sofferjacob/maker_api/models"
+	"github.com/sofferjacob/maker_api/tracking"
+)
+
+type userToken struct {
+	Id      int
+	Uid     int
+	Purpose string
+	Hash    string
+	Expires time.Time
+	Used    bool
+}
+
+type event struct {
+	tracking.Event
+	body json.RawMessage
+}
+
+// The tables of the store. Rows are stored by value
+// and JSON columns as encoded bytes, so a shallow copy
+// of the maps is enough to snapshot the whole store.
+type data struct {
+	seq              map[string]int
+	users            map[int]models.User
+	sessions         map[int]models.Session
+	userTokens       map[int]userToken
+	levels           map[int]models.DBLevel
+	courseData       map[int]json.RawMessage
+	drafts           map[int]models.DbDraft
+	collections      map[int]models.Collection
+	collectionLevels map[int]models.CollectionLevels
+	events           map[int]event
+	votes            map[voteKey]models.LevelVote
+	comments         map[int]models.Comment
+	difficultyVotes  map[voteKey]models.DifficultyVote
+	tags             map[int]models.Tag
+	levelTags        map[levelTag]bool
+	levelVersions    map[int]levelVersion
+	checkpoints      map[int]checkpoint
+}
+
+func newData() *data {
+	return &data{
+		seq:              map[string]int{},
+		users:            map[int]models.User{},
+		sessions:         map[int]models.Session{},
+		userTokens:       map[int]userToken{},
+		levels:           map[int]models.DBLevel{},
+		courseData:       map[int]json.RawMessage{},
+		drafts:           map[int]models.DbDraft{},
+		collections:      map[int]models.Collection{},
+		collectionLevels: map[int]models.CollectionLevels{},
+		events:           map[int]event{},
+		votes:            map[voteKey]models.LevelVote{},
+		comments:         map[int]models.Comment{},
+		difficultyVotes:  map[voteKey]models.DifficultyVote{},
+		tags:             map[int]models.Tag{},
+		levelTags:        map[levelTag]bool{},
+		levelVersions:    map[int]levelVersion{},
+		checkpoints:      map[int]checkpoint{},
+	}
+}
+
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	res := make(map[K]V, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
+
+func (d *data) clone() data {
+	return data{
+		seq:              copyMap(d.seq),
+		users:            copyMap(d.users),
+		sessions:         copyMap(d.sessions),
+		userTokens:       copyMap(d.userTokens),
+		levels:           copyMap(d.levels),
+		courseData:       copyMap(d.courseData),
+		drafts:           copyMap(d.drafts),
+		collections:      copyMap(d.collections),
+		collectionLevels: copyMap(d.collectionLevels),
+		events:           copyMap(d.events),
+		votes:            copyMap(d.votes),
+		comments:         copyMap(d.comments),
+		difficultyVotes:  copyMap(d.difficultyVotes),
+		tags:             copyMap(d.tags),
+		levelTags:        copyMap(d.levelTags),
+		levelVersions:    copyMap(d.levelVersions),
+		checkpoints:      copyMap(d.checkpoints),
+	}
+}
+
+// Returns the next value of the table's serial id
+func (d *data) nextId(table string) int {
+	d.seq[table]++
+	return d.seq[table]
+}
+
+// An in-memory models.Store. The zero value is not
+// usable, create stores with New.
+type Store struct {
+	mu   *sync.Mutex
+	data *data
+	// Set on the store passed to Tx callbacks, which
+	// already hold the lock
+	inTx bool
+}
+
+func New() *Store {
+	return &Store{mu: &sync.Mutex{}, data: newData()}
+}
+
+// Locks the store and returns the function that
+// unlocks it. Does nothing inside a transaction.
+func (s *Store) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *Store) Users() models.UserRepository             { return users{s} }
+func (s *Store) Sessions() models.SessionRepository       { return sessions{s} }
+func (s *Store) UserTokens() models.UserTokenRepository   { return userTokens{s} }
+func (s *Store) Levels() models.LevelRepository           { return levels{s} }
+func (s *Store) Drafts() models.DraftRepository           { return drafts{s} }
+func (s *Store) Collections() models.CollectionRepository { return collections{s} }
+func (s *Store) Votes() models.VoteRepository             { return votes{s} }
+func (s *Store) Comments() models.CommentRepository       { return comments{s} }
+func (s *Store) Difficulty() models.DifficultyRepository  { return difficulty{s} }
+func (s *Store) Tags() models.TagRepository               { return tags{s} }
+func (s *Store) Versions() models.VersionRepository       { return versions{s} }
+func (s *Store) Checkpoints() models.CheckpointRepository { return checkpoints{s} }
+func (s *Store) Events() tracking.Repository              { return events{s} }
+
+// Transactions hold the store's lock until they finish,
+// so they are serializable. On error every table is
+// restored from a snapshot taken when the transaction
+// started.
+func (s *Store) Tx(fn func(s models.Store) error) (err error) {
+	if s.inTx {
+		return fn(s)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := s.data.clone()
+	defer func() {
+		if p := recover(); p != nil {
+			*s.data = snapshot
+			panic(p)
+		}
+		if err != nil {
+			*s.data = snapshot
+		}
+	}()
+	return fn(&Store{mu: s.mu, data: s.data, inTx: true})
+}
+
+func uniqueError(constraint string) error {
+	return fmt.Errorf("pq: duplicate key value violates unique constraint \"%v\"", constraint)
+}
+
+func fkError(table, constraint string) error {
+	return fmt.Errorf("pq: insert or update on table \"%v\" violates foreign key constraint \"%v\"", table, constraint)
+}
+
+// Returns the keys of m sorted in ascending
+// order, to iterate tables by id
+func sortedIds[V any](m map[int]V) []int {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// The ids of the page of a list sorted by id, newest
+// first, like the pg lists. ids must be ascending.
+func newestFirst(ids []int, p models.Page) []int {
+	res := []int{}
+	for i := len(ids) - 1; i >= 0 && len(res) < p.Fetch(); i-- {
+		if p.After == nil || ids[i] < p.After.Id {
+			res = append(res, ids[i])
+		}
+	}
+	return res
+}
@@ -0,0 +1,134 @@
+package memory_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/sofferjacob/maker_api/models"
+	"github.com/sofferjacob/maker_api/models/memory"
+	"github.com/sofferjacob/maker_api/tracking"
+)
+
+func newUser(t *testing.T, s *memory.Store) int {
+	t.Helper()
+	uid, err := s.Users().Create(&models.User{Email: "ana@example.com", Name: "Ana", Password: "x"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return uid
+}
+
+func TestTxRollback(t *testing.T) {
+	s := memory.New()
+	uid := newUser(t, s)
+	fail := errors.New("fail")
+	err := s.Tx(func(tx models.Store) error {
+		if _, err := tx.Levels().Create(&models.Level{Name: "a", Uid: uid}); err != nil {
+			return err
+		}
+		return fail
+	})
+	if err != fail {
+		t.Fatalf("expected tx error, got %v", err)
+	}
+	levels, _, err := s.Levels().GetByUser(uid, true, models.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 0 {
+		t.Fatalf("expected rollback, found %d levels", len(levels))
+	}
+}
+
+func TestDeleteLevelCascades(t *testing.T) {
+	s := memory.New()
+	models.SetStore(s)
+	uid := newUser(t, s)
+	l := models.Level{
+		Difficulty:  1,
+		Name:        "Level",
+		Description: "A level",
+		Uid:         uid,
+		Theme:       1,
+		Car:         1,
+		Soundtrack:  1,
+		CourseData:  map[string]interface{}{"blocks": []interface{}{}},
+	}
+	id, err := l.Create()
+	if err != nil {
+		t.Fatalf("create level: %v", err)
+	}
+	if _, err := models.GetLevelDraft(id, models.Viewer{Uid: uid}); err != nil {
+		t.Fatalf("create draft: %v", err)
+	}
+	liked := true
+	vote := models.LevelVote{LevelId: id, Uid: uid, Liked: &liked}
+	if err := vote.Save(models.Viewer{Uid: uid}); err != nil {
+		t.Fatalf("vote: %v", err)
+	}
+	if err := s.Events().Insert(&tracking.Event{EventType: "game_start", LevelId: id, Uid: uid}); err != nil {
+		t.Fatalf("event: %v", err)
+	}
+	if err := models.DeleteLevel(id, uid+1); err != sql.ErrNoRows {
+		t.Fatalf("expected ErrNoRows deleting someone else's level, got %v", err)
+	}
+	if err := models.DeleteLevel(id, uid); err != nil {
+		t.Fatalf("delete level: %v", err)
+	}
+	got := models.Level{Id: id}
+	if err := got.Get(models.Viewer{Uid: uid}); err != models.ErrLevelNotFound {
+		t.Fatalf("expected deleted level, got %v", err)
+	}
+	drafts, _, err := models.GetUserDrafts(uid, models.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drafts) != 0 {
+		t.Fatalf("expected drafts to be deleted with the level, found %d", len(drafts))
+	}
+	if _, err := s.Votes().Get(id, uid); err != sql.ErrNoRows {
+		t.Fatalf("expected votes to be deleted with the level, got %v", err)
+	}
+	starts, err := s.Events().LevelStarts(tracking.StatsFilter{LevelId: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 0 {
+		t.Fatalf("expected events to be deleted with the level, found %+v", starts)
+	}
+	if err := s.Events().Insert(&tracking.Event{EventType: "game_start", LevelId: id}); err == nil {
+		t.Fatal("expected an event of a deleted level to be rejected")
+	}
+}
+
+func TestPruneCheckpoints(t *testing.T) {
+	s := memory.New()
+	uid := newUser(t, s)
+	draftId, err := s.Drafts().Create(&models.Draft{Name: "Draft", Uid: uid, Car: 1, Soundtrack: 1})
+	if err != nil {
+		t.Fatalf("create draft: %v", err)
+	}
+	for _, name := range []string{"", "Named", "", ""} {
+		c := models.Checkpoint{DraftId: draftId, Name: name, CourseData: map[string]interface{}{}}
+		if _, err := s.Checkpoints().Create(&c); err != nil {
+			t.Fatalf("create checkpoint: %v", err)
+		}
+	}
+	if err := s.Checkpoints().Prune(draftId, 1); err != nil {
+		t.Fatal(err)
+	}
+	res, _, err := s.Checkpoints().List(draftId, models.Page{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].Id != 4 || res[1].Name != "Named" {
+		t.Fatalf("expected the newest unnamed and the named checkpoint, got %+v", res)
+	}
+	if err := s.Drafts().DeleteOwned(draftId, uid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Checkpoints().Latest(draftId); err != sql.ErrNoRows {
+		t.Fatalf("expected checkpoints to be deleted with the draft, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type hit struct {
+	id   int
+	rank float64
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// A word of a search. Negated words must not
+// appear in the row.
+type term struct {
+	word string
+	not  bool
+}
+
+// Stands in for websearch_to_tsquery: words are ANDed, "or"
+// separates alternatives and words starting with - are
+// excluded. Quotes are ignored, so phrases match their
+// words in any order.
+func parseQuery(query string) [][]term {
+	alts := [][]term{{}}
+	for _, tok := range strings.Fields(query) {
+		if strings.EqualFold(tok, "or") {
+			alts = append(alts, []term{})
+			continue
+		}
+		not := strings.HasPrefix(tok, "-")
+		for _, w := range words(tok) {
+			alts[len(alts)-1] = append(alts[len(alts)-1], term{w, not})
+		}
+	}
+	return alts
+}
+
+// The weight of the first field that has a word
+// starting with w, 0 if none does
+func weight(w string, fields []string) float64 {
+	for i, f := range fields {
+		for _, fw := range words(f) {
+			if strings.HasPrefix(fw, w) {
+				return 1 / float64(i+1)
+			}
+		}
+	}
+	return 0
+}
+
+// Stands in for matching the ts column with gin_query: a
+// word of the query hits a field if it is a prefix of one
+// of its words. Fields are given in decreasing weight (like
+// the A and B weights of the ts columns), and the rank adds
+// up the weight of the field each query word hit. The rank
+// of a query with alternatives is the best one's. Words
+// aren't stemmed, so the language of the query and of the
+// rows doesn't matter.
+func match(query string, fields ...string) (float64, bool) {
+	best, found := 0.0, false
+	for _, alt := range parseQuery(query) {
+		if len(alt) == 0 {
+			continue
+		}
+		rank, ok := 0.0, true
+		for _, t := range alt {
+			w := weight(t.word, fields)
+			if (w > 0) == t.not {
+				ok = false
+				break
+			}
+			rank += w
+		}
+		if ok && (!found || rank > best) {
+			best, found = rank, true
+		}
+	}
+	return best, found
+}
+
+// Stands in for the prefix_query autocomplete matches
+// names with: every word has to start a word of name
+func prefixMatch(prefix, name string) bool {
+	terms := words(prefix)
+	for _, t := range terms {
+		if weight(t, []string{name}) == 0 {
+			return false
+		}
+	}
+	return len(terms) > 0
+}
+
+// The trigrams of the words of s, padded like pg_trgm does
+func trigrams(s string) map[string]bool {
+	res := map[string]bool{}
+	for _, w := range words(s) {
+		r := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			res[string(r[i:i+3])] = true
+		}
+	}
+	return res
+}
+
+// Stands in for word_similarity(query, name): the share
+// of the trigrams of query that name has. Unlike pg_trgm
+// it doesn't require them to be in one extent of name.
+func wordSimilarity(query, name string) float64 {
+	q, n := trigrams(query), trigrams(name)
+	if len(q) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range q {
+		if n[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(q))
+}
+
+// pg_trgm.word_similarity_threshold, the similarity
+// of the <% operator
+const similarityThreshold = 0.6
+
+// A searchable row, with the fields of its
+// ts column in decreasing weight
+type doc struct {
+	id     int
+	name   string
+	fields []string
+}
+
+// Stands in for the searches of pg_store: rows matching the
+// query, or rows with similar names if none matches it
+func search(query string, docs []doc) []hit {
+	hits := []hit{}
+	for _, d := range docs {
+		if rank, ok := match(query, d.fields...); ok {
+			hits = append(hits, hit{d.id, rank})
+		}
+	}
+	if len(hits) > 0 {
+		return hits
+	}
+	for _, d := range docs {
+		if sim := wordSimilarity(query, d.name); sim >= similarityThreshold {
+			hits = append(hits, hit{d.id, sim})
+		}
+	}
+	return hits
+}
+
+// Stands in for suggestQuery: names starting like prefix
+// and then similar ones, best first
+func suggest(prefix string, docs []doc, limit int) []models.Suggestion {
+	hits := []hit{}
+	for _, d := range docs {
+		rank := wordSimilarity(prefix, d.name)
+		if prefixMatch(prefix, d.name) {
+			rank++
+		} else if rank < similarityThreshold {
+			continue
+		}
+		hits = append(hits, hit{d.id, rank})
+	}
+	names := map[int]string{}
+	for _, d := range docs {
+		names[d.id] = d.name
+	}
+	hits = pageHits(hits, models.Page{Limit: limit})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	res := []models.Suggestion{}
+	for _, h := range hits {
+		res = append(res, models.Suggestion{Id: h.id, Name: names[h.id]})
+	}
+	return res
+}
+
+// Sorts hits by rank and id, highest first, and
+// returns the ones in the page
+func pageHits(hits []hit, p models.Page) []hit {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].rank != hits[j].rank {
+			return hits[i].rank > hits[j].rank
+		}
+		return hits[i].id > hits[j].id
+	})
+	res := []hit{}
+	for _, h := range hits {
+		if len(res) == p.Fetch() {
+			break
+		}
+		a := p.After
+		if a == nil || h.rank < a.Rank || (h.rank == a.Rank && h.id < a.Id) {
+			res = append(res, h)
+		}
+	}
+	return res
+}
+
+func (h hit) cursor() models.Cursor {
+	return models.Cursor{Id: h.id, Rank: h.rank}
+}
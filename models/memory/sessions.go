@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type sessions struct {
+	*Store
+}
+
+func (r sessions) Create(uid int, refreshHash string, ttl time.Duration) (int, error) {
+	defer r.lock()()
+	if _, ok := r.data.users[uid]; !ok {
+		return 0, fkError("sessions", "sessions_uid_fkey")
+	}
+	for _, v := range r.data.sessions {
+		if v.RefreshHash == refreshHash {
+			return 0, uniqueError("sessions_refresh_hash_key")
+		}
+	}
+	now := time.Now()
+	s := models.Session{
+		Id:          r.data.nextId("sessions"),
+		Uid:         uid,
+		RefreshHash: refreshHash,
+		Created:     now,
+		Expires:     now.Add(ttl),
+	}
+	r.data.sessions[s.Id] = s
+	return s.Id, nil
+}
+
+func active(s models.Session) bool {
+	return !s.Revoked.Valid && s.Expires.After(time.Now())
+}
+
+func (r sessions) Rotate(refreshHash, nextHash string, ttl time.Duration) (models.Session, error) {
+	defer r.lock()()
+	for id, s := range r.data.sessions {
+		if s.RefreshHash != refreshHash || !active(s) {
+			continue
+		}
+		now := time.Now()
+		s.PreviousHash = sql.NullString{String: s.RefreshHash, Valid: true}
+		s.RefreshHash = nextHash
+		s.Refreshed = sql.NullTime{Time: now, Valid: true}
+		s.Expires = now.Add(ttl)
+		r.data.sessions[id] = s
+		return s, nil
+	}
+	return models.Session{}, sql.ErrNoRows
+}
+
+// Revokes the active sessions that match
+func (r sessions) revoke(match func(s models.Session) bool) {
+	defer r.lock()()
+	for id, s := range r.data.sessions {
+		if !s.Revoked.Valid && match(s) {
+			s.Revoked = sql.NullTime{Time: time.Now(), Valid: true}
+			r.data.sessions[id] = s
+		}
+	}
+}
+
+func (r sessions) RevokeRotated(previousHash string) error {
+	r.revoke(func(s models.Session) bool { return s.PreviousHash.String == previousHash })
+	return nil
+}
+
+func (r sessions) Revoke(id, uid int) error {
+	r.revoke(func(s models.Session) bool { return s.Id == id && s.Uid == uid })
+	return nil
+}
+
+func (r sessions) RevokeAll(uid int) error {
+	r.revoke(func(s models.Session) bool { return s.Uid == uid })
+	return nil
+}
+
+func (r sessions) IsActive(id int) (bool, error) {
+	defer r.lock()()
+	s, ok := r.data.sessions[id]
+	return ok && active(s), nil
+}
+
+type userTokens struct {
+	*Store
+}
+
+func (r userTokens) Create(uid int, purpose, hash string, ttl time.Duration) error {
+	defer r.lock()()
+	if _, ok := r.data.users[uid]; !ok {
+		return fkError("user_tokens", "user_tokens_uid_fkey")
+	}
+	for _, v := range r.data.userTokens {
+		if v.Hash == hash {
+			return uniqueError("user_tokens_token_hash_key")
+		}
+	}
+	t := userToken{
+		Id:      r.data.nextId("user_tokens"),
+		Uid:     uid,
+		Purpose: purpose,
+		Hash:    hash,
+		Expires: time.Now().Add(ttl),
+	}
+	r.data.userTokens[t.Id] = t
+	return nil
+}
+
+func (r userTokens) Invalidate(uid int, purpose string) error {
+	defer r.lock()()
+	for id, t := range r.data.userTokens {
+		if t.Uid == uid && t.Purpose == purpose {
+			t.Used = true
+			r.data.userTokens[id] = t
+		}
+	}
+	return nil
+}
+
+func (r userTokens) Consume(hash, purpose string) (int, error) {
+	defer r.lock()()
+	for id, t := range r.data.userTokens {
+		if t.Hash == hash && t.Purpose == purpose && !t.Used && t.Expires.After(time.Now()) {
+			t.Used = true
+			r.data.userTokens[id] = t
+			return t.Uid, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
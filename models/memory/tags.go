@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type levelTag struct {
+	levelId int
+	tagId   int
+}
+
+type tags struct {
+	*Store
+}
+
+// Returns the id of the tag named name, creating
+// the tag if it doesn't exist
+func (d *data) tagId(name string) int {
+	for id, t := range d.tags {
+		if t.Name == name {
+			return id
+		}
+	}
+	t := models.Tag{Id: d.nextId("tags"), Name: name, Created: time.Now()}
+	d.tags[t.Id] = t
+	return t.Id
+}
+
+func (r tags) SetLevelTags(levelId int, names []string) error {
+	defer r.lock()()
+	if _, ok := r.data.levels[levelId]; !ok && len(names) > 0 {
+		return fkError("level_tags", "level_tags_level_id_fkey")
+	}
+	for k := range r.data.levelTags {
+		if k.levelId == levelId {
+			delete(r.data.levelTags, k)
+		}
+	}
+	for _, name := range names {
+		r.data.levelTags[levelTag{levelId, r.data.tagId(name)}] = true
+	}
+	return nil
+}
+
+func (r tags) LevelTags(levelId int) ([]string, error) {
+	defer r.lock()()
+	res := []string{}
+	for k := range r.data.levelTags {
+		if k.levelId == levelId {
+			res = append(res, r.data.tags[k.tagId].Name)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func (r tags) Levels(tag string, p models.Page) ([]models.Level, string, error) {
+	defer r.lock()()
+	ids := []int{}
+	for k := range r.data.levelTags {
+		if r.data.tags[k.tagId].Name == tag && r.data.public(k.levelId) {
+			ids = append(ids, k.levelId)
+		}
+	}
+	sort.Ints(ids)
+	res := []models.Level{}
+	for _, id := range newestFirst(ids, p) {
+		res = append(res, toLevel(r.data.levels[id]))
+	}
+	res, next := models.Paginate(res, p, func(l models.Level) models.Cursor { return models.Cursor{Id: l.Id} })
+	return res, next, nil
+}
+
+// Like the tag_counts view
+func (d *data) tagCounts() []models.Tag {
+	counts := map[int]int{}
+	for k := range d.levelTags {
+		if d.public(k.levelId) {
+			counts[k.tagId]++
+		}
+	}
+	res := make([]models.Tag, 0, len(d.tags))
+	for _, id := range sortedIds(d.tags) {
+		t := d.tags[id]
+		t.Levels = counts[id]
+		res = append(res, t)
+	}
+	return res
+}
+
+func (r tags) Search(prefix string, curatedOnly bool, limit int) ([]models.Tag, error) {
+	defer r.lock()()
+	res := []models.Tag{}
+	for _, t := range r.data.tagCounts() {
+		if (curatedOnly && !t.Curated) || (!t.Curated && t.Levels == 0) || !strings.HasPrefix(t.Name, prefix) {
+			continue
+		}
+		res = append(res, t)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Levels != res[j].Levels {
+			return res[i].Levels > res[j].Levels
+		}
+		return res[i].Name < res[j].Name
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (r tags) SetCurated(tag string, curated bool) error {
+	defer r.lock()()
+	id := r.data.tagId(tag)
+	t := r.data.tags[id]
+	t.Curated = curated
+	r.data.tags[id] = t
+	return nil
+}
+
+// Whether the level has every tag
+func (d *data) hasTags(levelId int, names []string) bool {
+	for _, name := range names {
+		found := false
+		for k := range d.levelTags {
+			if k.levelId == levelId && d.tags[k.tagId].Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type users struct {
+	*Store
+}
+
+func (r users) Create(u *models.User) (int, error) {
+	defer r.lock()()
+	for _, v := range r.data.users {
+		if v.Email == u.Email {
+			return 0, uniqueError("users_email_key")
+		}
+	}
+	row := models.User{
+		Id:       r.data.nextId("users"),
+		Email:    u.Email,
+		Name:     u.Name,
+		Password: u.Password,
+		Joined:   time.Now(),
+		Role:     models.RoleUser,
+		Language: u.Language,
+	}
+	r.data.users[row.Id] = row
+	return row.Id, nil
+}
+
+func (r users) Get(id int) (models.User, error) {
+	defer r.lock()()
+	u, ok := r.data.users[id]
+	if !ok {
+		return models.User{}, sql.ErrNoRows
+	}
+	return u, nil
+}
+
+func (r users) GetByEmail(email string) (models.User, error) {
+	defer r.lock()()
+	for _, u := range r.data.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return models.User{}, sql.ErrNoRows
+}
+
+func (r users) Update(u *models.User) error {
+	defer r.lock()()
+	row, ok := r.data.users[u.Id]
+	if !ok {
+		return nil
+	}
+	if u.Email != "" {
+		for id, v := range r.data.users {
+			if v.Email == u.Email && id != u.Id {
+				return uniqueError("users_email_key")
+			}
+		}
+		row.Email = u.Email
+		row.Verified = false
+	}
+	if u.Name != "" {
+		row.Name = u.Name
+	}
+	if u.Language != "" {
+		row.Language = u.Language
+	}
+	r.data.users[u.Id] = row
+	return nil
+}
+
+// Applies fn to the user, if it exists
+func (r users) update(id int, fn func(u *models.User)) bool {
+	defer r.lock()()
+	u, ok := r.data.users[id]
+	if !ok {
+		return false
+	}
+	fn(&u)
+	r.data.users[id] = u
+	return true
+}
+
+func (r users) SetLastLogin(id int, t time.Time) error {
+	r.update(id, func(u *models.User) { u.LastLogin = sql.NullTime{Time: t, Valid: true} })
+	return nil
+}
+
+func (r users) SetPassword(id int, hash string) error {
+	r.update(id, func(u *models.User) { u.Password = hash })
+	return nil
+}
+
+func (r users) SetVerified(id int) error {
+	r.update(id, func(u *models.User) { u.Verified = true })
+	return nil
+}
+
+func (r users) SetRole(id int, role string) error {
+	if !r.update(id, func(u *models.User) { u.Role = role }) {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r users) Query(query, lang string, p models.Page) ([]models.User, string, error) {
+	defer r.lock()()
+	hits := search(query, r.data.userDocs())
+	hits, next := models.Paginate(pageHits(hits, p), p, hit.cursor)
+	res := []models.User{}
+	for _, h := range hits {
+		res = append(res, r.data.users[h.id])
+	}
+	return res, next, nil
+}
+
+func (d *data) userDocs() []doc {
+	docs := []doc{}
+	for _, id := range sortedIds(d.users) {
+		u := d.users[id]
+		docs = append(docs, doc{id, u.Name, []string{u.Name}})
+	}
+	return docs
+}
+
+func (r users) Suggest(prefix string, limit int) ([]models.Suggestion, error) {
+	defer r.lock()()
+	return suggest(prefix, r.data.userDocs(), limit), nil
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type versions struct {
+	*Store
+}
+
+type levelVersion struct {
+	models.LevelVersion
+	mapData json.RawMessage
+}
+
+func (r versions) Create(v *models.LevelVersion) (int, error) {
+	defer r.lock()()
+	if _, ok := r.data.levels[v.LevelId]; !ok {
+		return 0, fkError("level_versions", "level_versions_level_id_fkey")
+	}
+	cd, err := json.Marshal(v.CourseData)
+	if err != nil {
+		return 0, err
+	}
+	row := levelVersion{LevelVersion: *v, mapData: cd}
+	row.CourseData = nil
+	if v.RestoredFrom != nil {
+		from := *v.RestoredFrom
+		row.RestoredFrom = &from
+	}
+	row.Created = time.Now()
+	row.Version = 1
+	for _, lv := range r.data.levelVersions {
+		if lv.LevelId == v.LevelId && lv.Version >= row.Version {
+			row.Version = lv.Version + 1
+		}
+	}
+	r.data.levelVersions[r.data.nextId("level_versions")] = row
+	return row.Version, nil
+}
+
+func (r versions) Get(levelId, version int) (models.LevelVersion, error) {
+	defer r.lock()()
+	for _, lv := range r.data.levelVersions {
+		if lv.LevelId == levelId && lv.Version == version {
+			v := lv.LevelVersion
+			err := json.Unmarshal(lv.mapData, &v.CourseData)
+			return v, err
+		}
+	}
+	return models.LevelVersion{}, sql.ErrNoRows
+}
+
+func (r versions) Latest(levelId int) (int, error) {
+	defer r.lock()()
+	latest := 0
+	for _, lv := range r.data.levelVersions {
+		if lv.LevelId == levelId && lv.Version > latest {
+			latest = lv.Version
+		}
+	}
+	if latest == 0 {
+		return 0, sql.ErrNoRows
+	}
+	return latest, nil
+}
+
+func (r versions) List(levelId int, p models.Page) ([]models.LevelVersion, string, error) {
+	defer r.lock()()
+	byVersion := map[int]models.LevelVersion{}
+	for _, lv := range r.data.levelVersions {
+		if lv.LevelId == levelId {
+			byVersion[lv.Version] = lv.LevelVersion
+		}
+	}
+	res := []models.LevelVersion{}
+	for _, v := range newestFirst(sortedIds(byVersion), p) {
+		res = append(res, byVersion[v])
+	}
+	res, next := models.Paginate(res, p, func(v models.LevelVersion) models.Cursor { return models.Cursor{Id: v.Version} })
+	return res, next, nil
+}
@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type voteKey struct {
+	levelId int
+	uid     int
+}
+
+type votes struct {
+	*Store
+}
+
+func (r votes) Set(v *models.LevelVote) error {
+	defer r.lock()()
+	if _, ok := r.data.levels[v.LevelId]; !ok {
+		return fkError("level_votes", "level_votes_level_id_fkey")
+	}
+	if _, ok := r.data.users[v.Uid]; !ok {
+		return fkError("level_votes", "level_votes_uid_fkey")
+	}
+	k := voteKey{v.LevelId, v.Uid}
+	now := time.Now()
+	row, ok := r.data.votes[k]
+	if !ok {
+		row = models.LevelVote{LevelId: v.LevelId, Uid: v.Uid, Created: now}
+	}
+	row.Updated = sql.NullTime{Time: now, Valid: true}
+	// Pointers are copied so the row doesn't
+	// share them with the caller
+	if v.Liked != nil {
+		liked := *v.Liked
+		row.Liked = &liked
+	}
+	if v.Stars != nil {
+		stars := *v.Stars
+		row.Stars = &stars
+	}
+	r.data.votes[k] = row
+	return nil
+}
+
+func (r votes) Get(levelId, uid int) (models.LevelVote, error) {
+	defer r.lock()()
+	row, ok := r.data.votes[voteKey{levelId, uid}]
+	if !ok {
+		return models.LevelVote{}, sql.ErrNoRows
+	}
+	return row, nil
+}
+
+func (r votes) Delete(levelId, uid int) error {
+	defer r.lock()()
+	k := voteKey{levelId, uid}
+	if _, ok := r.data.votes[k]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.data.votes, k)
+	return nil
+}
+
+func (r votes) Rating(levelId int) (models.LevelRating, error) {
+	defer r.lock()()
+	return r.data.rating(levelId), nil
+}
+
+// Like the level_ratings view, for any level
+func (d *data) rating(levelId int) models.LevelRating {
+	res := models.LevelRating{}
+	sum, total, n := 0, 0, 0
+	for k, v := range d.votes {
+		if v.Stars != nil {
+			total += *v.Stars
+			n++
+		}
+		if k.levelId != levelId {
+			continue
+		}
+		if v.Liked != nil && *v.Liked {
+			res.Likes++
+		} else if v.Liked != nil {
+			res.Dislikes++
+		}
+		if v.Stars != nil {
+			sum += *v.Stars
+			res.Ratings++
+		}
+	}
+	if res.Ratings > 0 {
+		res.Average = float64(sum) / float64(res.Ratings)
+	}
+	mean := float64(models.DefaultRatingMean)
+	if n > 0 {
+		mean = float64(total) / float64(n)
+	}
+	res.Score = models.BayesianRating(res.Ratings, res.Average, mean)
+	return res
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Position of the last row of a page. Lists are
+// sorted by Id, after Rank (search results) or
+// Time (leaderboards) where those apply.
+type Cursor struct {
+	Id   int     `json:"id"`
+	Rank float64 `json:"rank,omitempty"`
+	Time int     `json:"time,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := Cursor{}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// A page of a list. After is nil for the first page.
+type Page struct {
+	Limit int
+	After *Cursor
+}
+
+// Builds a page from the limit and cursor sent by a
+// client. A zero limit uses DefaultPageLimit and an
+// empty cursor starts from the first row.
+func NewPage(limit int, cursor string) (Page, error) {
+	if limit < 0 || limit > MaxPageLimit {
+		return Page{}, fmt.Errorf("limit must be between 1 and %v", MaxPageLimit)
+	}
+	if limit == 0 {
+		limit = DefaultPageLimit
+	}
+	p := Page{Limit: limit}
+	if cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return Page{}, err
+		}
+		p.After = c
+	}
+	return p, nil
+}
+
+func (p Page) limit() int {
+	if p.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	return p.Limit
+}
+
+// Repositories fetch one row more than the
+// limit to know if there is a next page
+func (p Page) Fetch() int {
+	return p.limit() + 1
+}
+
+// Trims rows fetched with p.Fetch() to the page. Returns
+// the encoded cursor of the next page, or "" if rows
+// was the last one.
+func Paginate[T any](rows []T, p Page, key func(T) Cursor) ([]T, string) {
+	if len(rows) <= p.limit() {
+		return rows, ""
+	}
+	rows = rows[:p.limit()]
+	return rows, key(rows[len(rows)-1]).Encode()
+}
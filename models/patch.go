@@ -0,0 +1,303 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// An operation of a JSON Patch (RFC 6902). Value is
+// kept encoded so a missing value can be told apart
+// from null.
+type PatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Returned for patches that can't be applied to the
+// document, e.g. because a path doesn't exist or a
+// test operation failed
+type PatchError struct {
+	// Index of the failed operation
+	Op  int
+	Msg string
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("patch operation %d: %v", e.Op, e.Msg)
+}
+
+// Splits a JSON Pointer (RFC 6901) into its unescaped
+// tokens. The empty pointer is the whole document.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("path %q must start with /", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// Index of an array element. "-", the element after
+// the last one, is only valid if end is set.
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" && end {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	max := length - 1
+	if end {
+		max = length
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of bounds", i)
+	}
+	return i, nil
+}
+
+func getPointer(doc interface{}, tokens []string) (interface{}, error) {
+	for _, t := range tokens {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			v, ok := d[t]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", t)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(t, len(d), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = d[i]
+		default:
+			return nil, fmt.Errorf("can't index a scalar with %q", t)
+		}
+	}
+	return doc, nil
+}
+
+// Applies fn to the parent of the value at tokens, which
+// can't be empty, and returns the updated document
+func updateParent(doc interface{}, tokens []string, fn func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		child, ok := d[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", tokens[0])
+		}
+		child, err := updateParent(child, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		d[tokens[0]] = child
+		return d, nil
+	case []interface{}:
+		i, err := arrayIndex(tokens[0], len(d), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := updateParent(d[i], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		d[i] = child
+		return d, nil
+	}
+	return nil, fmt.Errorf("can't index a scalar with %q", tokens[0])
+}
+
+func addValue(doc interface{}, tokens []string, v interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return v, nil
+	}
+	return updateParent(doc, tokens, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[key] = v
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(key, len(p), true)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = v
+			return p, nil
+		}
+		return nil, fmt.Errorf("can't add %q to a scalar", key)
+	})
+}
+
+func removeValue(doc interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("can't remove the whole document")
+	}
+	return updateParent(doc, tokens, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := p[key]; !ok {
+				return nil, fmt.Errorf("member %q not found", key)
+			}
+			delete(p, key)
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(key, len(p), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(p[:i], p[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("can't remove %q from a scalar", key)
+	})
+}
+
+func replaceValue(doc interface{}, tokens []string, v interface{}) (interface{}, error) {
+	if _, err := getPointer(doc, tokens); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return v, nil
+	}
+	return updateParent(doc, tokens, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[key] = v
+			return p, nil
+		case []interface{}:
+			i, _ := arrayIndex(key, len(p), false)
+			p[i] = v
+			return p, nil
+		}
+		return nil, fmt.Errorf("can't replace %q in a scalar", key)
+	})
+}
+
+// Copies decoded JSON, so copied values
+// don't share maps and slices
+func copyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			res[k] = copyJSON(e)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			res[i] = copyJSON(e)
+		}
+		return res
+	}
+	return v
+}
+
+func (op PatchOp) value() (interface{}, error) {
+	if len(op.Value) == 0 {
+		return nil, fmt.Errorf("%v needs a value", op.Op)
+	}
+	var v interface{}
+	err := json.Unmarshal(op.Value, &v)
+	return v, err
+}
+
+func (op PatchOp) apply(doc interface{}) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		v, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "add" {
+			return addValue(doc, path, v)
+		}
+		if op.Op == "replace" {
+			return replaceValue(doc, path, v)
+		}
+		cur, err := getPointer(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(cur, v) {
+			return nil, fmt.Errorf("test of %q failed", op.Path)
+		}
+		return doc, nil
+	case "remove":
+		return removeValue(doc, path)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := getPointer(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			return addValue(doc, path, copyJSON(v))
+		}
+		if op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("can't move %q into itself", op.From)
+		}
+		if doc, err = removeValue(doc, from); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, v)
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// Applies the operations of a JSON Patch (RFC 6902) in
+// order. doc may be modified even if the patch fails.
+func ApplyJSONPatch(doc interface{}, ops []PatchOp) (interface{}, error) {
+	for i, op := range ops {
+		var err error
+		if doc, err = op.apply(doc); err != nil {
+			return nil, &PatchError{Op: i, Msg: err.Error()}
+		}
+	}
+	return doc, nil
+}
+
+// Applies a JSON Merge Patch (RFC 7396). Members set to
+// null are removed and anything but an object replaces
+// the target.
+func MergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = MergePatch(t[k], v)
+		}
+	}
+	return t
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/sofferjacob/maker_api/db"
+)
+
+type pgCheckpoints struct {
+	q db.Queryer
+}
+
+type dbCheckpoint struct {
+	Checkpoint
+	CourseData json.RawMessage `db:"course_data"`
+}
+
+func (r pgCheckpoints) Create(c *Checkpoint) (int, error) {
+	cd, err := json.Marshal(c.CourseData)
+	if err != nil {
+		return 0, err
+	}
+	query, args := db.Insert("draft_checkpoints").Set("draft_id", c.DraftId).Set("revision", c.Revision).
+		Set("name", c.Name).Set("course_data", cd).Returning("id").Query()
+	var id int
+	err = r.q.Get(&id, query, args...)
+	return id, err
+}
+
+func (r pgCheckpoints) get(query string, args ...interface{}) (Checkpoint, error) {
+	res := dbCheckpoint{}
+	if err := r.q.Get(&res, query, args...); err != nil {
+		return Checkpoint{}, err
+	}
+	c := res.Checkpoint
+	err := json.Unmarshal(res.CourseData, &c.CourseData)
+	return c, err
+}
+
+func (r pgCheckpoints) Get(draftId, id int) (Checkpoint, error) {
+	return r.get("SELECT * FROM draft_checkpoints WHERE draft_id = $1 AND id = $2;", draftId, id)
+}
+
+func (r pgCheckpoints) Latest(draftId int) (Checkpoint, error) {
+	return r.get("SELECT * FROM draft_checkpoints WHERE draft_id = $1 ORDER BY id DESC LIMIT 1;", draftId)
+}
+
+func (r pgCheckpoints) List(draftId int, p Page) ([]Checkpoint, string, error) {
+	qb := db.SelectFrom("draft_checkpoints").Select("id", "draft_id", "revision", "name", "created").
+		Where("draft_id", "=", draftId)
+	query, args := afterId(qb, "id", p).Query()
+	res := []Checkpoint{}
+	if err := r.q.Select(&res, query, args...); err != nil {
+		return nil, "", err
+	}
+	res, next := Paginate(res, p, func(c Checkpoint) Cursor { return Cursor{Id: c.Id} })
+	return res, next, nil
+}
+
+func (r pgCheckpoints) CountNamed(draftId int) (int, error) {
+	query := "SELECT COUNT(*) FROM draft_checkpoints WHERE draft_id = $1 AND name <> '';"
+	var res int
+	err := r.q.Get(&res, query, draftId)
+	return res, err
+}
+
+func (r pgCheckpoints) Prune(draftId, keep int) error {
+	query := `DELETE FROM draft_checkpoints WHERE draft_id = $1 AND name = '' AND id NOT IN (
+		SELECT id FROM draft_checkpoints WHERE draft_id = $1 AND name = '' ORDER BY id DESC LIMIT $2
+	);`
+	_, err := r.q.Exec(query, draftId, keep)
+	return err
+}
+
+func (r pgCheckpoints) Delete(draftId, id int) error {
+	query := "DELETE FROM draft_checkpoints WHERE draft_id = $1 AND id = $2;"
+	_, err := r.q.Exec(query, draftId, id)
+	return err
+}
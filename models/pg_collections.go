@@ -0,0 +1,147 @@
+package models
+
+import (
+	"github.com/sofferjacob/maker_api/db"
+)
+
+type pgCollections struct {
+	q db.Queryer
+}
+
+type rankedCollection struct {
+	Collection
+	Rank float64 `db:"rank"`
+}
+
+// A level with the id of its collection_levels row
+type linkedLevel struct {
+	DBLevel
+	LinkId int `db:"link_id"`
+}
+
+func (r pgCollections) Create(c *Collection) (int, error) {
+	var id int
+	query := "INSERT INTO collection (uid, name, description, language) VALUES ($1, $2, $3, $4) RETURNING id;"
+	err := r.q.Get(&id, query, c.Uid, c.Name, c.Description, c.Language)
+	return id, err
+}
+
+func (r pgCollections) Get(id int) (CollectionData, error) {
+	query := "SELECT c.*, u.name user_name FROM collection c INNER JOIN users u ON uid = u.id WHERE c.id = $1;"
+	res := CollectionData{}
+	err := r.q.Get(&res, query, id)
+	return res, err
+}
+
+func (r pgCollections) GetByUser(uid int, p Page) ([]CollectionData, string, error) {
+	qb := db.SelectFrom("collection c").Select("c.*", "u.name user_name").Join("users u", "c.uid", "u.id").
+		Where("c.uid", "=", uid)
+	query, args := afterId(qb, "c.id", p).Query()
+	res := []CollectionData{}
+	if err := r.q.Select(&res, query, args...); err != nil {
+		return nil, "", err
+	}
+	res, next := Paginate(res, p, func(c CollectionData) Cursor { return Cursor{Id: c.Id} })
+	return res, next, nil
+}
+
+func (r pgCollections) Update(c *Collection) error {
+	if c.Name == "" && c.Description == "" && c.Language == "" {
+		return nil
+	}
+	qb := db.Update("collection").Where("id", "=", c.Id).And("uid", "=", c.Uid)
+	if c.Name != "" {
+		qb = qb.Set("name", c.Name)
+	}
+	if c.Language != "" {
+		qb = qb.Set("language", c.Language)
+	}
+	if c.Description != "" {
+		qb = qb.Set("description", c.Description)
+	}
+	query, args := qb.Query()
+	_, err := r.q.Exec(query, args...)
+	return err
+}
+
+func (r pgCollections) Delete(id, uid int) error {
+	query := "DELETE FROM collection WHERE id = $1 AND uid = $2;"
+	_, err := r.q.Exec(query, id, uid)
+	return err
+}
+
+func (r pgCollections) Owner(id int) (int, error) {
+	query := "SELECT uid FROM collection WHERE id = $1;"
+	var uid int
+	err := r.q.Get(&uid, query, id)
+	return uid, err
+}
+
+func (r pgCollections) Query(query, lang string, p Page) ([]Collection, string, error) {
+	m, err := searchModeFor(r.q, "collection", query, lang)
+	if err != nil {
+		return nil, "", err
+	}
+	res := []rankedCollection{}
+	sql, args := searchQuery("collection", query, lang, m, p).Query()
+	if err := r.q.Select(&res, sql, args...); err != nil {
+		return nil, "", err
+	}
+	res, next := Paginate(res, p, func(c rankedCollection) Cursor {
+		return Cursor{Id: c.Id, Rank: c.Rank}
+	})
+	cls := make([]Collection, 0, len(res))
+	for _, v := range res {
+		cls = append(cls, v.Collection)
+	}
+	return cls, next, nil
+}
+
+func (r pgCollections) Suggest(prefix string, limit int) ([]Suggestion, error) {
+	return suggest(r.q, "collection", "A", prefix, limit)
+}
+
+func (r pgCollections) Trending() ([]Collection, error) {
+	query := "SELECT id, name, description, uid, created, updated FROM trending_collections;"
+	res := []Collection{}
+	err := r.q.Select(&res, query)
+	return res, err
+}
+
+func (r pgCollections) AddLevel(cl *CollectionLevels) error {
+	query, args := db.Insert("collection_levels").
+		Set("collection_id", cl.CollectionId).
+		Set("level_id", cl.LevelId).Query()
+	_, err := r.q.Exec(query, args...)
+	return err
+}
+
+func (r pgCollections) RemoveLevel(cl *CollectionLevels) error {
+	query := "DELETE FROM collection_levels WHERE collection_id = $1 AND level_id = $2;"
+	_, err := r.q.Exec(query, cl.CollectionId, cl.LevelId)
+	return err
+}
+
+// Levels are sorted by the order they
+// were added to the collection
+func (r pgCollections) Levels(collectionId int, p Page) ([]Level, string, error) {
+	qb := db.SelectFrom("collection_levels c").Select("l.*", "c.id link_id").Join("levels l", "c.level_id", "l.id").
+		Where("c.collection_id", "=", collectionId).
+		And("l.visibility", "=", VisibilityPublic)
+	if p.After != nil {
+		qb = qb.And("c.id", ">", p.After.Id)
+	}
+	query, args := qb.OrderBy("c.id").Limit(p.Fetch()).Query()
+	res := []linkedLevel{}
+	if err := r.q.Select(&res, query, args...); err != nil {
+		return nil, "", err
+	}
+	res, next := Paginate(res, p, func(l linkedLevel) Cursor { return Cursor{Id: l.LinkId} })
+	levels := make([]Level, 0, len(res))
+	for _, v := range res {
+		l := Level{}
+		v.ToLevel(&l)
+		levels = append(levels, l)
+	}
+	return levels, next, nil
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sofferjacob/maker_api/db"
+)
+
+type pgComments struct {
+	q db.Queryer
+}
+
+type rankedComment struct {
+	CommentData
+	Rank float64 `db:"rank"`
+}
+
+func commentCursor(c CommentData) Cursor {
+	return Cursor{Id: c.Id}
+}
+
+func (r pgComments) Create(c *Comment) (int, error) {
+	query, args := db.Insert("comments").
+		Set("uid", c.Uid).
+		Set("level_id", c.LevelId).
+		Set("collection_id", c.CollectionId).
+		Set("parent_id", c.ParentId).
+		Set("body", c.Body).
+		Returning("id").Query()
+	var id int
+	err := r.q.Get(&id, query, args...)
+	return id, err
+}
+
+func (r pgComments) Get(id int) (CommentData, error) {
+	res := CommentData{}
+	err := r.q.Get(&res, "SELECT * FROM comment_data WHERE id = $1;", id)
+	return res, err
+}
+
+func (r pgComments) list(qb db.DynamicQuery, p Page) ([]CommentData, string, error) {
+	query, args := qb.Query()
+	res := []CommentData{}
+	if err := r.q.Select(&res, query, args...); err != nil {
+		return nil, "", err
+	}
+	res, next := Paginate(res, p, commentCursor)
+	return res, next, nil
+}
+
+func (r pgComments) ByLevel(levelId int, p Page) ([]CommentData, string, error) {
+	qb := db.SelectFrom("comment_data").Where("level_id", "=", levelId).Filter(db.IsNull("parent_id"))
+	return r.list(afterId(qb, "id", p), p)
+}
+
+func (r pgComments) ByCollection(collectionId int, p Page) ([]CommentData, string, error) {
+	qb := db.SelectFrom("comment_data").Where("collection_id", "=", collectionId).Filter(db.IsNull("parent_id"))
+	return r.list(afterId(qb, "id", p), p)
+}
+
+func (r pgComments) Replies(id int, p Page) ([]CommentData, string, error) {
+	qb := db.SelectFrom("comment_data").Where("parent_id", "=", id)
+	if p.After != nil {
+		qb = qb.And("id", ">", p.After.Id)
+	}
+	return r.list(qb.OrderBy("id").Limit(p.Fetch()), p)
+}
+
+func (r pgComments) Update(c *Comment) error {
+	query, args := db.Update("comments").
+		Set("body", c.Body).
+		Set("updated", time.Now()).
+		Where("id", "=", c.Id).
+		And("uid", "=", c.Uid).Query()
+	res, err := r.q.Exec(query, args...)
+	return expectRows(res, err)
+}
+
+// Replies are removed by the ON DELETE CASCADE
+// constraint on parent_id
+func (r pgComments) Delete(id int) error {
+	res, err := r.q.Exec("DELETE FROM comments WHERE id = $1;", id)
+	return expectRows(res, err)
+}
+
+func (r pgComments) Query(query, lang string, p Page) ([]CommentData, string, error) {
+	res := []rankedComment{}
+	sql, args := searchQuery("comment_data", query, lang, ftsSearch, p).
+		Filter(db.Or(db.IsNull("t.level_id"), db.Expr("t.level_id IN (SELECT id FROM levels WHERE visibility = 'public')"))).Query()
+	if err := r.q.Select(&res, sql, args...); err != nil {
+		return nil, "", err
+	}
+	res, next := Paginate(res, p, func(c rankedComment) Cursor {
+		return Cursor{Id: c.Id, Rank: c.Rank}
+	})
+	comments := make([]CommentData, 0, len(res))
+	for _, v := range res {
+		comments = append(comments, v.CommentData)
+	}
+	return comments, next, nil
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sofferjacob/maker_api/db"
+)
+
+type pgDifficulty struct {
+	q db.Queryer
+}
+
+func (r pgDifficulty) Finished(levelId, uid int) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM events WHERE event_type = 'game_finish' AND level_id = $1 AND uid = $2);"
+	var finished bool
+	err := r.q.Get(&finished, query, levelId, uid)
+	return finished, err
+}
+
+func (r pgDifficulty) Set(v *DifficultyVote) error {
+	query, args := db.Insert("difficulty_votes").
+		Set("level_id", v.LevelId).
+		Set("uid", v.Uid).
+		Set("difficulty", v.Difficulty).
+		Set("updated", time.Now()).
+		OnConflict("level_id", "uid").DoUpdate("difficulty", "updated").Query()
+	_, err := r.q.Exec(query, args...)
+	return err
+}
+
+func (r pgDifficulty) Get(levelId, uid int) (DifficultyVote, error) {
+	res := DifficultyVote{}
+	query := "SELECT * FROM difficulty_votes WHERE level_id = $1 AND uid = $2;"
+	err := r.q.Get(&res, query, levelId, uid)
+	return res, err
+}
+
+func (r pgDifficulty) Delete(levelId, uid int) error {
+	res, err := r.q.Exec("DELETE FROM difficulty_votes WHERE level_id = $1 AND uid = $2;", levelId, uid)
+	return expectRows(res, err)
+}
+
+func (r pgDifficulty) Level(levelId int) (LevelDifficulty, error) {
+	query := "SELECT community_difficulty, votes, avg_vote, starts, finishes, avg_time FROM level_difficulty WHERE level_id = $1;"
+	res := LevelDifficulty{}
+	err := r.q.Get(&res, query, levelId)
+	return res, err
+}
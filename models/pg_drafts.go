@@ -0,0 +1,119 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jmoiron/sqlx/types"
+	"github.com/sofferjacob/maker_api/db"
+)
+
+type pgDrafts struct {
+	q db.Queryer
+}
+
+func (r pgDrafts) Create(d *Draft) (int, error) {
+	qb := db.Insert("drafts").Set("name", d.Name).Set("uid", d.Uid).
+		Set("car", d.Car).Set("soundtrack", d.Soundtrack)
+	if d.LevelId != 0 {
+		qb = qb.Set("level_id", d.LevelId)
+	}
+	if d.CourseData != nil {
+		cd, err := d.CourseDataDb()
+		if err != nil {
+			return -1, err
+		}
+		qb = qb.Set("course_data", cd)
+	}
+	if d.Theme != 0 {
+		qb = qb.Set("theme", d.Theme)
+	}
+	if d.SourceLevelId != nil {
+		qb = qb.Set("source_level_id", *d.SourceLevelId).Set("source_version", d.SourceVersion)
+	}
+	query, args := qb.Returning("id").Query()
+	var id int
+	err := r.q.Get(&id, query, args...)
+	return id, err
+}
+
+func (r pgDrafts) Update(d *Draft) error {
+	qb := db.Update("drafts")
+	if d.Name != "" {
+		qb = qb.Set("name", d.Name)
+	}
+	if d.Theme != 0 {
+		qb = qb.Set("theme", d.Theme)
+	}
+	if d.Car != 0 {
+		qb = qb.Set("car", d.Car)
+	}
+	if d.Soundtrack != 0 {
+		qb = qb.Set("soundtrack", d.Soundtrack)
+	}
+	if d.CourseData != nil {
+		cdE, err := json.Marshal(d.CourseData)
+		cd := types.JSONText(cdE)
+		if err != nil {
+			return fmt.Errorf("could not encode course data: %v", err.Error())
+		}
+		qb = qb.Set("course_data", cd)
+	}
+	qb = qb.SetExpr("revision", "revision + 1").
+		Where("id", "=", d.Id).And("uid", "=", d.Uid)
+	if d.Revision != 0 {
+		qb = qb.And("revision", "=", d.Revision)
+	}
+	query, args := qb.Returning("revision").Query()
+	return r.q.Get(&d.Revision, query, args...)
+}
+
+func (r pgDrafts) Get(id int) (Draft, error) {
+	res := DbDraft{}
+	query := "SELECT * FROM drafts WHERE id = $1"
+	err := r.q.Get(&res, query, id)
+	d := Draft{}
+	if err == nil {
+		res.LoadToDraft(&d)
+	}
+	return d, err
+}
+
+func (r pgDrafts) GetByLevel(levelId int) (Draft, error) {
+	res := DbDraft{}
+	query := "SELECT * FROM drafts WHERE level_id = $1"
+	err := r.q.Get(&res, query, levelId)
+	d := Draft{}
+	if err == nil {
+		res.LoadToDraft(&d)
+	}
+	return d, err
+}
+
+func (r pgDrafts) GetByUser(uid int, p Page) ([]Draft, string, error) {
+	query, args := afterId(db.SelectFrom("drafts").Select("*").Where("uid", "=", uid), "id", p).Query()
+	res := []DbDraft{}
+	if err := r.q.Select(&res, query, args...); err != nil {
+		return nil, "", err
+	}
+	res, next := Paginate(res, p, func(d DbDraft) Cursor { return Cursor{Id: d.Id} })
+	arr := make([]Draft, 0, len(res))
+	for _, v := range res {
+		d := Draft{}
+		v.LoadToDraft(&d)
+		arr = append(arr, d)
+	}
+	return arr, next, nil
+}
+
+func (r pgDrafts) Delete(id int) error {
+	query := "DELETE FROM drafts WHERE id = $1;"
+	_, err := r.q.Exec(query, id)
+	return err
+}
+
+func (r pgDrafts) DeleteOwned(id, uid int) error {
+	query := "DELETE FROM drafts WHERE id = $1 AND uid = $2;"
+	_, err := r.q.Exec(query, id, uid)
+	return err
+}
@@ -0,0 +1,386 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sofferjacob/maker_api/db"
+)
+
+type pgLevels struct {
+	q db.Queryer
+}
+
+type rankedLevel struct {
+	DBLevel
+	Rank float64 `db:"rank"`
+}
+
+func levelCursor(l Level) Cursor {
+	return Cursor{Id: l.Id}
+}
+
+func leaderboardCursor(l Leaderboard) Cursor {
+	return Cursor{Id: l.Id, Time: l.Time}
+}
+
+func toLevels(res []DBLevel) []Level {
+	levels := make([]Level, 0, len(res))
+	for _, v := range res {
+		l := Level{}
+		v.ToLevel(&l)
+		levels = append(levels, l)
+	}
+	return levels
+}
+
+func (r pgLevels) Create(l *Level) (int, error) {
+	query := `INSERT INTO levels (difficulty, name, description, uid, theme, car, soundtrack, language, visibility, share_token, source_level_id, source_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, $12) RETURNING id;`
+	var id int
+	err := r.q.Get(&id, query, l.Difficulty, l.Name, l.Description, l.Uid, l.Theme, l.Car, l.Soundtrack, l.Language, l.Visibility, l.ShareToken, l.SourceLevelId, l.SourceVersion)
+	return id, err
+}
+
+func (r pgLevels) Get(id int) (Level, error) {
+	res := DBLevel{}
+	query := "SELECT l.*, c.map_data course_data FROM levels l INNER JOIN course_data c ON l.id = c.level_id WHERE l.id = $1;"
+	err := r.q.Get(&res, query, id)
+	l := Level{}
+	res.ToLevel(&l)
+	return l, err
+}
+
+func (r pgLevels) GetInfo(id int) (Level, error) {
+	res := DBLevel{}
+	query := "SELECT * FROM levels WHERE id = $1;"
+	err := r.q.Get(&res, query, id)
+	l := Level{}
+	res.ToLevel(&l)
+	return l, err
+}
+
+func (r pgLevels) GetByShareToken(token string) (Level, error) {
+	res := DBLevel{}
+	query := "SELECT l.*, c.map_data course_data FROM levels l INNER JOIN course_data c ON l.id = c.level_id WHERE l.share_token = $1 AND l.visibility = 'unlisted';"
+	err := r.q.Get(&res, query, token)
+	l := Level{}
+	res.ToLevel(&l)
+	return l, err
+}
+
+func (r pgLevels) GetByUser(uid int, all bool, p Page) ([]Level, string, error) {
+	qb := db.SelectFrom("levels").Select("*").Where("uid", "=", uid)
+	if !all {
+		qb = qb.And("visibility", "=", VisibilityPublic)
+	}
+	query, args := afterId(qb, "id", p).Query()
+	res := []DBLevel{}
+	if err := r.q.Select(&res, query, args...); err != nil {
+		return nil, "", err
+	}
+	levels, next := Paginate(toLevels(res), p, levelCursor)
+	return levels, next, nil
+}
+
+func (r pgLevels) Update(l *Level) error {
+	query := db.Update("levels").Set("updated", time.Now())
+	if l.Name != "" {
+		query = query.Set("name", l.Name)
+	}
+	if l.Difficulty != 0 {
+		query = query.Set("difficulty", l.Difficulty)
+	}
+	if l.Description != "" {
+		query = query.Set("description", l.Description)
+	}
+	if l.Theme != 0 {
+		query = query.Set("theme", l.Theme)
+	}
+	if l.Car != 0 {
+		query = query.Set("car", l.Car)
+	}
+	if l.Soundtrack != 0 {
+		query = query.Set("soundtrack", l.Soundtrack)
+	}
+	if l.Language != "" {
+		query = query.Set("language", l.Language)
+	}
+	// Unlisted levels keep their share token
+	if l.Visibility == VisibilityUnlisted {
+		query = query.Set("visibility", l.Visibility).
+			SetExpr("share_token", "COALESCE(share_token, ?)", l.ShareToken)
+	} else if l.Visibility != "" {
+		query = query.Set("visibility", l.Visibility).Set("share_token", nil)
+	}
+	query = query.SetExpr("revision", "revision + 1").
+		Where("id", "=", l.Id).And("uid", "=", l.Uid)
+	if l.Revision != 0 {
+		query = query.And("revision", "=", l.Revision)
+	}
+	queryStr, args := query.Returning("revision").Query()
+	return r.q.Get(&l.Revision, queryStr, args...)
+}
+
+// Course data, drafts and collection links are removed
+// by the ON DELETE CASCADE constraints on those tables
+func (r pgLevels) Delete(id, uid int) error {
+	res, err := r.q.Exec("DELETE FROM levels WHERE id = $1 AND uid = $2;", id, uid)
+	return expectRows(res, err)
+}
+
+func (r pgLevels) Owner(id int) (int, error) {
+	query := "SELECT uid FROM levels WHERE id = $1;"
+	var uid int
+	err := r.q.Get(&uid, query, id)
+	return uid, err
+}
+
+func (r pgLevels) Remixes(id int, p Page) ([]Level, string, error) {
+	qb := db.SelectFrom("levels").Select("*").
+		Where("source_level_id", "=", id).
+		And("visibility", "=", VisibilityPublic)
+	query, args := afterId(qb, "id", p).Query()
+	res := []DBLevel{}
+	if err := r.q.Select(&res, query, args...); err != nil {
+		return nil, "", err
+	}
+	levels, next := Paginate(toLevels(res), p, levelCursor)
+	return levels, next, nil
+}
+
+func (r pgLevels) RemixCount(id int) (int, error) {
+	query := "SELECT COUNT(*) FROM levels WHERE source_level_id = $1 AND visibility = 'public';"
+	var n int
+	err := r.q.Get(&n, query, id)
+	return n, err
+}
+
+func (r pgLevels) SetShareToken(id int, token string) error {
+	res, err := r.q.Exec("UPDATE levels SET share_token = $1 WHERE id = $2;", token, id)
+	return expectRows(res, err)
+}
+
+// Sort key of each sort order, the ORDER BY of a
+// level search is the key and then the id. Relevance
+// is the rank of the search mode.
+var levelSortKeys = map[string]string{
+	SortNewest:     "t.id::float8",
+	SortMostPlayed: "t.plays::float8",
+	SortTopRated:   "t.score",
+}
+
+// Selects the rows of level_search, aliased t, that
+// match the text query and filters
+func filterLevels(qb db.DynamicQuery, query, lang string, m searchMode, f LevelFilter) db.DynamicQuery {
+	if query != "" {
+		qb = m.join(qb, query, lang).Filter(db.Expr(m.match))
+	}
+	if f.MinDifficulty != 0 {
+		qb = qb.Filter(db.Op("t.community_difficulty", ">=", f.MinDifficulty))
+	}
+	if f.MaxDifficulty != 0 {
+		qb = qb.Filter(db.Op("t.community_difficulty", "<=", f.MaxDifficulty))
+	}
+	if len(f.Tags) > 0 {
+		qb = qb.Filter(db.Expr(`t.id IN (SELECT lt.level_id FROM level_tags lt
+			INNER JOIN tags g ON lt.tag_id = g.id WHERE g.name = ANY(?)
+			GROUP BY lt.level_id HAVING COUNT(*) = ?)`, pq.Array(f.Tags), len(f.Tags)))
+	}
+	if len(f.Themes) > 0 {
+		qb = qb.Filter(db.In("t.theme", f.Themes))
+	}
+	if len(f.Cars) > 0 {
+		qb = qb.Filter(db.In("t.car", f.Cars))
+	}
+	if len(f.Soundtracks) > 0 {
+		qb = qb.Filter(db.In("t.soundtrack", f.Soundtracks))
+	}
+	if f.Creator != 0 {
+		qb = qb.Where("t.uid", "=", f.Creator)
+	}
+	if !f.CreatedFrom.IsZero() {
+		qb = qb.Where("t.created", ">=", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		qb = qb.Where("t.created", "<=", f.CreatedTo)
+	}
+	if !f.UpdatedFrom.IsZero() {
+		qb = qb.Where("t.updated", ">=", f.UpdatedFrom)
+	}
+	if !f.UpdatedTo.IsZero() {
+		qb = qb.Where("t.updated", "<=", f.UpdatedTo)
+	}
+	if f.MinPlays != 0 {
+		qb = qb.Where("t.plays", ">=", f.MinPlays)
+	}
+	if f.MinRating != 0 {
+		qb = qb.Where("t.avg_stars", ">=", f.MinRating)
+	}
+	return qb
+}
+
+// The search mode of a level search, probing
+// with the same filters as the search
+func (r pgLevels) searchMode(query, lang string, f LevelFilter) (searchMode, error) {
+	if query == "" {
+		return ftsSearch, nil
+	}
+	return searchModeOf(r.q, filterLevels(db.SelectFrom("level_search t"), query, lang, ftsSearch, f))
+}
+
+func (r pgLevels) Query(query, lang string, f LevelFilter, sort string, p Page) ([]Level, string, error) {
+	m, err := r.searchMode(query, lang, f)
+	if err != nil {
+		return nil, "", err
+	}
+	key, ok := levelSortKeys[sort]
+	if sort == SortRelevance {
+		key, ok = m.rank, true
+	}
+	if !ok {
+		return nil, "", ErrInvalidSort
+	}
+	qb := filterLevels(db.SelectFrom("level_search t"), query, lang, m, f).
+		SelectExpr("t.id, t.difficulty, t.name, t.description, t.uid, t.created, t.updated, t.theme, t.car, t.soundtrack, t.language, t.visibility").
+		SelectExpr(key + " AS rank")
+	if p.After != nil {
+		qb = qb.Filter(db.Expr("("+key+", t.id) < (?::float8, ?)", p.After.Rank, p.After.Id))
+	}
+	res := []rankedLevel{}
+	sql, args := qb.OrderBy("rank DESC").OrderBy("t.id DESC").Limit(p.Fetch()).Query()
+	if err := r.q.Select(&res, sql, args...); err != nil {
+		return nil, "", err
+	}
+	res, next := Paginate(res, p, func(l rankedLevel) Cursor {
+		return Cursor{Id: l.Id, Rank: l.Rank}
+	})
+	levels := make([]Level, 0, len(res))
+	for _, v := range res {
+		l := Level{}
+		v.ToLevel(&l)
+		levels = append(levels, l)
+	}
+	return levels, next, nil
+}
+
+// Column (or expression) each facet counts
+var levelFacets = map[string]string{
+	FacetTheme:      "t.theme",
+	FacetCar:        "t.car",
+	FacetSoundtrack: "t.soundtrack",
+	FacetDifficulty: "ROUND(t.community_difficulty)::int",
+}
+
+func (r pgLevels) Facet(query, lang string, f LevelFilter, facet string) ([]FacetCount, error) {
+	col, ok := levelFacets[facet]
+	if !ok {
+		return nil, fmt.Errorf("unknown facet %q", facet)
+	}
+	m, err := r.searchMode(query, lang, f)
+	if err != nil {
+		return nil, err
+	}
+	// Levels without a car or soundtrack aren't counted
+	qb := filterLevels(db.SelectFrom("level_search t"), query, lang, m, f).
+		SelectExpr(col + " AS value, COUNT(*) AS count").
+		Filter(db.Expr(col + " IS NOT NULL")).
+		GroupByExpr(col)
+	sql, args := qb.OrderBy("count DESC").OrderBy("value").Query()
+	res := []FacetCount{}
+	err = r.q.Select(&res, sql, args...)
+	return res, err
+}
+
+func (r pgLevels) Suggest(prefix string, limit int) ([]Suggestion, error) {
+	return suggest(r.q, "level_search", "A", prefix, limit)
+}
+
+func (r pgLevels) Trending() ([]Level, error) {
+	query := "SELECT id, difficulty, name, description, uid, created, updated, theme, visibility FROM trending_levels;"
+	res := []Level{}
+	err := r.q.Select(&res, query)
+	return res, err
+}
+
+type ratedLevel struct {
+	Level
+	LevelRating
+}
+
+func (r pgLevels) TopRated() ([]Level, error) {
+	query := "SELECT id, difficulty, name, description, uid, created, updated, theme, car, soundtrack, visibility, likes, dislikes, ratings, avg_stars, score FROM top_rated_levels;"
+	res := []ratedLevel{}
+	if err := r.q.Select(&res, query); err != nil {
+		return nil, err
+	}
+	levels := make([]Level, 0, len(res))
+	for _, v := range res {
+		l := v.Level
+		rating := v.LevelRating
+		l.Rating = &rating
+		levels = append(levels, l)
+	}
+	return levels, nil
+}
+
+func (r pgLevels) Leaderboard(id int, p Page) ([]Leaderboard, string, error) {
+	qb := db.SelectFrom("leaderboard").Where("level_id", "=", id)
+	if p.After != nil {
+		qb = qb.Filter(db.Expr("(time, id) > (?, ?)", p.After.Time, p.After.Id))
+	}
+	query, args := qb.OrderBy("time").OrderBy("id").Limit(p.Fetch()).Query()
+	res := []Leaderboard{}
+	if err := r.q.Select(&res, query, args...); err != nil {
+		return nil, "", err
+	}
+	res, next := Paginate(res, p, leaderboardCursor)
+	return res, next, nil
+}
+
+func (r pgLevels) CreateCourseData(c *CourseData) error {
+	query := "INSERT INTO course_data (level_id, map_data) VALUES ($1, $2);"
+	cd, err := json.Marshal(c.MapData)
+	if err != nil {
+		return err
+	}
+	_, err = r.q.Exec(query, c.LevelId, cd)
+	return err
+}
+
+// The level's draft is deleted by the
+// course_data_update_trigger
+func (r pgLevels) UpdateCourseData(c *CourseData) error {
+	cd, err := json.Marshal(c.MapData)
+	if err != nil {
+		return err
+	}
+	query := "UPDATE course_data SET map_data = $1 WHERE level_id = $2"
+	_, err = r.q.Exec(query, cd, c.LevelId)
+	return err
+}
+
+func (r pgLevels) DeleteCourseData(levelId int) error {
+	query := "DELETE FROM course_data WHERE level_id = $1"
+	_, err := r.q.Exec(query, levelId)
+	return err
+}
+
+// Returns sql.ErrNoRows if the statement
+// didn't affect any row
+func expectRows(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sofferjacob/maker_api/db"
+)
+
+type pgSessions struct {
+	q db.Queryer
+}
+
+func (r pgSessions) Create(uid int, refreshHash string, ttl time.Duration) (int, error) {
+	query := "INSERT INTO sessions (uid, refresh_hash, expires) VALUES ($1, $2, CURRENT_TIMESTAMP + $3 * INTERVAL '1 second') RETURNING id;"
+	var id int
+	err := r.q.Get(&id, query, uid, refreshHash, int(ttl.Seconds()))
+	return id, err
+}
+
+func (r pgSessions) Rotate(refreshHash, nextHash string, ttl time.Duration) (Session, error) {
+	query := `UPDATE sessions SET refresh_hash = $1, previous_hash = refresh_hash,
+		refreshed = CURRENT_TIMESTAMP, expires = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+		WHERE refresh_hash = $3 AND revoked IS NULL AND expires > CURRENT_TIMESTAMP
+		RETURNING *;`
+	s := Session{}
+	err := r.q.Get(&s, query, nextHash, int(ttl.Seconds()), refreshHash)
+	return s, err
+}
+
+func (r pgSessions) RevokeRotated(previousHash string) error {
+	query := "UPDATE sessions SET revoked = CURRENT_TIMESTAMP WHERE previous_hash = $1 AND revoked IS NULL;"
+	_, err := r.q.Exec(query, previousHash)
+	return err
+}
+
+func (r pgSessions) Revoke(id, uid int) error {
+	query := "UPDATE sessions SET revoked = CURRENT_TIMESTAMP WHERE id = $1 AND uid = $2 AND revoked IS NULL;"
+	_, err := r.q.Exec(query, id, uid)
+	return err
+}
+
+func (r pgSessions) RevokeAll(uid int) error {
+	query := "UPDATE sessions SET revoked = CURRENT_TIMESTAMP WHERE uid = $1 AND revoked IS NULL;"
+	_, err := r.q.Exec(query, uid)
+	return err
+}
+
+func (r pgSessions) IsActive(id int) (bool, error) {
+	query := "SELECT COUNT(*) FROM sessions WHERE id = $1 AND revoked IS NULL AND expires > CURRENT_TIMESTAMP;"
+	var n int
+	err := r.q.Get(&n, query, id)
+	return n > 0, err
+}
+
+type pgUserTokens struct {
+	q db.Queryer
+}
+
+func (r pgUserTokens) Create(uid int, purpose, hash string, ttl time.Duration) error {
+	query := "INSERT INTO user_tokens (uid, purpose, token_hash, expires) VALUES ($1, $2, $3, CURRENT_TIMESTAMP + $4 * INTERVAL '1 second');"
+	_, err := r.q.Exec(query, uid, purpose, hash, int(ttl.Seconds()))
+	return err
+}
+
+func (r pgUserTokens) Invalidate(uid int, purpose string) error {
+	query := "UPDATE user_tokens SET used = CURRENT_TIMESTAMP WHERE uid = $1 AND purpose = $2 AND used IS NULL;"
+	_, err := r.q.Exec(query, uid, purpose)
+	return err
+}
+
+func (r pgUserTokens) Consume(hash, purpose string) (int, error) {
+	query := `UPDATE user_tokens SET used = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND purpose = $2 AND used IS NULL AND expires > CURRENT_TIMESTAMP
+		RETURNING uid;`
+	var uid int
+	err := r.q.Get(&uid, query, hash, purpose)
+	return uid, err
+}
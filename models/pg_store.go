@@ -0,0 +1,142 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/sofferjacob/maker_api/db"
+	"github.com/sofferjacob/maker_api/tracking"
+)
+
+// The Postgres store. q is the transaction the
+// repositories run in, db.Client is used if it is nil.
+type pgStore struct {
+	q db.Queryer
+}
+
+func (s pgStore) queryer() db.Queryer {
+	if s.q == nil {
+		return db.Client.Client
+	}
+	return s.q
+}
+
+func (s pgStore) Users() UserRepository             { return pgUsers{s.queryer()} }
+func (s pgStore) Sessions() SessionRepository       { return pgSessions{s.queryer()} }
+func (s pgStore) UserTokens() UserTokenRepository   { return pgUserTokens{s.queryer()} }
+func (s pgStore) Levels() LevelRepository           { return pgLevels{s.queryer()} }
+func (s pgStore) Drafts() DraftRepository           { return pgDrafts{s.queryer()} }
+func (s pgStore) Collections() CollectionRepository { return pgCollections{s.queryer()} }
+func (s pgStore) Votes() VoteRepository             { return pgVotes{s.queryer()} }
+func (s pgStore) Comments() CommentRepository       { return pgComments{s.queryer()} }
+func (s pgStore) Difficulty() DifficultyRepository  { return pgDifficulty{s.queryer()} }
+func (s pgStore) Tags() TagRepository               { return pgTags{s.queryer()} }
+func (s pgStore) Versions() VersionRepository       { return pgVersions{s.queryer()} }
+func (s pgStore) Checkpoints() CheckpointRepository { return pgCheckpoints{s.queryer()} }
+func (s pgStore) Events() tracking.Repository       { return tracking.PgRepository{Q: s.q} }
+
+func (s pgStore) Tx(fn func(s Store) error) error {
+	// Already in a transaction
+	if s.q != nil {
+		return fn(s)
+	}
+	return db.Client.Tx(func(tx db.Queryer) error {
+		return fn(pgStore{tx})
+	})
+}
+
+// Filters the rows newer than the cursor, for
+// lists sorted by id, newest first
+func afterId(qb db.DynamicQuery, col string, p Page) db.DynamicQuery {
+	if p.After != nil {
+		qb = qb.And(col, "<", p.After.Id)
+	}
+	return qb.OrderBy(col + " DESC").Limit(p.Fetch())
+}
+
+// How a search matches and ranks the rows of a table,
+// aliased t, with q, the search query joined in by join
+type searchMode struct {
+	fuzzy bool
+	match string
+	rank  string
+}
+
+var (
+	// Full text search on the ts column
+	ftsSearch = searchMode{false, "t.ts @@ q", "ts_rank(t.ts, q)"}
+	// Trigram similarity of the name column, for
+	// misspelled words and partial names
+	fuzzySearch = searchMode{true, "q.s <% t.name", "word_similarity(q.s, t.name)"}
+)
+
+// Joins q, the query parsed in the language lang. Trigrams
+// don't depend on the language.
+func (m searchMode) join(qb db.DynamicQuery, query, lang string) db.DynamicQuery {
+	if m.fuzzy {
+		return qb.JoinExpr("CROSS JOIN (VALUES (?::text)) q(s)", query)
+	}
+	return qb.JoinExpr("CROSS JOIN gin_query(?, ?) q", query, lang)
+}
+
+// Searches fall back to fuzzySearch when no row of
+// table matches the query. The check doesn't depend
+// on the page, so every page uses the same mode.
+func searchModeFor(q db.Queryer, table, query, lang string) (searchMode, error) {
+	probe := ftsSearch.join(db.SelectFrom(table+" t"), query, lang).Filter(db.Expr(ftsSearch.match))
+	return searchModeOf(q, probe)
+}
+
+// Like searchModeFor, with probe selecting the rows
+// matching the full text query and the filters of
+// the search, so filtered out matches don't count
+func searchModeOf(q db.Queryer, probe db.DynamicQuery) (searchMode, error) {
+	query, args := probe.SelectExpr("1").Limit(1).Query()
+	var found bool
+	err := q.Get(&found, "SELECT EXISTS ("+strings.TrimSuffix(query, ";")+");", args...)
+	if err != nil || found {
+		return ftsSearch, err
+	}
+	return fuzzySearch, nil
+}
+
+// A page of the rows of table matching the search query,
+// ranked by relevance. The table is aliased t and rows
+// have an extra rank column. Callers can add filters
+// and joins before building the query.
+func searchQuery(table, query, lang string, m searchMode, p Page) db.DynamicQuery {
+	qb := m.join(db.SelectFrom(table+" t"), query, lang).
+		SelectExpr("t.*, " + m.rank + " AS rank").
+		Filter(db.Expr(m.match))
+	if p.After != nil {
+		qb = qb.Filter(db.Expr("("+m.rank+", t.id) < (?::float8, ?)", p.After.Rank, p.After.Id))
+	}
+	return qb.OrderBy("rank DESC").OrderBy("t.id DESC").Limit(p.Fetch())
+}
+
+type rankedSuggestion struct {
+	Suggestion
+	Rank float64 `db:"rank"`
+}
+
+// Up to limit names of table that start like prefix, or
+// are similar to it. Prefix matches come first, then the
+// most similar names. Prefixes only match the words of ts
+// with the given weights, e.g. "A" for the name of a
+// level. Empty weights match every word.
+func suggest(q db.Queryer, table, weights, prefix string, limit int) ([]Suggestion, error) {
+	query, args := db.SelectFrom(table+" t").
+		SelectExpr("t.id, t.name, (t.ts @@ q)::int + word_similarity(s.prefix, t.name) AS rank").
+		JoinExpr("CROSS JOIN prefix_query(?, ?) q CROSS JOIN (VALUES (?::text)) s(prefix)", prefix, weights, prefix).
+		Filter(db.Or(db.Expr("t.ts @@ q"), db.Expr("s.prefix <% t.name"))).
+		OrderBy("rank DESC").OrderBy("t.id DESC").
+		Limit(limit).Query()
+	res := []rankedSuggestion{}
+	if err := q.Select(&res, query, args...); err != nil {
+		return nil, err
+	}
+	suggestions := make([]Suggestion, 0, len(res))
+	for _, v := range res {
+		suggestions = append(suggestions, v.Suggestion)
+	}
+	return suggestions, nil
+}
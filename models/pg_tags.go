@@ -0,0 +1,73 @@
+package models
+
+import (
+	"github.com/lib/pq"
+	"github.com/sofferjacob/maker_api/db"
+)
+
+type pgTags struct {
+	q db.Queryer
+}
+
+func (r pgTags) SetLevelTags(levelId int, tags []string) error {
+	if _, err := r.q.Exec("DELETE FROM level_tags WHERE level_id = $1;", levelId); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	query := "INSERT INTO tags (name) SELECT unnest($1::varchar[]) ON CONFLICT (name) DO NOTHING;"
+	if _, err := r.q.Exec(query, pq.Array(tags)); err != nil {
+		return err
+	}
+	query = "INSERT INTO level_tags (level_id, tag_id) SELECT $1, id FROM tags WHERE name = ANY($2);"
+	_, err := r.q.Exec(query, levelId, pq.Array(tags))
+	return err
+}
+
+func (r pgTags) LevelTags(levelId int) ([]string, error) {
+	query, args := db.SelectFrom("level_tags lt").Select("g.name").Join("tags g", "lt.tag_id", "g.id").
+		Where("lt.level_id", "=", levelId).OrderBy("g.name").Query()
+	res := []string{}
+	err := r.q.Select(&res, query, args...)
+	return res, err
+}
+
+func (r pgTags) Levels(tag string, p Page) ([]Level, string, error) {
+	qb := db.SelectFrom("levels l").Select("l.*").
+		Join("level_tags lt", "lt.level_id", "l.id").
+		Join("tags g", "lt.tag_id", "g.id").
+		Where("g.name", "=", tag).
+		And("l.visibility", "=", VisibilityPublic)
+	query, args := afterId(qb, "l.id", p).Query()
+	res := []DBLevel{}
+	if err := r.q.Select(&res, query, args...); err != nil {
+		return nil, "", err
+	}
+	levels, next := Paginate(toLevels(res), p, levelCursor)
+	return levels, next, nil
+}
+
+func (r pgTags) Search(prefix string, curatedOnly bool, limit int) ([]Tag, error) {
+	qb := db.SelectFrom("tag_counts")
+	if curatedOnly {
+		qb = qb.Where("curated", "=", true)
+	} else {
+		qb = qb.Filter(db.Or(db.Op("curated", "=", true), db.Op("levels", ">", 0)))
+	}
+	if prefix != "" {
+		// Normalized tags have no LIKE wildcards
+		qb = qb.Where("name", "LIKE", prefix+"%")
+	}
+	query, args := qb.OrderBy("levels DESC").OrderBy("name").Limit(limit).Query()
+	res := []Tag{}
+	err := r.q.Select(&res, query, args...)
+	return res, err
+}
+
+func (r pgTags) SetCurated(tag string, curated bool) error {
+	query, args := db.Insert("tags").Set("name", tag).Set("curated", curated).
+		OnConflict("name").DoUpdate("curated").Query()
+	_, err := r.q.Exec(query, args...)
+	return err
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sofferjacob/maker_api/db"
+)
+
+type pgUsers struct {
+	q db.Queryer
+}
+
+type rankedUser struct {
+	User
+	Rank float64 `db:"rank"`
+}
+
+func (r pgUsers) Create(u *User) (int, error) {
+	var id int
+	err := r.q.Get(
+		&id,
+		"INSERT INTO users  (email, name, password, language) VALUES ($1, $2, $3, $4) RETURNING id;",
+		u.Email,
+		u.Name,
+		u.Password,
+		u.Language,
+	)
+	return id, err
+}
+
+func (r pgUsers) Get(id int) (User, error) {
+	u := User{}
+	err := r.q.Get(&u, "SELECT * FROM users WHERE id = $1;", id)
+	return u, err
+}
+
+func (r pgUsers) GetByEmail(email string) (User, error) {
+	u := User{}
+	err := r.q.Get(&u, "SELECT * FROM users WHERE email = $1;", email)
+	return u, err
+}
+
+func (r pgUsers) Update(u *User) error {
+	if u.Name == "" && u.Email == "" && u.Language == "" {
+		return nil
+	}
+	qb := db.Update("users").Where("id", "=", u.Id)
+	if u.Name != "" {
+		qb = qb.Set("name", u.Name)
+	}
+	if u.Language != "" {
+		qb = qb.Set("language", u.Language)
+	}
+	if u.Email != "" {
+		qb = qb.Set("email", u.Email).Set("verified", false)
+	}
+	query, args := qb.Query()
+	_, err := r.q.Exec(query, args...)
+	return err
+}
+
+func (r pgUsers) SetLastLogin(id int, t time.Time) error {
+	_, err := r.q.Exec("UPDATE users SET last_login = $1 WHERE id = $2;", t, id)
+	return err
+}
+
+func (r pgUsers) SetPassword(id int, hash string) error {
+	_, err := r.q.Exec("UPDATE users SET password = $1 WHERE id = $2;", hash, id)
+	return err
+}
+
+func (r pgUsers) SetVerified(id int) error {
+	_, err := r.q.Exec("UPDATE users SET verified = true WHERE id = $1;", id)
+	return err
+}
+
+func (r pgUsers) SetRole(id int, role string) error {
+	res, err := r.q.Exec("UPDATE users SET role = $1 WHERE id = $2;", role, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r pgUsers) Query(query, lang string, p Page) ([]User, string, error) {
+	m, err := searchModeFor(r.q, "users", query, lang)
+	if err != nil {
+		return nil, "", err
+	}
+	res := []rankedUser{}
+	sql, args := searchQuery("users", query, lang, m, p).Query()
+	if err := r.q.Select(&res, sql, args...); err != nil {
+		return nil, "", err
+	}
+	res, next := Paginate(res, p, func(u rankedUser) Cursor {
+		return Cursor{Id: u.Id, Rank: u.Rank}
+	})
+	u := make([]User, 0, len(res))
+	for _, v := range res {
+		u = append(u, v.User)
+	}
+	return u, next, nil
+}
+
+func (r pgUsers) Suggest(prefix string, limit int) ([]Suggestion, error) {
+	return suggest(r.q, "users", "", prefix, limit)
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/sofferjacob/maker_api/db"
+)
+
+type pgVersions struct {
+	q db.Queryer
+}
+
+type dbLevelVersion struct {
+	LevelVersion
+	MapData json.RawMessage `db:"map_data"`
+}
+
+func versionCursor(v LevelVersion) Cursor {
+	return Cursor{Id: v.Version}
+}
+
+// The unique (level_id, version) constraint makes
+// concurrent publishes of a level fail instead of
+// sharing a number
+func (r pgVersions) Create(v *LevelVersion) (int, error) {
+	cd, err := json.Marshal(v.CourseData)
+	if err != nil {
+		return 0, err
+	}
+	query := `INSERT INTO level_versions (level_id, version, map_data, restored_from)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3 FROM level_versions WHERE level_id = $1
+		RETURNING version;`
+	var version int
+	err = r.q.Get(&version, query, v.LevelId, cd, v.RestoredFrom)
+	return version, err
+}
+
+func (r pgVersions) Get(levelId, version int) (LevelVersion, error) {
+	res := dbLevelVersion{}
+	query := "SELECT level_id, version, map_data, restored_from, created FROM level_versions WHERE level_id = $1 AND version = $2;"
+	if err := r.q.Get(&res, query, levelId, version); err != nil {
+		return LevelVersion{}, err
+	}
+	v := res.LevelVersion
+	err := json.Unmarshal(res.MapData, &v.CourseData)
+	return v, err
+}
+
+func (r pgVersions) Latest(levelId int) (int, error) {
+	query := "SELECT version FROM level_versions WHERE level_id = $1 ORDER BY version DESC LIMIT 1;"
+	var version int
+	err := r.q.Get(&version, query, levelId)
+	return version, err
+}
+
+func (r pgVersions) List(levelId int, p Page) ([]LevelVersion, string, error) {
+	qb := db.SelectFrom("level_versions").Select("level_id", "version", "restored_from", "created").
+		Where("level_id", "=", levelId)
+	query, args := afterId(qb, "version", p).Query()
+	res := []LevelVersion{}
+	if err := r.q.Select(&res, query, args...); err != nil {
+		return nil, "", err
+	}
+	res, next := Paginate(res, p, versionCursor)
+	return res, next, nil
+}
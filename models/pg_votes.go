@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sofferjacob/maker_api/db"
+)
+
+type pgVotes struct {
+	q db.Queryer
+}
+
+func (r pgVotes) Set(v *LevelVote) error {
+	qb := db.Insert("level_votes").
+		Set("level_id", v.LevelId).
+		Set("uid", v.Uid).
+		Set("updated", time.Now())
+	cols := []string{"updated"}
+	if v.Liked != nil {
+		qb = qb.Set("liked", *v.Liked)
+		cols = append(cols, "liked")
+	}
+	if v.Stars != nil {
+		qb = qb.Set("stars", *v.Stars)
+		cols = append(cols, "stars")
+	}
+	query, args := qb.OnConflict("level_id", "uid").DoUpdate(cols...).Query()
+	_, err := r.q.Exec(query, args...)
+	return err
+}
+
+func (r pgVotes) Get(levelId, uid int) (LevelVote, error) {
+	res := LevelVote{}
+	query := "SELECT * FROM level_votes WHERE level_id = $1 AND uid = $2;"
+	err := r.q.Get(&res, query, levelId, uid)
+	return res, err
+}
+
+func (r pgVotes) Delete(levelId, uid int) error {
+	res, err := r.q.Exec("DELETE FROM level_votes WHERE level_id = $1 AND uid = $2;", levelId, uid)
+	return expectRows(res, err)
+}
+
+// Aggregates without GROUP BY always return a row,
+// so levels without votes get a zero rating
+func (r pgVotes) Rating(levelId int) (LevelRating, error) {
+	query := `SELECT r.*, bayesian_rating(r.ratings, r.avg_stars) score FROM (
+		SELECT COUNT(*) FILTER (WHERE liked) likes,
+			COUNT(*) FILTER (WHERE NOT liked) dislikes,
+			COUNT(stars) ratings,
+			COALESCE(AVG(stars), 0)::float8 avg_stars
+		FROM level_votes WHERE level_id = $1
+	) r;`
+	res := LevelRating{}
+	err := r.q.Get(&res, query, levelId)
+	return res, err
+}
@@ -0,0 +1,51 @@
+package models
+
+import "database/sql"
+
+// A level that a remix descends from
+type LineageEntry struct {
+	LevelId int `json:"levelId"`
+	// The version that was forked
+	Version *int `json:"version,omitempty"`
+	// Nil if the viewer can't read the level
+	Level *Level `json:"level,omitempty"`
+}
+
+// Lineages are cut at this depth
+const maxLineage = 50
+
+// Returns a page of the public remixes of the level,
+// newest first
+func GetLevelRemixes(levelId int, v Viewer, p Page) ([]Level, string, error) {
+	if _, err := readableLevel(store, levelId, v); err != nil {
+		return nil, "", err
+	}
+	return store.Levels().Remixes(levelId, p)
+}
+
+// Returns the levels the level was remixed from, from
+// its source to the original level. The lineage stops
+// at deleted levels.
+func GetLevelLineage(levelId int, v Viewer) ([]LineageEntry, error) {
+	l, err := readableLevel(store, levelId, v)
+	if err != nil {
+		return nil, err
+	}
+	res := []LineageEntry{}
+	for l.SourceLevelId != nil && len(res) < maxLineage {
+		entry := LineageEntry{LevelId: *l.SourceLevelId, Version: l.SourceVersion}
+		l, err = store.Levels().GetInfo(entry.LevelId)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if l.VisibleTo(v) {
+			source := l
+			entry.Level = &source
+		}
+		res = append(res, entry)
+	}
+	return res, nil
+}
@@ -0,0 +1,8 @@
+package models
+
+import "errors"
+
+// Returned by updates of drafts and levels based on an
+// outdated revision, which would overwrite the changes
+// made since it was read
+var ErrRevisionConflict = errors.New("it was changed since the given revision, read it again and retry")
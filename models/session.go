@@ -0,0 +1,157 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/sofferjacob/maker_api/conf"
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+type Session struct {
+	Id           int            `db:"id" json:"id"`
+	Uid          int            `db:"uid" json:"uid"`
+	RefreshHash  string         `db:"refresh_hash" json:"-"`
+	PreviousHash sql.NullString `db:"previous_hash" json:"-"`
+	Created      time.Time      `db:"created" json:"created"`
+	Refreshed    sql.NullTime   `db:"refreshed" json:"refreshed"`
+	Expires      time.Time      `db:"expires" json:"expires"`
+	Revoked      sql.NullTime   `db:"revoked" json:"revoked"`
+}
+
+// Access and refresh token issued for a session
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresAt    int64  `json:"expiresAt"`
+}
+
+func accessTokenTTL() time.Duration {
+	return conf.Duration("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+func refreshTokenTTL() time.Duration {
+	return conf.Duration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// Returns a random url-safe token. Only its
+// hash (see hashToken) should be stored.
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func issueAccessToken(uid, sessionId int, role string) (string, int64, error) {
+	now := time.Now()
+	expires := now.Add(accessTokenTTL()).Unix()
+	claims := Claims{
+		Role: role,
+		StandardClaims: jwt.StandardClaims{
+			Id:        strconv.Itoa(sessionId),
+			Issuer:    "a01028653@tec.mx",
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expires,
+			Subject:   strconv.Itoa(uid),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).
+		SignedString([]byte(os.Getenv("AUTH_KEY")))
+	if err != nil {
+		return "", 0, fmt.Errorf("could not issue token: %v", err.Error())
+	}
+	return token, expires, nil
+}
+
+// Opens a new session for the user and
+// returns its first token pair
+func NewSession(uid int, role string) (TokenPair, error) {
+	if uid == 0 {
+		return TokenPair{}, errors.New("missing required param uid")
+	}
+	refresh, err := newOpaqueToken()
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("could not create refresh token: %v", err.Error())
+	}
+	id, err := store.Sessions().Create(uid, hashToken(refresh), refreshTokenTTL())
+	if err != nil {
+		return TokenPair{}, err
+	}
+	token, expires, err := issueAccessToken(uid, id, role)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{Token: token, RefreshToken: refresh, ExpiresAt: expires}, nil
+}
+
+// Exchanges a refresh token for a new token pair.
+// The refresh token is rotated, so each one can
+// only be used once. Presenting an already rotated
+// token revokes the session, as it means the token
+// was leaked.
+func RefreshSession(refreshToken string) (TokenPair, int, error) {
+	if refreshToken == "" {
+		return TokenPair{}, 0, errors.New("missing required param refreshToken")
+	}
+	next, err := newOpaqueToken()
+	if err != nil {
+		return TokenPair{}, 0, fmt.Errorf("could not create refresh token: %v", err.Error())
+	}
+	hash := hashToken(refreshToken)
+	session, err := store.Sessions().Rotate(hash, hashToken(next), refreshTokenTTL())
+	if err == sql.ErrNoRows {
+		store.Sessions().RevokeRotated(hash)
+		return TokenPair{}, 0, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return TokenPair{}, 0, err
+	}
+	// The role is read again so role changes
+	// apply from the next refresh on
+	u, err := store.Users().Get(session.Uid)
+	if err != nil {
+		return TokenPair{}, 0, err
+	}
+	token, expires, err := issueAccessToken(session.Uid, session.Id, u.Role)
+	if err != nil {
+		return TokenPair{}, 0, err
+	}
+	return TokenPair{Token: token, RefreshToken: next, ExpiresAt: expires}, session.Uid, nil
+}
+
+func RevokeSession(id, uid int) error {
+	if id == 0 || uid == 0 {
+		return errors.New("missing required params id, uid")
+	}
+	return store.Sessions().Revoke(id, uid)
+}
+
+// Revokes every active session of the user
+// (log out of all devices)
+func RevokeUserSessions(uid int) error {
+	if uid == 0 {
+		return errors.New("missing required param uid")
+	}
+	return store.Sessions().RevokeAll(uid)
+}
+
+func IsSessionActive(id int) (bool, error) {
+	return store.Sessions().IsActive(id)
+}
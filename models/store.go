@@ -0,0 +1,246 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sofferjacob/maker_api/tracking"
+)
+
+// Repositories return sql.ErrNoRows when the row they
+// look up (or have to update) doesn't exist, like the
+// database/sql functions they wrap. Lists take a Page
+// and return the cursor of the next one, see Paginate.
+
+type UserRepository interface {
+	// Inserts the user. u.Password must already be hashed.
+	Create(u *User) (int, error)
+	Get(id int) (User, error)
+	GetByEmail(email string) (User, error)
+	// Sets the name and email of user u.Id, skipping empty
+	// values. Changing the email unverifies the user.
+	Update(u *User) error
+	SetLastLogin(id int, t time.Time) error
+	SetPassword(id int, hash string) error
+	SetVerified(id int) error
+	SetRole(id int, role string) error
+	// Users matching the query, parsed in the language lang
+	Query(query, lang string, p Page) ([]User, string, error)
+	Suggest(prefix string, limit int) ([]Suggestion, error)
+}
+
+type SessionRepository interface {
+	Create(uid int, refreshHash string, ttl time.Duration) (int, error)
+	// Replaces the refresh hash of the active session with
+	// refreshHash and extends it by ttl
+	Rotate(refreshHash, nextHash string, ttl time.Duration) (Session, error)
+	// Revokes the session whose previous refresh hash
+	// is previousHash
+	RevokeRotated(previousHash string) error
+	Revoke(id, uid int) error
+	RevokeAll(uid int) error
+	IsActive(id int) (bool, error)
+}
+
+type UserTokenRepository interface {
+	Create(uid int, purpose, hash string, ttl time.Duration) error
+	// Marks the unused tokens of the user for purpose as used
+	Invalidate(uid int, purpose string) error
+	// Marks the valid token as used and returns its uid
+	Consume(hash, purpose string) (int, error)
+}
+
+type LevelRepository interface {
+	// Inserts the level row. Course data is
+	// created separately with CreateCourseData.
+	Create(l *Level) (int, error)
+	// Returns the level with its course data
+	Get(id int) (Level, error)
+	// Returns the level without its course data
+	GetInfo(id int) (Level, error)
+	// Returns the unlisted level with the share
+	// token and its course data
+	GetByShareToken(token string) (Level, error)
+	// Levels of the user, only public ones
+	// unless all is set
+	GetByUser(uid int, all bool, p Page) ([]Level, string, error)
+	// Updates the non-zero fields of the level l.Id
+	// owned by l.Uid and increments its revision. If
+	// l.Revision is set, only that revision is updated.
+	// Sets l.Revision to the new revision. Course data
+	// is not updated.
+	Update(l *Level) error
+	// Deletes the level owned by uid, along with its
+	// course data, drafts and collection links
+	Delete(id, uid int) error
+	Owner(id int) (int, error)
+	SetShareToken(id int, token string) error
+	// Public levels forked from the level, newest first
+	Remixes(id int, p Page) ([]Level, string, error)
+	// Number of public levels forked from the level
+	RemixCount(id int) (int, error)
+	// Public levels matching the search, see QueryLevelFTS.
+	// Page cursors hold the value of the sort key.
+	Query(query, lang string, f LevelFilter, sort string, p Page) ([]Level, string, error)
+	// Counts of the levels matching the search by
+	// the values of facet, most common first
+	Facet(query, lang string, f LevelFilter, facet string) ([]FacetCount, error)
+	// Names completing prefix, see AutocompleteLevels
+	Suggest(prefix string, limit int) ([]Suggestion, error)
+	// Levels with the most game_start events
+	Trending() ([]Level, error)
+	// Rated levels by Bayesian rating, with their rating
+	TopRated() ([]Level, error)
+	// Fastest game_finish times of the level
+	Leaderboard(id int, p Page) ([]Leaderboard, string, error)
+	CreateCourseData(c *CourseData) error
+	// Updates the course data and deletes the
+	// level's draft, if there is one
+	UpdateCourseData(c *CourseData) error
+	DeleteCourseData(levelId int) error
+}
+
+type DraftRepository interface {
+	Create(d *Draft) (int, error)
+	// Updates the non-zero fields of the draft d.Id
+	// owned by d.Uid, like LevelRepository.Update
+	Update(d *Draft) error
+	Get(id int) (Draft, error)
+	GetByLevel(levelId int) (Draft, error)
+	GetByUser(uid int, p Page) ([]Draft, string, error)
+	Delete(id int) error
+	DeleteOwned(id, uid int) error
+}
+
+type CollectionRepository interface {
+	Create(c *Collection) (int, error)
+	Get(id int) (CollectionData, error)
+	GetByUser(uid int, p Page) ([]CollectionData, string, error)
+	// Updates the non-zero fields of the collection
+	// c.Id owned by c.Uid
+	Update(c *Collection) error
+	Delete(id, uid int) error
+	Owner(id int) (int, error)
+	Query(query, lang string, p Page) ([]Collection, string, error)
+	Suggest(prefix string, limit int) ([]Suggestion, error)
+	Trending() ([]Collection, error)
+	AddLevel(cl *CollectionLevels) error
+	RemoveLevel(cl *CollectionLevels) error
+	// Levels in the order they were added
+	Levels(collectionId int, p Page) ([]Level, string, error)
+}
+
+type VoteRepository interface {
+	// Inserts or updates the vote of v.Uid on v.LevelId.
+	// Liked and Stars are only changed if they are set.
+	Set(v *LevelVote) error
+	Get(levelId, uid int) (LevelVote, error)
+	Delete(levelId, uid int) error
+	// Aggregates of the level's votes. Levels
+	// without votes have a zero rating.
+	Rating(levelId int) (LevelRating, error)
+}
+
+type CommentRepository interface {
+	Create(c *Comment) (int, error)
+	Get(id int) (CommentData, error)
+	// Top level comments, newest first
+	ByLevel(levelId int, p Page) ([]CommentData, string, error)
+	ByCollection(collectionId int, p Page) ([]CommentData, string, error)
+	// Direct replies, oldest first
+	Replies(id int, p Page) ([]CommentData, string, error)
+	// Updates the body of the comment c.Id owned by c.Uid
+	Update(c *Comment) error
+	// Deletes the comment along with its replies
+	Delete(id int) error
+	// Comments matching the query, except those on
+	// levels that aren't public
+	Query(query, lang string, p Page) ([]CommentData, string, error)
+}
+
+type DifficultyRepository interface {
+	// Whether uid has a game_finish event on the level
+	Finished(levelId, uid int) (bool, error)
+	// Inserts or updates the vote of v.Uid on v.LevelId
+	Set(v *DifficultyVote) error
+	Get(levelId, uid int) (DifficultyVote, error)
+	Delete(levelId, uid int) error
+	// Community difficulty of the level
+	Level(levelId int) (LevelDifficulty, error)
+}
+
+type TagRepository interface {
+	// Replaces the tags of the level, creating the
+	// tags that don't exist. Tags must be normalized.
+	SetLevelTags(levelId int, tags []string) error
+	// Tags of the level, sorted by name
+	LevelTags(levelId int) ([]string, error)
+	// Levels with the tag, newest first
+	Levels(tag string, p Page) ([]Level, string, error)
+	// Tags starting with prefix, most used first. Only
+	// curated tags and tags with levels are listed.
+	Search(prefix string, curatedOnly bool, limit int) ([]Tag, error)
+	// Creates the tag if it doesn't exist
+	SetCurated(tag string, curated bool) error
+}
+
+type VersionRepository interface {
+	// Inserts v as the next version of v.LevelId
+	// and returns its number
+	Create(v *LevelVersion) (int, error)
+	// Returns the version with its course data
+	Get(levelId, version int) (LevelVersion, error)
+	// Number of the newest version of the level
+	Latest(levelId int) (int, error)
+	// Versions of the level without their
+	// course data, newest first
+	List(levelId int, p Page) ([]LevelVersion, string, error)
+}
+
+type CheckpointRepository interface {
+	// Inserts c and returns its id
+	Create(c *Checkpoint) (int, error)
+	// Returns the checkpoint of the draft with its
+	// course data
+	Get(draftId, id int) (Checkpoint, error)
+	// Newest checkpoint of the draft, with its course data
+	Latest(draftId int) (Checkpoint, error)
+	// Checkpoints of the draft without their course
+	// data, newest first
+	List(draftId int, p Page) ([]Checkpoint, string, error)
+	// Number of named checkpoints of the draft
+	CountNamed(draftId int) (int, error)
+	// Deletes all but the newest keep unnamed
+	// checkpoints of the draft
+	Prune(draftId, keep int) error
+	Delete(draftId, id int) error
+}
+
+// A Store gives access to every repository.
+type Store interface {
+	Users() UserRepository
+	Sessions() SessionRepository
+	UserTokens() UserTokenRepository
+	Levels() LevelRepository
+	Drafts() DraftRepository
+	Collections() CollectionRepository
+	Votes() VoteRepository
+	Comments() CommentRepository
+	Difficulty() DifficultyRepository
+	Tags() TagRepository
+	Versions() VersionRepository
+	Checkpoints() CheckpointRepository
+	Events() tracking.Repository
+	// Runs fn with a store whose repositories share
+	// a transaction. The transaction is committed if
+	// fn returns nil and rolled back otherwise.
+	Tx(fn func(s Store) error) error
+}
+
+var store Store = pgStore{}
+
+// Replaces the store used by the models and the
+// event tracker. Postgres is used by default.
+func SetStore(s Store) {
+	store = s
+	tracking.SetRepository(s.Events())
+}
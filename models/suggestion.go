@@ -0,0 +1,35 @@
+package models
+
+import "strings"
+
+// A name to complete a search with, see AutocompleteLevels
+type Suggestion struct {
+	Id   int    `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+}
+
+// Returns up to limit levels whose name has words starting
+// like the words of prefix, then levels with similar names
+// so typos still find them
+func AutocompleteLevels(prefix string, limit int) ([]Suggestion, error) {
+	if strings.TrimSpace(prefix) == "" {
+		return []Suggestion{}, nil
+	}
+	return store.Levels().Suggest(prefix, limit)
+}
+
+// Like AutocompleteLevels, for user names
+func AutocompleteUsers(prefix string, limit int) ([]Suggestion, error) {
+	if strings.TrimSpace(prefix) == "" {
+		return []Suggestion{}, nil
+	}
+	return store.Users().Suggest(prefix, limit)
+}
+
+// Like AutocompleteLevels, for collection names
+func AutocompleteCollections(prefix string, limit int) ([]Suggestion, error) {
+	if strings.TrimSpace(prefix) == "" {
+		return []Suggestion{}, nil
+	}
+	return store.Collections().Suggest(prefix, limit)
+}
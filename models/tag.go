@@ -0,0 +1,119 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	MaxTagLength = 30
+	MaxLevelTags = 10
+)
+
+var (
+	ErrInvalidTag  = errors.New("tags can only have letters, numbers and dashes")
+	ErrTooManyTags = errors.New("levels can have up to 10 tags")
+)
+
+type Tag struct {
+	Id   int    `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+	// Curated tags are suggested by the app
+	Curated bool      `db:"curated" json:"curated"`
+	Created time.Time `db:"created" json:"created"`
+	// Number of levels with the tag
+	Levels int `db:"levels" json:"levels"`
+}
+
+// Lowercases the tag and joins its words with dashes,
+// e.g. "Speed Run" becomes "speed-run"
+func NormalizeTag(tag string) (string, error) {
+	words := strings.FieldsFunc(strings.ToLower(tag), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '-' || r == '_'
+	})
+	res := strings.Join(words, "-")
+	if res == "" || len([]rune(res)) > MaxTagLength {
+		return "", ErrInvalidTag
+	}
+	for _, r := range res {
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '-' {
+			return "", ErrInvalidTag
+		}
+	}
+	return res, nil
+}
+
+// Normalizes the tags, dropping duplicates
+func NormalizeTags(tags []string) ([]string, error) {
+	res := []string{}
+	seen := map[string]bool{}
+	for _, t := range tags {
+		tag, err := NormalizeTag(t)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			res = append(res, tag)
+		}
+	}
+	return res, nil
+}
+
+// Normalizes the tags given to a level
+func levelTags(tags []string) ([]string, error) {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > MaxLevelTags {
+		return nil, ErrTooManyTags
+	}
+	return tags, nil
+}
+
+// Replaces the tags of the level
+func SetLevelTags(levelId int, tags []string) error {
+	tags, err := levelTags(tags)
+	if err != nil {
+		return err
+	}
+	return store.Tx(func(s Store) error {
+		return s.Tags().SetLevelTags(levelId, tags)
+	})
+}
+
+// Returns a page of the levels with the tag, newest first
+func GetTagLevels(tag string, p Page) ([]Level, string, error) {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return nil, "", err
+	}
+	return store.Tags().Levels(tag, p)
+}
+
+// Returns the tags starting with prefix, most used first.
+// Tags without levels are only suggested if curated.
+func AutocompleteTags(prefix string, limit int) ([]Tag, error) {
+	prefix, err := NormalizeTag(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return store.Tags().Search(prefix, false, limit)
+}
+
+// Returns the most used tags
+func PopularTags(curatedOnly bool, limit int) ([]Tag, error) {
+	return store.Tags().Search("", curatedOnly, limit)
+}
+
+// Marks the tag as curated or not, creating it if needed
+func SetTagCurated(tag string, curated bool) error {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+	return store.Tags().SetCurated(tag, curated)
+}
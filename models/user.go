@@ -4,12 +4,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"os"
 	"strconv"
 	"time"
 
-	"github.com/golang-jwt/jwt"
-	"github.com/sofferjacob/maker_api/db"
+	"github.com/sofferjacob/maker_api/conf"
+	"github.com/sofferjacob/maker_api/mail"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -21,6 +20,10 @@ type User struct {
 	Joined    time.Time    `db:"joined" json:"joined"`
 	LastLogin sql.NullTime `db:"last_login" json:"last_login"`
 	Ts        string       `db:"ts"`
+	Verified  bool         `db:"verified" json:"verified"`
+	Role      string       `db:"role" json:"role"`
+	// The language names are searched in
+	Language string `db:"language" json:"language"`
 }
 
 type UserData struct {
@@ -29,6 +32,9 @@ type UserData struct {
 	Name      string    `db:"name" json:"name"`
 	Joined    time.Time `db:"joined" json:"joined"`
 	LastLogin time.Time `db:"last_login" json:"last_login"`
+	Verified  bool      `db:"verified" json:"verified"`
+	Role      string    `db:"role" json:"role"`
+	Language  string    `db:"language" json:"language"`
 }
 
 func (u *User) ToUserData() UserData {
@@ -38,85 +44,189 @@ func (u *User) ToUserData() UserData {
 		u.Name,
 		u.Joined,
 		u.LastLogin.Time,
+		u.Verified,
+		u.Role,
+		u.Language,
 	}
 }
 
 // Takes a struct with id set to the uid to update,
-// and optional update values name, email
+// and optional update values name, email, language. Changing
+// the email marks the user as unverified and sends
+// a new verification email.
 func (u *User) Update() error {
-	if u.Name == "" && u.Email == "" {
+	if u.Name == "" && u.Email == "" && u.Language == "" {
 		return nil
 	}
-	updateI := 1
-	addComma := false
-	query := "UPDATE users SET "
-	args := []interface{}{}
-	if u.Name != "" {
-		query += fmt.Sprintf("name = $%v", updateI)
-		updateI++
-		addComma = true
-		args = append(args, u.Name)
-	}
-	if u.Email != "" {
-		if addComma {
-			query += ", "
-		}
-		query += fmt.Sprintf("email = $%v", updateI)
-		updateI++
-		args = append(args, u.Email)
-
+	if u.Language != "" && !ValidLanguage(u.Language) {
+		return ErrInvalidLanguage
+	}
+	err := store.Users().Update(u)
+	if err != nil || u.Email == "" {
+		return err
 	}
-	query += fmt.Sprintf(" WHERE id = $%v", updateI)
-	args = append(args, u.Id)
-	_, err := db.Client.Client.Exec(query, args...)
-	return err
+	if err := SendVerificationEmail(u.Id); err != nil {
+		return fmt.Errorf("email updated, failed to send verification email: %v", err.Error())
+	}
+	return nil
 }
 
+// Creates the user and mails them a verification link.
+// The user is registered even if the mail fails, they
+// can ask for it again with the resend endpoint.
 func (u *User) Register() error {
 	if u.Email == "" || u.Password == "" || u.Name == "" {
 		return errors.New("missing required struct fields (name, email, password)")
 	}
+	if u.Language == "" {
+		u.Language = DefaultLanguage
+	} else if !ValidLanguage(u.Language) {
+		return ErrInvalidLanguage
+	}
 	pwd, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return fmt.Errorf("could not hash password: %v", err.Error())
 	}
-	_, err = db.Client.Client.Exec(
-		"INSERT INTO users  (email, name, password) VALUES ($1, $2, $3);",
-		u.Email,
-		u.Name,
-		string(pwd),
-	)
-	return err
+	u.Password = string(pwd)
+	u.Id, err = store.Users().Create(u)
+	if err != nil {
+		return err
+	}
+	if err := SendVerificationEmail(u.Id); err != nil {
+		fmt.Printf("⚠️ Warning: could not send verification email to user %v: %v\n", u.Id, err.Error())
+	}
+	return nil
+}
+
+// Mails a verification link to the user's
+// current email address
+func SendVerificationEmail(uid int) error {
+	u, err := store.Users().Get(uid)
+	if err != nil {
+		return err
+	}
+	if u.Verified {
+		return nil
+	}
+	token, err := newUserToken(u.Id, EmailVerificationToken, conf.Duration("EMAIL_VERIFICATION_TTL", 48*time.Hour))
+	if err != nil {
+		return err
+	}
+	return mail.Send(mail.Message{
+		To:      u.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf(
+			"Hi %v,\n\nPlease confirm your email address using the following link:\n\n%v",
+			u.Name,
+			tokenLink("API_URL", "/id/verify", token),
+		),
+	})
+}
+
+// Marks the user the token was issued for as verified
+func VerifyEmail(token string) error {
+	return store.Tx(func(s Store) error {
+		uid, err := consumeUserToken(s, token, EmailVerificationToken)
+		if err != nil {
+			return err
+		}
+		return s.Users().SetVerified(uid)
+	})
+}
+
+func IsVerified(uid int) (bool, error) {
+	u, err := store.Users().Get(uid)
+	return u.Verified, err
 }
 
-func (u *User) Login() (string, error) {
+// Checks the user's credentials and opens a new session
+func (u *User) Login() (TokenPair, error) {
 	if u.Email == "" || u.Password == "" {
-		return "", errors.New("missing required struct fields (email, password)")
+		return TokenPair{}, errors.New("missing required struct fields (email, password)")
 	}
 	pwd := []byte(u.Password)
-	err := db.Client.Client.Get(u, "SELECT * FROM users WHERE email = $1;", u.Email)
+	found, err := store.Users().GetByEmail(u.Email)
 	if err != nil {
-		return "", err
+		return TokenPair{}, err
 	}
+	*u = found
 	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), pwd); err != nil {
-		return "", fmt.Errorf("invalid password: %v", err.Error())
+		return TokenPair{}, fmt.Errorf("invalid password: %v", err.Error())
 	}
-	claims := jwt.StandardClaims{
-		Issuer:    "a01028653@tec.mx",
-		IssuedAt:  time.Now().Unix(),
-		ExpiresAt: time.Now().Add(time.Duration(48 * time.Hour)).Unix(),
-		Subject:   strconv.Itoa(u.Id),
+	tokens, err := NewSession(u.Id, u.Role)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("could not issue token: %v", err.Error())
 	}
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).
-		SignedString([]byte(os.Getenv("AUTH_KEY")))
+	err = store.Users().SetLastLogin(u.Id, time.Now())
 	if err != nil {
-		return "", fmt.Errorf("could not issue token: %v", err.Error())
+		return tokens, fmt.Errorf("token created, failed to update last login: %v", err.Error())
+	}
+	return tokens, nil
+}
+
+// Mails a password reset link to the user with the given
+// email. Unknown emails are ignored so the endpoint can't
+// be used to find out who is registered.
+func RequestPasswordReset(email string) error {
+	if email == "" {
+		return errors.New("missing required param email")
+	}
+	u, err := store.Users().GetByEmail(email)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	token, err := newUserToken(u.Id, PasswordResetToken, conf.Duration("PASSWORD_RESET_TTL", time.Hour))
+	if err != nil {
+		return err
+	}
+	return mail.Send(mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %v,\n\nUse the following link to choose a new password:\n\n%v\n\nIf you didn't ask for a password reset you can ignore this email.",
+			u.Name,
+			tokenLink("APP_URL", "/reset-password", token),
+		),
+	})
+}
+
+// Sets a new password using a reset token. All
+// sessions of the user are revoked.
+func ResetPassword(token, password string) error {
+	if password == "" {
+		return errors.New("missing required param password")
 	}
-	_, err = db.Client.Client.Exec("UPDATE users SET last_login = $1 WHERE id = $2;", time.Now(), u.Id)
+	pwd, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return token, fmt.Errorf("token created, failed to update last login: %v", err.Error())
+		return fmt.Errorf("could not hash password: %v", err.Error())
 	}
-	return token, nil
+	return store.Tx(func(s Store) error {
+		uid, err := consumeUserToken(s, token, PasswordResetToken)
+		if err != nil {
+			return err
+		}
+		if err := s.Users().SetPassword(uid, string(pwd)); err != nil {
+			return err
+		}
+		return s.Sessions().RevokeAll(uid)
+	})
+}
+
+// Changes the role of a user. The user's sessions
+// are revoked so the new role applies right away.
+func SetUserRole(uid int, role string) error {
+	if !IsValidRole(role) {
+		return fmt.Errorf("invalid role %v", role)
+	}
+	return store.Tx(func(s Store) error {
+		if err := s.Users().SetRole(uid, role); err != nil {
+			return err
+		}
+		return s.Sessions().RevokeAll(uid)
+	})
 }
 
 func GetUser(id string) (UserData, error) {
@@ -124,20 +234,22 @@ func GetUser(id string) (UserData, error) {
 	if err != nil {
 		return UserData{}, err
 	}
-	u := User{}
-	err = db.Client.Client.Get(&u, "SELECT * FROM users WHERE id = $1;", uid)
+	u, err := store.Users().Get(uid)
 	return u.ToUserData(), err
 }
 
-func QueryUserFTS(query string) ([]UserData, error) {
-	u := []User{}
-	err := db.Client.Client.Select(&u, "SELECT * FROM query_gin(null::users, $1);", query)
+func QueryUserFTS(query, lang string, p Page) ([]UserData, string, error) {
+	lang, err := queryLanguage(lang)
+	if err != nil {
+		return nil, "", err
+	}
+	u, next, err := store.Users().Query(query, lang, p)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	res := make([]UserData, 0, len(u))
 	for _, v := range u {
 		res = append(res, v.ToUserData())
 	}
-	return res, nil
+	return res, next, nil
 }
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	PasswordResetToken     = "password_reset"
+	EmailVerificationToken = "email_verify"
+)
+
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// Creates a single use token for the user. Unused
+// tokens previously issued for the same purpose
+// stop being valid.
+func newUserToken(uid int, purpose string, ttl time.Duration) (string, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("could not create token: %v", err.Error())
+	}
+	err = store.Tx(func(s Store) error {
+		if err := s.UserTokens().Invalidate(uid, purpose); err != nil {
+			return err
+		}
+		return s.UserTokens().Create(uid, purpose, hashToken(token), ttl)
+	})
+	return token, err
+}
+
+// Marks the token as used and returns
+// the uid it was issued for
+func consumeUserToken(s Store, token, purpose string) (int, error) {
+	if token == "" {
+		return 0, ErrInvalidUserToken
+	}
+	uid, err := s.UserTokens().Consume(hashToken(token), purpose)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidUserToken
+	}
+	return uid, err
+}
+
+// Builds a link for a mailed token using the base url
+// in the baseKey config parameter (APP_URL for the web
+// app, API_URL for this API). Falls back to the bare
+// token if the parameter is not set.
+func tokenLink(baseKey, path, token string) string {
+	base := os.Getenv(baseKey)
+	if base == "" {
+		return token
+	}
+	return fmt.Sprintf("%v%v?token=%v", base, path, token)
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// A course published for a level. Versions are numbered
+// from 1 and never change, publishing or rolling back
+// the level adds a new one.
+type LevelVersion struct {
+	LevelId    int                    `db:"level_id" json:"levelId"`
+	Version    int                    `db:"version" json:"version"`
+	CourseData map[string]interface{} `db:"-" json:"courseData,omitempty"`
+	// The version a rollback republished
+	RestoredFrom *int      `db:"restored_from" json:"restoredFrom,omitempty"`
+	Created      time.Time `db:"created" json:"created"`
+}
+
+var ErrVersionNotFound = errors.New("version not found")
+
+// Creates the course data of a new level,
+// which is its first version
+func createCourse(s Store, levelId int, cd map[string]interface{}) error {
+	if err := s.Levels().CreateCourseData(&CourseData{LevelId: levelId, MapData: cd}); err != nil {
+		return err
+	}
+	_, err := s.Versions().Create(&LevelVersion{LevelId: levelId, CourseData: cd})
+	return err
+}
+
+// Replaces the course of the level with cd, saving it as
+// a new version, and returns the version's number. Also
+// deletes the level's draft.
+func publish(s Store, levelId int, cd map[string]interface{}, restoredFrom *int) (int, error) {
+	if err := s.Levels().UpdateCourseData(&CourseData{LevelId: levelId, MapData: cd}); err != nil {
+		return 0, err
+	}
+	return s.Versions().Create(&LevelVersion{LevelId: levelId, CourseData: cd, RestoredFrom: restoredFrom})
+}
+
+// Returns a page of the versions of the level, newest
+// first, without their course data
+func GetLevelVersions(levelId int, v Viewer, p Page) ([]LevelVersion, string, error) {
+	if _, err := readableLevel(store, levelId, v); err != nil {
+		return nil, "", err
+	}
+	return store.Versions().List(levelId, p)
+}
+
+func getVersion(levelId, version int) (LevelVersion, error) {
+	res, err := store.Versions().Get(levelId, version)
+	if err == sql.ErrNoRows {
+		return LevelVersion{}, ErrVersionNotFound
+	}
+	return res, err
+}
+
+// Returns the version of the level with its course data
+func GetLevelVersion(levelId, version int, v Viewer) (LevelVersion, error) {
+	if _, err := readableLevel(store, levelId, v); err != nil {
+		return LevelVersion{}, err
+	}
+	return getVersion(levelId, version)
+}
+
+// The changes from version from to version to
+// of the course of the level, see DiffJSON
+func DiffLevelVersions(levelId, from, to int, v Viewer) ([]Change, error) {
+	if _, err := readableLevel(store, levelId, v); err != nil {
+		return nil, err
+	}
+	a, err := getVersion(levelId, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := getVersion(levelId, to)
+	if err != nil {
+		return nil, err
+	}
+	return DiffJSON(a.CourseData, b.CourseData), nil
+}
+
+// Publishes the course of an old version of the level
+// owned by uid again, as a new version, if the level is
+// still at revision (0 matches any). Returns the number
+// of the new version and the new revision of the level.
+func RollbackLevel(levelId, uid, version, revision int) (int, int, error) {
+	var res int
+	level := Level{Id: levelId, Uid: uid, Revision: revision}
+	err := store.Tx(func(s Store) error {
+		old, err := s.Versions().Get(levelId, version)
+		if err == sql.ErrNoRows {
+			return ErrVersionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		// Sets the updated time and bumps the revision
+		err = s.Levels().Update(&level)
+		if err == sql.ErrNoRows && revision != 0 {
+			if owner, err := s.Levels().Owner(levelId); err == nil && owner == uid {
+				return ErrRevisionConflict
+			}
+		}
+		if err != nil {
+			return err
+		}
+		res, err = publish(s, levelId, old.CourseData, &version)
+		return err
+	})
+	return res, level.Revision, err
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+// Who can read a level. Only public levels are listed,
+// searched and trending. Unlisted levels can be read with
+// their share link, and private ones only by their creator.
+// Moderators can read every level.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+var (
+	ErrInvalidVisibility = errors.New("visibility must be public, unlisted or private")
+	// Also returned for levels the user can't read,
+	// so their existence isn't revealed
+	ErrLevelNotFound = errors.New("level not found")
+	ErrNotUnlisted   = errors.New("only unlisted levels have share links")
+)
+
+func ValidVisibility(v string) bool {
+	return v == VisibilityPublic || v == VisibilityUnlisted || v == VisibilityPrivate
+}
+
+// The user reading content
+type Viewer struct {
+	Uid       int
+	Moderator bool
+}
+
+func (c *Claims) Viewer() Viewer {
+	uid, _ := strconv.Atoi(c.Subject)
+	return Viewer{Uid: uid, Moderator: c.CanModerate()}
+}
+
+// Whether v can read the level without a share link
+func (l *Level) VisibleTo(v Viewer) bool {
+	return l.Visibility == VisibilityPublic || l.Uid == v.Uid || v.Moderator
+}
+
+// Returns the level if v can read it, ErrLevelNotFound
+// otherwise. Used by everything scoped to a level.
+func readableLevel(s Store, levelId int, v Viewer) (Level, error) {
+	l, err := s.Levels().GetInfo(levelId)
+	if err == sql.ErrNoRows || (err == nil && !l.VisibleTo(v)) {
+		return Level{}, ErrLevelNotFound
+	}
+	return l, err
+}
+
+// Returns ErrLevelNotFound unless v can read the level
+func CheckLevelReadable(levelId int, v Viewer) error {
+	_, err := readableLevel(store, levelId, v)
+	return err
+}
+
+// Share tokens are stored as is, unlike session tokens,
+// so owners can see the link again. Anyone with it can
+// only read the level.
+func newShareToken(visibility string) (string, error) {
+	if visibility != VisibilityUnlisted {
+		return "", nil
+	}
+	return newOpaqueToken()
+}
+
+// The link the web app opens an unlisted level with
+func ShareLink(token string) string {
+	return tokenLink("APP_URL", "/levels/shared", token)
+}
+
+// Returns the unlisted level the share token was made for
+func GetSharedLevel(token string) (Level, error) {
+	l, err := store.Levels().GetByShareToken(token)
+	if err != nil {
+		return Level{}, err
+	}
+	l.Tags, err = store.Tags().LevelTags(l.Id)
+	return l, err
+}
+
+// Replaces the share token of the unlisted level, so
+// the old link stops working
+func ResetShareToken(levelId int) (string, error) {
+	l, err := store.Levels().GetInfo(levelId)
+	if err != nil {
+		return "", err
+	}
+	if l.Visibility != VisibilityUnlisted {
+		return "", ErrNotUnlisted
+	}
+	token, err := newShareToken(l.Visibility)
+	if err != nil {
+		return "", err
+	}
+	return token, store.Levels().SetShareToken(levelId, token)
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	// Weight of the average of every rating in a level's
+	// Bayesian rating, in number of ratings
+	RatingPriorWeight = 5
+	// Average used as prior when nothing is rated yet
+	DefaultRatingMean = 3
+)
+
+var (
+	ErrEmptyVote    = errors.New("a vote needs liked or stars")
+	ErrInvalidStars = errors.New("stars must be between 1 and 5")
+)
+
+// A user's vote on a level. Liked and Stars
+// are nil if the user didn't give them.
+type LevelVote struct {
+	LevelId int          `db:"level_id" json:"levelId"`
+	Uid     int          `db:"uid" json:"uid"`
+	Liked   *bool        `db:"liked" json:"liked"`
+	Stars   *int         `db:"stars" json:"stars"`
+	Created time.Time    `db:"created" json:"created"`
+	Updated sql.NullTime `db:"updated" json:"updated"`
+}
+
+// Aggregates of a level's votes
+type LevelRating struct {
+	Likes    int `db:"likes" json:"likes"`
+	Dislikes int `db:"dislikes" json:"dislikes"`
+	// Number of star ratings
+	Ratings int     `db:"ratings" json:"ratings"`
+	Average float64 `db:"avg_stars" json:"average"`
+	// Bayesian average of the stars, see BayesianRating
+	Score float64 `db:"score" json:"score"`
+}
+
+// The average of a level's ratings pulled towards mean,
+// the average of every rating, so levels with only a few
+// ratings don't outrank well rated popular ones. Same as
+// the bayesian_rating SQL function.
+func BayesianRating(ratings int, avg, mean float64) float64 {
+	return (RatingPriorWeight*mean + float64(ratings)*avg) / float64(RatingPriorWeight+ratings)
+}
+
+// Creates or changes the user's vote. Only the parts
+// of the vote that are set are changed. Returns
+// ErrLevelNotFound if viewer can't read the level.
+func (v *LevelVote) Save(viewer Viewer) error {
+	if v.LevelId == 0 || v.Uid == 0 {
+		return errors.New("missing required fields LevelId, Uid")
+	}
+	if v.Liked == nil && v.Stars == nil {
+		return ErrEmptyVote
+	}
+	if v.Stars != nil && (*v.Stars < 1 || *v.Stars > 5) {
+		return ErrInvalidStars
+	}
+	return store.Tx(func(s Store) error {
+		if _, err := readableLevel(s, v.LevelId, viewer); err != nil {
+			return err
+		}
+		return s.Votes().Set(v)
+	})
+}
+
+// Returns the vote of v on the level
+func GetLevelVote(levelId int, v Viewer) (LevelVote, error) {
+	if _, err := readableLevel(store, levelId, v); err != nil {
+		return LevelVote{}, err
+	}
+	return store.Votes().Get(levelId, v.Uid)
+}
+
+// Deletes the vote of v on the level
+func DeleteLevelVote(levelId int, v Viewer) error {
+	if levelId == 0 || v.Uid == 0 {
+		return errors.New("missing required fields levelId, uid")
+	}
+	if _, err := readableLevel(store, levelId, v); err != nil {
+		return err
+	}
+	return store.Votes().Delete(levelId, v.Uid)
+}
+
+func GetLevelRating(levelId int) (LevelRating, error) {
+	return store.Votes().Rating(levelId)
+}
@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type SetRoleParams struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func SetUserRole(c *gin.Context) {
+	p := c.Param("id")
+	uid, err := strconv.Atoi(p)
+	if err != nil || uid == 0 {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	params := SetRoleParams{}
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.IsValidRole(params.Role) {
+		c.JSON(400, gin.H{"error": "invalid role"})
+		return
+	}
+	err = models.SetUserRole(uid, params.Role)
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
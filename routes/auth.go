@@ -4,7 +4,6 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
 	"github.com/sofferjacob/maker_api/models"
 	"github.com/sofferjacob/maker_api/tracking"
 )
@@ -24,12 +23,18 @@ func Login(c *gin.Context) {
 		Email:    params.Email,
 		Password: params.Password,
 	}
-	token, err := u.Login()
+	tokens, err := u.Login()
 	if err != nil {
 		c.JSON(500, gin.H{"error": "could not authenticate user", "message": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "ok", "token": token, "user": u.ToUserData()})
+	c.JSON(200, gin.H{
+		"status":       "ok",
+		"token":        tokens.Token,
+		"refreshToken": tokens.RefreshToken,
+		"expiresAt":    tokens.ExpiresAt,
+		"user":         u.ToUserData(),
+	})
 	event := tracking.Event{
 		EventType: "user_login",
 		Uid:       u.Id,
@@ -40,6 +45,8 @@ func Login(c *gin.Context) {
 type RegisterParams struct {
 	LoginParams
 	Name string `json:"name" binding:"required"`
+	// The language the name is searched in
+	Language string `json:"language" binding:"omitempty,oneof=es en pt"`
 }
 
 func Register(c *gin.Context) {
@@ -52,6 +59,7 @@ func Register(c *gin.Context) {
 		Name:     params.Name,
 		Email:    params.Email,
 		Password: params.Password,
+		Language: params.Language,
 	}
 	err := u.Register()
 	if err != nil {
@@ -71,7 +79,7 @@ func Profile(c *gin.Context) {
 		c.JSON(403, gin.H{"error": "forbidden"})
 		return
 	}
-	claims, ok := claimsObj.(*jwt.StandardClaims)
+	claims, ok := claimsObj.(*models.Claims)
 	if !ok {
 		c.JSON(500, gin.H{"error": "invalid claims"})
 		return
@@ -85,8 +93,9 @@ func Profile(c *gin.Context) {
 }
 
 type UpdateProfileParams struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Language string `json:"language" binding:"omitempty,oneof=es en pt"`
 }
 
 func UpdateProfile(c *gin.Context) {
@@ -95,7 +104,7 @@ func UpdateProfile(c *gin.Context) {
 		c.JSON(403, gin.H{"error": "forbidden"})
 		return
 	}
-	claims, ok := claimsObj.(*jwt.StandardClaims)
+	claims, ok := claimsObj.(*models.Claims)
 	if !ok {
 		c.JSON(500, gin.H{"error": "invalid claims"})
 		return
@@ -111,9 +120,10 @@ func UpdateProfile(c *gin.Context) {
 		return
 	}
 	u := models.User{
-		Id:    id,
-		Name:  params.Name,
-		Email: params.Email,
+		Id:       id,
+		Name:     params.Name,
+		Email:    params.Email,
+		Language: params.Language,
 	}
 	err = u.Update()
 	if err != nil {
@@ -122,3 +132,126 @@ func UpdateProfile(c *gin.Context) {
 	}
 	c.JSON(200, gin.H{"status": "ok"})
 }
+
+type RefreshParams struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+func Refresh(c *gin.Context) {
+	params := RefreshParams{}
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	tokens, _, err := models.RefreshSession(params.RefreshToken)
+	if err == models.ErrInvalidRefreshToken {
+		c.JSON(403, gin.H{"error": "Forbidden", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "could not refresh session", "message": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"status":       "ok",
+		"token":        tokens.Token,
+		"refreshToken": tokens.RefreshToken,
+		"expiresAt":    tokens.ExpiresAt,
+	})
+}
+
+// Revokes the session of the token used
+// to call this endpoint
+func Logout(c *gin.Context) {
+	claims := getClaims(c)
+	uid, _ := strconv.Atoi(claims.Subject)
+	sessionId, _ := strconv.Atoi(claims.Id)
+	err := models.RevokeSession(sessionId, uid)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// Revokes every session of the user
+func LogoutAll(c *gin.Context) {
+	claims := getClaims(c)
+	uid, _ := strconv.Atoi(claims.Subject)
+	err := models.RevokeUserSessions(uid)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+type ForgotPasswordParams struct {
+	Email string `json:"email" binding:"required"`
+}
+
+func ForgotPassword(c *gin.Context) {
+	params := ForgotPasswordParams{}
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	err := models.RequestPasswordReset(params.Email)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "could not request password reset", "message": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+type ResetPasswordParams struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func ResetPassword(c *gin.Context) {
+	params := ResetPasswordParams{}
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	err := models.ResetPassword(params.Token, params.Password)
+	if err == models.ErrInvalidUserToken {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "could not reset password", "message": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+func VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(400, gin.H{"error": "missing required parameter token"})
+		return
+	}
+	err := models.VerifyEmail(token)
+	if err == models.ErrInvalidUserToken {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "could not verify email", "message": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+func ResendVerification(c *gin.Context) {
+	claims := getClaims(c)
+	uid, _ := strconv.Atoi(claims.Subject)
+	err := models.SendVerificationEmail(uid)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "could not send verification email", "message": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+	"github.com/sofferjacob/maker_api/tracking"
+)
+
+// Reads the draft id of the path, aborting the
+// request unless it is a draft of the user
+func getCheckpointDraft(c *gin.Context) (int, int, bool) {
+	uid, _ := strconv.Atoi(getClaims(c).Subject)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id == 0 {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return 0, 0, false
+	}
+	if _, ok := getOwnDraft(c, id, uid); !ok {
+		return 0, 0, false
+	}
+	return id, uid, true
+}
+
+// Like getCheckpointDraft, also reading the
+// checkpoint id
+func getCheckpointParams(c *gin.Context) (int, int, int, bool) {
+	checkpoint, err := strconv.Atoi(c.Param("checkpoint"))
+	if err != nil || checkpoint < 1 {
+		c.JSON(400, gin.H{"error": "invalid checkpoint"})
+		return 0, 0, 0, false
+	}
+	id, uid, ok := getCheckpointDraft(c)
+	return id, uid, checkpoint, ok
+}
+
+// Responds with the errors of the checkpoint functions
+func checkpointError(c *gin.Context, err error) {
+	if err == models.ErrCheckpointNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(500, gin.H{"error": err.Error()})
+}
+
+func GetDraftCheckpoints(c *gin.Context) {
+	id, _, ok := getCheckpointDraft(c)
+	if !ok {
+		return
+	}
+	page, ok := getPage(c)
+	if !ok {
+		return
+	}
+	checkpoints, next, err := models.GetDraftCheckpoints(id, page)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "checkpoints": checkpoints, "nextCursor": next})
+}
+
+type CreateCheckpointParams struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// Saves the current course of the draft as a named checkpoint
+func CreateCheckpoint(c *gin.Context) {
+	params := CreateCheckpointParams{}
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	id, _, ok := getCheckpointDraft(c)
+	if !ok {
+		return
+	}
+	checkpoint, err := models.CreateCheckpoint(id, params.Name)
+	if err == models.ErrTooManyCheckpoints {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "id": checkpoint.Id})
+}
+
+func GetDraftCheckpoint(c *gin.Context) {
+	id, _, checkpoint, ok := getCheckpointParams(c)
+	if !ok {
+		return
+	}
+	res, err := models.GetDraftCheckpoint(id, checkpoint)
+	if err != nil {
+		checkpointError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "checkpoint": res})
+}
+
+// Replaces the course of the draft with the checkpoint's
+func RestoreCheckpoint(c *gin.Context) {
+	id, uid, checkpoint, ok := getCheckpointParams(c)
+	if !ok {
+		return
+	}
+	revision, err := models.RestoreCheckpoint(id, uid, checkpoint)
+	if err != nil {
+		checkpointError(c, err)
+		return
+	}
+	c.Header("ETag", revisionETag(revision))
+	c.JSON(200, gin.H{"status": "ok", "revision": revision})
+	event := tracking.Event{
+		EventType: "draft_update",
+		Uid:       uid,
+		DraftId:   id,
+	}
+	event.Send()
+}
+
+func DeleteCheckpoint(c *gin.Context) {
+	id, _, checkpoint, ok := getCheckpointParams(c)
+	if !ok {
+		return
+	}
+	if err := models.DeleteCheckpoint(id, checkpoint); err != nil {
+		checkpointError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
@@ -10,6 +10,8 @@ import (
 type CreateCollectionParams struct {
 	Name        string `json:"name" binding:"required"`
 	Description string `json:"description"`
+	// Defaults to the language of the creator
+	Language string `json:"language" binding:"omitempty,oneof=es en pt"`
 }
 
 func CreateCollection(c *gin.Context) {
@@ -24,6 +26,7 @@ func CreateCollection(c *gin.Context) {
 		Name:        params.Name,
 		Description: params.Description,
 		Uid:         uid,
+		Language:    params.Language,
 	}
 	id, err := collection.Create()
 	if err != nil {
@@ -55,12 +58,16 @@ func GetUserCollections(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "invalid id"})
 		return
 	}
-	res, err := models.GetUserCollections(uid)
+	page, ok := getPage(c)
+	if !ok {
+		return
+	}
+	res, next, err := models.GetUserCollections(uid, page)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "ok", "collections": res})
+	c.JSON(200, gin.H{"status": "ok", "collections": res, "nextCursor": next})
 }
 
 func DeleteCollection(c *gin.Context) {
@@ -71,10 +78,18 @@ func DeleteCollection(c *gin.Context) {
 		return
 	}
 	claims := getClaims(c)
-	uid, _ := strconv.Atoi(claims.Subject)
+	owner, err := models.GetCollectionOwner(id)
+	if err != nil {
+		ownerError(c, err)
+		return
+	}
+	if !canManage(claims, owner) {
+		c.JSON(403, gin.H{"error": "forbidden"})
+		return
+	}
 	collection := models.Collection{
 		Id:  id,
-		Uid: uid,
+		Uid: owner,
 	}
 	err = collection.Delete()
 	if err != nil {
@@ -88,6 +103,7 @@ type UpdateCollectionParams struct {
 	Id          int    `json:"id" binding:"required"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	Language    string `json:"language" binding:"omitempty,oneof=es en pt"`
 }
 
 func UpdateCollection(c *gin.Context) {
@@ -97,14 +113,23 @@ func UpdateCollection(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	uid, _ := strconv.Atoi(claims.Subject)
+	owner, err := models.GetCollectionOwner(params.Id)
+	if err != nil {
+		ownerError(c, err)
+		return
+	}
+	if !canManage(claims, owner) {
+		c.JSON(403, gin.H{"error": "forbidden"})
+		return
+	}
 	collection := models.Collection{
 		Id:          params.Id,
-		Uid:         uid,
+		Uid:         owner,
 		Name:        params.Name,
 		Description: params.Description,
+		Language:    params.Language,
 	}
-	err := collection.Update()
+	err = collection.Update()
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -112,18 +137,21 @@ func UpdateCollection(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "ok"})
 }
 
+func AutocompleteCollections(c *gin.Context) {
+	autocomplete(c, models.AutocompleteCollections)
+}
+
 func QueryCollections(c *gin.Context) {
-	params := QueryFTSParams{}
-	if err := c.ShouldBindJSON(&params); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	params, p, ok := bindQuery(c)
+	if !ok {
 		return
 	}
-	col, err := models.QueryCollectionsFTS(params.Query)
+	col, next, err := models.QueryCollectionsFTS(params.Query, params.Language, p)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "ok", "results": col})
+	c.JSON(200, gin.H{"status": "ok", "results": col, "nextCursor": next})
 }
 
 type LinkLevelParams struct {
@@ -133,18 +161,17 @@ type LinkLevelParams struct {
 
 func LinkLevel(c *gin.Context) {
 	claims := getClaims(c)
-	uid, _ := strconv.Atoi(claims.Subject)
 	params := LinkLevelParams{}
 	if err := c.ShouldBindJSON(&params); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	isOwn, err := models.IsOwnCollection(params.CollectionId, uid)
+	owner, err := models.GetCollectionOwner(params.CollectionId)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		ownerError(c, err)
 		return
 	}
-	if !isOwn {
+	if !canManage(claims, owner) {
 		c.JSON(403, gin.H{"error": "forbidden"})
 		return
 	}
@@ -162,18 +189,17 @@ func LinkLevel(c *gin.Context) {
 
 func UnlinkLevel(c *gin.Context) {
 	claims := getClaims(c)
-	uid, _ := strconv.Atoi(claims.Subject)
 	params := LinkLevelParams{}
 	if err := c.ShouldBindJSON(&params); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	isOwn, err := models.IsOwnCollection(params.CollectionId, uid)
+	owner, err := models.GetCollectionOwner(params.CollectionId)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		ownerError(c, err)
 		return
 	}
-	if !isOwn {
+	if !canManage(claims, owner) {
 		c.JSON(403, gin.H{"error": "forbidden"})
 		return
 	}
@@ -196,12 +222,16 @@ func GetCollectionLevels(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "invalid id"})
 		return
 	}
-	levels, err := models.GetCollectionLevels(collecionId)
+	page, ok := getPage(c)
+	if !ok {
+		return
+	}
+	levels, next, err := models.GetCollectionLevels(collecionId, page)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "ok", "levels": levels})
+	c.JSON(200, gin.H{"status": "ok", "levels": levels, "nextCursor": next})
 }
 
 func TrendingCollections(c *gin.Context) {
@@ -0,0 +1,201 @@
+package routes
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type CreateCommentParams struct {
+	LevelId      *int   `json:"levelId"`
+	CollectionId *int   `json:"collectionId"`
+	ParentId     *int   `json:"parentId"`
+	Body         string `json:"body" binding:"required,max=2000"`
+}
+
+func CreateComment(c *gin.Context) {
+	claims := getClaims(c)
+	uid, _ := strconv.Atoi(claims.Subject)
+	params := CreateCommentParams{}
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	comment := models.Comment{
+		Uid:          uid,
+		LevelId:      params.LevelId,
+		CollectionId: params.CollectionId,
+		ParentId:     params.ParentId,
+		Body:         params.Body,
+	}
+	id, err := comment.Create(claims.Viewer())
+	if err == models.ErrLevelNotFound || err == models.ErrCollectionNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err == models.ErrCommentTarget {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "parent comment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "id": id})
+}
+
+// Reads the comment in the id param, aborting
+// the request if it doesn't exist
+func getComment(c *gin.Context) (models.CommentData, bool) {
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return models.CommentData{}, false
+	}
+	comment, err := models.GetComment(id, getClaims(c).Viewer())
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "comment not found"})
+		return comment, false
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return comment, false
+	}
+	return comment, true
+}
+
+func GetComment(c *gin.Context) {
+	comment, ok := getComment(c)
+	if !ok {
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "comment": comment})
+}
+
+type UpdateCommentParams struct {
+	Body string `json:"body" binding:"required,max=2000"`
+}
+
+// Only the author can edit a comment
+func UpdateComment(c *gin.Context) {
+	claims := getClaims(c)
+	uid, _ := strconv.Atoi(claims.Subject)
+	params := UpdateCommentParams{}
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	comment, ok := getComment(c)
+	if !ok {
+		return
+	}
+	if comment.Uid != uid {
+		c.JSON(403, gin.H{"error": "forbidden"})
+		return
+	}
+	update := models.Comment{Id: comment.Id, Uid: uid, Body: params.Body}
+	if err := update.Update(); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// Comments can be deleted by their author, the owner
+// of the level or collection they are on and moderators
+func DeleteComment(c *gin.Context) {
+	claims := getClaims(c)
+	uid, _ := strconv.Atoi(claims.Subject)
+	comment, ok := getComment(c)
+	if !ok {
+		return
+	}
+	if comment.Uid != uid {
+		owner, err := comment.TargetOwner()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if !canManage(claims, owner) {
+			c.JSON(403, gin.H{"error": "forbidden"})
+			return
+		}
+	}
+	if err := models.DeleteComment(comment.Id); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// Responds with the page of comments listed by fn
+// for the id param
+func listComments(c *gin.Context, fn func(id int, p models.Page) ([]models.CommentData, string, error)) {
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	page, ok := getPage(c)
+	if !ok {
+		return
+	}
+	res, next, err := fn(id, page)
+	if err == models.ErrLevelNotFound || err == models.ErrCollectionNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "comment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "comments": res, "nextCursor": next})
+}
+
+func GetLevelComments(c *gin.Context) {
+	v := getClaims(c).Viewer()
+	listComments(c, func(id int, p models.Page) ([]models.CommentData, string, error) {
+		return models.GetLevelComments(id, v, p)
+	})
+}
+
+func GetCollectionComments(c *gin.Context) {
+	listComments(c, func(id int, p models.Page) ([]models.CommentData, string, error) {
+		if _, err := models.GetCollectionOwner(id); err != nil {
+			return nil, "", err
+		}
+		return models.GetCollectionComments(id, p)
+	})
+}
+
+func GetCommentReplies(c *gin.Context) {
+	v := getClaims(c).Viewer()
+	listComments(c, func(id int, p models.Page) ([]models.CommentData, string, error) {
+		return models.GetCommentReplies(id, v, p)
+	})
+}
+
+func QueryComments(c *gin.Context) {
+	params, p, ok := bindQuery(c)
+	if !ok {
+		return
+	}
+	res, next, err := models.QueryCommentsFTS(params.Query, params.Language, p)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "results": res, "nextCursor": next})
+}
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+func GetDifficultyVote(c *gin.Context) {
+	claims := getClaims(c)
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	vote, err := models.GetDifficultyVote(id, claims.Viewer())
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "vote not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "vote": vote})
+}
+
+type DifficultyVoteParams struct {
+	Difficulty int `json:"difficulty" binding:"required,min=1,max=5"`
+}
+
+// Only players who finished the level can vote,
+// the creator can't
+func VoteDifficulty(c *gin.Context) {
+	claims := getClaims(c)
+	uid, _ := strconv.Atoi(claims.Subject)
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	params := DifficultyVoteParams{}
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	vote := models.DifficultyVote{LevelId: id, Uid: uid, Difficulty: params.Difficulty}
+	err = vote.Save(claims.Viewer())
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err == models.ErrNotFinished || err == models.ErrOwnLevelVote {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	if err == models.ErrInvalidDifficulty {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	difficulty, err := models.GetLevelDifficulty(id)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "communityDifficulty": difficulty})
+}
+
+func DeleteDifficultyVote(c *gin.Context) {
+	claims := getClaims(c)
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	err = models.DeleteDifficultyVote(id, claims.Viewer())
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "vote not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
@@ -1,10 +1,12 @@
 package routes
 
 import (
-	"fmt"
+	"database/sql"
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/sofferjacob/maker_api/models"
 	"github.com/sofferjacob/maker_api/tracking"
 )
@@ -57,6 +59,9 @@ type UpdateDraftParams struct {
 	Id         int                    `json:"id" binding:"required"`
 	Car        int                    `json:"car"`
 	Soundtrack int                    `json:"soundtrack"`
+	// The revision being updated, if there
+	// is no If-Match header
+	Revision int `json:"revision" binding:"omitempty,min=1"`
 }
 
 func UpdateDraft(c *gin.Context) {
@@ -67,6 +72,10 @@ func UpdateDraft(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	revision, ok := getRevision(c, params.Revision)
+	if !ok {
+		return
+	}
 	draft := models.Draft{
 		Id:         params.Id,
 		Name:       params.Name,
@@ -75,13 +84,29 @@ func UpdateDraft(c *gin.Context) {
 		Uid:        uid,
 		Car:        params.Car,
 		Soundtrack: params.Soundtrack,
+		Revision:   revision,
 	}
 	err := draft.Update()
+	if err == models.ErrRevisionConflict {
+		current := models.Draft{Id: params.Id}
+		if err := current.Get(); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("ETag", revisionETag(current.Revision))
+		c.JSON(409, gin.H{"error": err.Error(), "draft": current})
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "draft not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "ok"})
+	c.Header("ETag", revisionETag(draft.Revision))
+	c.JSON(200, gin.H{"status": "ok", "revision": draft.Revision})
 	event := tracking.Event{
 		EventType: "draft_update",
 		Uid:       uid,
@@ -113,6 +138,7 @@ func GetDraft(c *gin.Context) {
 		c.JSON(403, gin.H{"error": "forbidden"})
 		return
 	}
+	c.Header("ETag", revisionETag(draft.Revision))
 	c.JSON(200, gin.H{"status": "ok", "draft": draft})
 }
 
@@ -139,6 +165,7 @@ func GetDraftFromLevelId(c *gin.Context) {
 		c.JSON(403, gin.H{"error": "forbidden"})
 		return
 	}
+	c.Header("ETag", revisionETag(draft.Revision))
 	c.JSON(200, gin.H{"status": "ok", "draft": draft})
 }
 
@@ -176,28 +203,132 @@ func DeleteDraft(c *gin.Context) {
 func GetUserDrafts(c *gin.Context) {
 	claims := getClaims(c)
 	uid, _ := strconv.Atoi(claims.Subject)
-	drafts, err := models.GetUserDrafts(uid)
+	page, ok := getPage(c)
+	if !ok {
+		return
+	}
+	drafts, next, err := models.GetUserDrafts(uid, page)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "ok", "drafts": drafts})
+	c.JSON(200, gin.H{"status": "ok", "drafts": drafts, "nextCursor": next})
 }
 
 func GetLevelDraft(c *gin.Context) {
-	fmt.Println("called here")
 	claims := getClaims(c)
-	uid, _ := strconv.Atoi(claims.Subject)
 	param := c.Param("id")
 	levelId, err := strconv.Atoi(param)
 	if param == "" || err != nil {
 		c.JSON(400, gin.H{"error": "invalid level id"})
 		return
 	}
-	draft, err := models.GetLevelDraft(levelId, uid)
+	draft, err := models.GetLevelDraft(levelId, claims.Viewer())
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
+	c.Header("ETag", revisionETag(draft.Revision))
 	c.JSON(200, gin.H{"status": "ok", "draft": draft})
 }
+
+// Returns the draft if it belongs to uid,
+// aborting the request otherwise
+func getOwnDraft(c *gin.Context, id, uid int) (models.Draft, bool) {
+	draft := models.Draft{Id: id}
+	err := draft.Get()
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "draft not found"})
+		return draft, false
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return draft, false
+	}
+	if draft.Uid != uid {
+		c.JSON(403, gin.H{"error": "forbidden"})
+		return draft, false
+	}
+	return draft, true
+}
+
+// Applies a JSON Patch (RFC 6902) or a JSON Merge Patch
+// (RFC 7396) to the course data of the draft, depending
+// on the Content-Type. The If-Match header must have the
+// revision the patch was made against, * isn't allowed.
+func PatchDraft(c *gin.Context) {
+	claims := getClaims(c)
+	uid, _ := strconv.Atoi(claims.Subject)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	var patch func(interface{}) (interface{}, error)
+	switch c.ContentType() {
+	case "application/json-patch+json":
+		ops := []models.PatchOp{}
+		if err := c.ShouldBindBodyWith(&ops, binding.JSON); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		patch = func(doc interface{}) (interface{}, error) {
+			return models.ApplyJSONPatch(doc, ops)
+		}
+	case "application/merge-patch+json":
+		var merge interface{}
+		if err := c.ShouldBindBodyWith(&merge, binding.JSON); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		patch = func(doc interface{}) (interface{}, error) {
+			return models.MergePatch(doc, merge), nil
+		}
+	default:
+		c.JSON(415, gin.H{"error": "patches must be application/json-patch+json or application/merge-patch+json"})
+		return
+	}
+	revision, ok := getRevision(c, 0)
+	if !ok {
+		return
+	}
+	if revision == 0 {
+		c.JSON(428, gin.H{"error": "the revision being patched is required in the If-Match header"})
+		return
+	}
+	draft, err := models.PatchDraft(id, uid, revision, patch)
+	if err == models.ErrRevisionConflict {
+		c.Header("ETag", revisionETag(draft.Revision))
+		c.JSON(409, gin.H{"error": err.Error(), "draft": draft})
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "draft not found"})
+		return
+	}
+	if err == models.ErrNotDraftOwner {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	var patchErr *models.PatchError
+	if errors.As(err, &patchErr) {
+		c.JSON(422, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("ETag", revisionETag(draft.Revision))
+	c.JSON(200, gin.H{"status": "ok", "revision": draft.Revision})
+	event := tracking.Event{
+		EventType: "draft_update",
+		Uid:       uid,
+		DraftId:   id,
+	}
+	event.Send()
+}
@@ -1,7 +1,9 @@
 package routes
 
 import (
+	"database/sql"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sofferjacob/maker_api/models"
@@ -17,7 +19,13 @@ func CreateLevel(c *gin.Context) {
 		return
 	}
 	level.Uid = uid
+	// Only forks published from a draft are remixes
+	level.SourceLevelId, level.SourceVersion = nil, nil
 	id, err := level.Create()
+	if err == models.ErrInvalidTag || err == models.ErrTooManyTags {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -32,11 +40,14 @@ func CreateLevel(c *gin.Context) {
 }
 
 type CreateFromDraftParams struct {
-	DraftId     int    `json:"draftId" binding:"required"`
-	Name        string `json:"name"`
-	Difficulty  int    `json:"difficulty" binding:"required"`
-	Description string `json:"description" binding:"required"`
-	Theme       int    `json:"theme" binding:"required"`
+	DraftId     int      `json:"draftId" binding:"required"`
+	Name        string   `json:"name"`
+	Difficulty  int      `json:"difficulty" binding:"required"`
+	Description string   `json:"description" binding:"required"`
+	Theme       int      `json:"theme" binding:"required"`
+	Tags        []string `json:"tags"`
+	Language    string   `json:"language" binding:"omitempty,oneof=es en pt"`
+	Visibility  string   `json:"visibility" binding:"omitempty,oneof=public unlisted private"`
 }
 
 func CreateLevelFromDraft(c *gin.Context) {
@@ -67,8 +78,15 @@ func CreateLevelFromDraft(c *gin.Context) {
 		Difficulty:  params.Difficulty,
 		Theme:       params.Theme,
 		Uid:         uid,
+		Tags:        params.Tags,
+		Language:    params.Language,
+		Visibility:  params.Visibility,
 	}
 	id, err := level.CreateFromDraft(draft)
+	if err == models.ErrInvalidTag || err == models.ErrTooManyTags {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -91,11 +109,16 @@ func GetLevel(c *gin.Context) {
 		return
 	}
 	level := models.Level{Id: id}
-	err = level.Get()
+	err = level.Get(getClaims(c).Viewer())
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
+	c.Header("ETag", revisionETag(level.Revision))
 	c.JSON(200, gin.H{"status": "ok", "level": level})
 }
 
@@ -107,7 +130,25 @@ func GetLevelInfo(c *gin.Context) {
 		return
 	}
 	level := models.Level{Id: id}
-	err = level.GetInfo()
+	err = level.GetInfo(getClaims(c).Viewer())
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("ETag", revisionETag(level.Revision))
+	c.JSON(200, gin.H{"status": "ok", "level": level})
+}
+
+func GetSharedLevel(c *gin.Context) {
+	level, err := models.GetSharedLevel(c.Param("token"))
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": models.ErrLevelNotFound.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -115,6 +156,36 @@ func GetLevelInfo(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "ok", "level": level})
 }
 
+// Makes a new share link for an unlisted level,
+// the previous one stops working
+func ResetShareLink(c *gin.Context) {
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	owner, err := models.GetLevelOwner(id)
+	if err != nil {
+		ownerError(c, err)
+		return
+	}
+	if !canManage(getClaims(c), owner) {
+		c.JSON(403, gin.H{"error": "forbidden"})
+		return
+	}
+	token, err := models.ResetShareToken(id)
+	if err == models.ErrNotUnlisted {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "shareToken": token, "shareLink": models.ShareLink(token)})
+}
+
 type UpdateLevelParams struct {
 	Id          int                    `json:"id" binding:"required"`
 	Name        string                 `json:"name"`
@@ -122,6 +193,23 @@ type UpdateLevelParams struct {
 	Description string                 `json:"description"`
 	Theme       int                    `json:"theme"`
 	CourseData  map[string]interface{} `json:"courseData"`
+	Language    string                 `json:"language" binding:"omitempty,oneof=es en pt"`
+	Visibility  string                 `json:"visibility" binding:"omitempty,oneof=public unlisted private"`
+	// The revision being updated, if there
+	// is no If-Match header
+	Revision int `json:"revision" binding:"omitempty,min=1"`
+}
+
+// Responds to a revision conflict with the current
+// level and its revision
+func levelConflict(c *gin.Context, id int, err error) {
+	current := models.Level{Id: id}
+	if err := current.Get(getClaims(c).Viewer()); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("ETag", revisionETag(current.Revision))
+	c.JSON(409, gin.H{"error": err.Error(), "level": current})
 }
 
 func UpdateLevel(c *gin.Context) {
@@ -132,21 +220,42 @@ func UpdateLevel(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	owner, err := models.GetLevelOwner(params.Id)
+	if err != nil {
+		ownerError(c, err)
+		return
+	}
+	if !canManage(claims, owner) {
+		c.JSON(403, gin.H{"error": "forbidden"})
+		return
+	}
+	revision, ok := getRevision(c, params.Revision)
+	if !ok {
+		return
+	}
 	level := models.Level{
 		Id:          params.Id,
-		Uid:         uid,
+		Uid:         owner,
 		Name:        params.Name,
 		Difficulty:  params.Difficulty,
 		Description: params.Description,
 		Theme:       params.Theme,
 		CourseData:  params.CourseData,
+		Language:    params.Language,
+		Visibility:  params.Visibility,
+		Revision:    revision,
+	}
+	err = level.Update()
+	if err == models.ErrRevisionConflict {
+		levelConflict(c, params.Id, err)
+		return
 	}
-	err := level.Update()
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "ok"})
+	c.Header("ETag", revisionETag(level.Revision))
+	c.JSON(200, gin.H{"status": "ok", "revision": level.Revision})
 	event := tracking.Event{
 		EventType: "level_update",
 		Uid:       uid,
@@ -158,6 +267,9 @@ func UpdateLevel(c *gin.Context) {
 type UpdateFromDraftParams struct {
 	LevelId int `json:"levelId" binding:"required"`
 	DraftId int `json:"draftId" binding:"required"`
+	// The revision of the level being updated,
+	// if there is no If-Match header
+	Revision int `json:"revision" binding:"omitempty,min=1"`
 }
 
 func UpdateLevelFromDraft(c *gin.Context) {
@@ -170,6 +282,10 @@ func UpdateLevelFromDraft(c *gin.Context) {
 	}
 	draft := models.Draft{Id: params.DraftId}
 	err := draft.Get()
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "draft not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -178,12 +294,25 @@ func UpdateLevelFromDraft(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "invalid draft"})
 		return
 	}
-	err = models.UpdateLevelFomDraft(params.LevelId, draft)
+	revision, ok := getRevision(c, params.Revision)
+	if !ok {
+		return
+	}
+	revision, err = models.UpdateLevelFomDraft(params.LevelId, draft, revision)
+	if err == models.ErrRevisionConflict {
+		levelConflict(c, params.LevelId, err)
+		return
+	}
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "ok"})
+	c.Header("ETag", revisionETag(revision))
+	c.JSON(200, gin.H{"status": "ok", "revision": revision})
 	event := tracking.Event{
 		EventType: "level_update",
 		Uid:       uid,
@@ -193,29 +322,165 @@ func UpdateLevelFromDraft(c *gin.Context) {
 }
 
 func DeleteLevel(c *gin.Context) {
-	c.JSON(500, gin.H{"error": "not implemented", "message": "coming soon"})
+	claims := getClaims(c)
+	uid, _ := strconv.Atoi(claims.Subject)
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	owner, err := models.GetLevelOwner(id)
+	if err != nil {
+		ownerError(c, err)
+		return
+	}
+	if !canManage(claims, owner) {
+		c.JSON(403, gin.H{"error": "forbidden"})
+		return
+	}
+	err = models.DeleteLevel(id, owner)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+	// The level's events are deleted with it,
+	// so the id goes in the body
+	event := tracking.Event{
+		EventType: "level_delete",
+		Uid:       uid,
+		Body:      map[string]interface{}{"levelId": id},
+	}
+	event.Send()
 }
 
 type QueryFTSParams struct {
-	Query string `json:"query" binding:"required"`
+	Query  string `json:"query" binding:"required"`
+	Limit  int    `json:"limit"`
+	Cursor string `json:"cursor"`
+	// The language the query is written in,
+	// models.DefaultLanguage if empty
+	Language string `json:"language" binding:"omitempty,oneof=es en pt"`
 }
 
-func QueryLevels(c *gin.Context) {
+func (p QueryFTSParams) page() (models.Page, error) {
+	return models.NewPage(p.Limit, p.Cursor)
+}
+
+// Params of a search, like QueryFTSParams or
+// QueryLevelsParams, with the page to return
+// from their limit and cursor
+type searchParams interface {
+	page() (models.Page, error)
+}
+
+// Binds the params of a search into params,
+// aborting the request if they are invalid
+func bindSearch(c *gin.Context, params searchParams) (models.Page, bool) {
+	if err := c.ShouldBindJSON(params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return models.Page{}, false
+	}
+	p, err := params.page()
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return models.Page{}, false
+	}
+	return p, true
+}
+
+// Binds the params of a search, aborting
+// the request if they are invalid
+func bindQuery(c *gin.Context) (QueryFTSParams, models.Page, bool) {
 	params := QueryFTSParams{}
-	if err := c.ShouldBindJSON(&params); err != nil {
+	p, ok := bindSearch(c, &params)
+	return params, p, ok
+}
+
+type QueryLevelsParams struct {
+	// Every level matches an empty query
+	Query    string `json:"query"`
+	Limit    int    `json:"limit"`
+	Cursor   string `json:"cursor"`
+	Language string `json:"language" binding:"omitempty,oneof=es en pt"`
+	// Range of the community difficulty
+	MinDifficulty float64 `json:"minDifficulty" binding:"omitempty,min=1,max=5"`
+	MaxDifficulty float64 `json:"maxDifficulty" binding:"omitempty,min=1,max=5"`
+	// Levels must have every tag
+	Tags []string `json:"tags"`
+	// Levels must have one of the values
+	Themes      []int     `json:"themes"`
+	Cars        []int     `json:"cars"`
+	Soundtracks []int     `json:"soundtracks"`
+	Creator     int       `json:"creator"`
+	CreatedFrom time.Time `json:"createdFrom"`
+	CreatedTo   time.Time `json:"createdTo"`
+	UpdatedFrom time.Time `json:"updatedFrom"`
+	UpdatedTo   time.Time `json:"updatedTo"`
+	MinPlays    int       `json:"minPlays" binding:"omitempty,min=0"`
+	MinRating   float64   `json:"minRating" binding:"omitempty,min=1,max=5"`
+	Sort        string    `json:"sort" binding:"omitempty,oneof=relevance newest plays rating"`
+	// Adds the facet counts to the response
+	Facets bool `json:"facets"`
+}
+
+func (p QueryLevelsParams) page() (models.Page, error) {
+	return models.NewPage(p.Limit, p.Cursor)
+}
+
+func QueryLevels(c *gin.Context) {
+	params := QueryLevelsParams{}
+	p, ok := bindSearch(c, &params)
+	if !ok {
+		return
+	}
+	f := models.LevelFilter{
+		MinDifficulty: params.MinDifficulty,
+		MaxDifficulty: params.MaxDifficulty,
+		Tags:          params.Tags,
+		Themes:        params.Themes,
+		Cars:          params.Cars,
+		Soundtracks:   params.Soundtracks,
+		Creator:       params.Creator,
+		CreatedFrom:   params.CreatedFrom,
+		CreatedTo:     params.CreatedTo,
+		UpdatedFrom:   params.UpdatedFrom,
+		UpdatedTo:     params.UpdatedTo,
+		MinPlays:      params.MinPlays,
+		MinRating:     params.MinRating,
+	}
+	res, next, err := models.QueryLevelFTS(params.Query, params.Language, f, params.Sort, p)
+	if err == models.ErrInvalidTag || err == models.ErrInvalidSort || err == models.ErrInvalidLanguage {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-	res, err := models.QueryLevelFTS(params.Query)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "ok", "results": res})
+	body := gin.H{"status": "ok", "results": res, "nextCursor": next}
+	if params.Facets {
+		facets, err := models.GetLevelFacets(params.Query, params.Language, f)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		body["facets"] = facets
+	}
+	c.JSON(200, body)
+}
+
+func AutocompleteLevels(c *gin.Context) {
+	autocomplete(c, models.AutocompleteLevels)
 }
 
 func TrendingLevels(c *gin.Context) {
-	levels, err := models.TrendingLevels()
+	levels, err := models.TrendingLevels(c.DefaultQuery("sort", models.TrendingByPlays))
+	if err == models.ErrInvalidTrendingSort {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -230,12 +495,20 @@ func Leaderboard(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "invalid id"})
 		return
 	}
-	res, err := models.GetLeaderboard(id)
+	page, ok := getPage(c)
+	if !ok {
+		return
+	}
+	res, next, err := models.GetLeaderboard(id, getClaims(c).Viewer(), page)
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "ok", "result": res})
+	c.JSON(200, gin.H{"status": "ok", "result": res, "nextCursor": next})
 }
 
 func GetUserLevels(c *gin.Context) {
@@ -245,10 +518,14 @@ func GetUserLevels(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "invalid uid"})
 		return
 	}
-	levels, err := models.GetUserLevels(uid)
+	page, ok := getPage(c)
+	if !ok {
+		return
+	}
+	levels, next, err := models.GetUserLevels(uid, getClaims(c).Viewer(), page)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "ok", "levels": levels})
+	c.JSON(200, gin.H{"status": "ok", "levels": levels, "nextCursor": next})
 }
@@ -1,20 +1,127 @@
 package routes
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
+	"github.com/sofferjacob/maker_api/models"
 )
 
-func getClaims(c *gin.Context) *jwt.StandardClaims {
+func getClaims(c *gin.Context) *models.Claims {
 	claimsObj, ok := c.Get("user-claims")
 	if !ok {
 		c.AbortWithStatusJSON(403, gin.H{"error": "forbidden"})
-		return &jwt.StandardClaims{}
+		return &models.Claims{}
 	}
-	claims, ok := claimsObj.(*jwt.StandardClaims)
+	claims, ok := claimsObj.(*models.Claims)
 	if !ok {
 		c.AbortWithStatusJSON(500, gin.H{"error": "invalid claims"})
-		return &jwt.StandardClaims{}
+		return &models.Claims{}
 	}
 	return claims
 }
+
+// Whether the user can edit or delete a level or
+// collection owned by ownerUid. Moderators and
+// admins can manage content of any user.
+func canManage(claims *models.Claims, ownerUid int) bool {
+	uid, _ := strconv.Atoi(claims.Subject)
+	return uid == ownerUid || claims.CanModerate()
+}
+
+// Reads the page requested with the limit and cursor
+// query parameters, aborting the request if they
+// are invalid
+func getPage(c *gin.Context) (models.Page, bool) {
+	limit := 0
+	if l := c.Query("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid limit"})
+			return models.Page{}, false
+		}
+	}
+	p, err := models.NewPage(limit, c.Query("cursor"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return models.Page{}, false
+	}
+	return p, true
+}
+
+const (
+	defaultListLimit = 10
+	maxListLimit     = 50
+)
+
+// Reads the limit query parameter of the short lists
+// (tags and autocomplete suggestions), aborting the
+// request if it is invalid
+func getLimit(c *gin.Context) (int, bool) {
+	l := c.Query("limit")
+	if l == "" {
+		return defaultListLimit, true
+	}
+	limit, err := strconv.Atoi(l)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		c.JSON(400, gin.H{"error": "invalid limit"})
+		return 0, false
+	}
+	return limit, true
+}
+
+// Completes the q query parameter with
+// the names fn returns
+func autocomplete(c *gin.Context, fn func(prefix string, limit int) ([]models.Suggestion, error)) {
+	limit, ok := getLimit(c)
+	if !ok {
+		return
+	}
+	res, err := fn(c.Query("q"), limit)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "suggestions": res})
+}
+
+// Responds with the errors of GetLevelOwner
+// and GetCollectionOwner
+func ownerError(c *gin.Context, err error) {
+	if err == models.ErrLevelNotFound || err == models.ErrCollectionNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(500, gin.H{"error": err.Error()})
+}
+
+// The ETag of a draft or level revision
+func revisionETag(revision int) string {
+	return `"` + strconv.Itoa(revision) + `"`
+}
+
+// Reads the revision an update is based on from the
+// If-Match header, or the revision of the body if there
+// is no header. Returns 0 for If-Match: *, which matches
+// any revision. Aborts the request if neither is set.
+func getRevision(c *gin.Context, body int) (int, bool) {
+	match := strings.TrimPrefix(c.GetHeader("If-Match"), "W/")
+	if match == "*" {
+		return 0, true
+	}
+	if match != "" {
+		revision, err := strconv.Atoi(strings.Trim(match, `"`))
+		if err != nil || revision < 1 {
+			c.JSON(400, gin.H{"error": "invalid If-Match header"})
+			return 0, false
+		}
+		return revision, true
+	}
+	if body < 1 {
+		c.JSON(428, gin.H{"error": "the revision being updated is required, in the If-Match header or the revision field"})
+		return 0, false
+	}
+	return body, true
+}
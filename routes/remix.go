@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+func GetLevelRemixes(c *gin.Context) {
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	page, ok := getPage(c)
+	if !ok {
+		return
+	}
+	levels, next, err := models.GetLevelRemixes(id, getClaims(c).Viewer(), page)
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "levels": levels, "nextCursor": next})
+}
+
+func GetLevelLineage(c *gin.Context) {
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	lineage, err := models.GetLevelLineage(id, getClaims(c).Viewer())
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "lineage": lineage})
+}
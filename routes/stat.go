@@ -5,14 +5,10 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sofferjacob/maker_api/db"
+	"github.com/sofferjacob/maker_api/models"
+	"github.com/sofferjacob/maker_api/tracking"
 )
 
-type LevelStartsResult struct {
-	GameStarts int       `db:"game_starts" json:"gameStarts"`
-	Date       time.Time `db:"date" json:"date"`
-}
-
 type GetLevelStartsParams struct {
 	From time.Time `json:"from"`
 	To   time.Time `json:"to"`
@@ -27,6 +23,21 @@ type AvgTimeParams struct {
 	Lt   float64   `json:"lt"`
 }
 
+// Aborts the request with a 404 unless the
+// user can read the level
+func levelReadable(c *gin.Context, id int) bool {
+	err := models.CheckLevelReadable(id, getClaims(c).Viewer())
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
 func GetLevelStarts(c *gin.Context) {
 	param := c.Param("id")
 	id, err := strconv.Atoi(param)
@@ -39,19 +50,10 @@ func GetLevelStarts(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "invalid id"})
 		return
 	}
-	qb := db.SelectFrom("events").Select("COUNT(*) game_starts").Select("date(timestamp)").Where("event_type", "=", "game_start").
-		And("level_id", "=", id)
-	base := time.Time{}
-	if params.From != base {
-		qb = qb.And("timestamp", ">=", params.From)
-	}
-	if params.To != base {
-		qb = qb.And("timestamp", "<=", params.To)
+	if !levelReadable(c, id) {
+		return
 	}
-	query, args := qb.GroupBy("date(timestamp)").Query()
-	//query, args := "SELECT COUNT(*) game_starts, date(timestamp) FROM events WHERE event_type = 'game_start' AND level_id = $1 GROUP BY date(timestamp);"
-	res := []LevelStartsResult{}
-	err = db.Client.Client.Select(&res, query, args...)
+	res, err := tracking.LevelStarts(tracking.StatsFilter{LevelId: id, From: params.From, To: params.To})
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -67,11 +69,6 @@ func GetLevelStarts(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "ok", "result": res})
 }
 
-type LevelCompleteResult struct {
-	GameComplete int       `db:"game_complete" json:"gameCompletes"`
-	Date         time.Time `db:"date" json:"date"`
-}
-
 func GetLevelCompletes(c *gin.Context) {
 	param := c.Param("id")
 	id, err := strconv.Atoi(param)
@@ -84,19 +81,10 @@ func GetLevelCompletes(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "invalid id"})
 		return
 	}
-	qb := db.SelectFrom("events").Select("COUNT(*) game_complete").Select("date(timestamp)").Where("event_type", "=", "game_finish").
-		And("level_id", "=", id)
-	base := time.Time{}
-	if params.From != base {
-		qb = qb.And("timestamp", ">=", params.From)
-	}
-	if params.To != base {
-		qb = qb.And("timestamp", "<=", params.To)
+	if !levelReadable(c, id) {
+		return
 	}
-	query, args := qb.GroupBy("date(timestamp)").Query()
-	//query, args := "SELECT COUNT(*) game_starts, date(timestamp) FROM events WHERE event_type = 'game_start' AND level_id = $1 GROUP BY date(timestamp);"
-	res := []LevelCompleteResult{}
-	err = db.Client.Client.Select(&res, query, args...)
+	res, err := tracking.LevelCompletes(tracking.StatsFilter{LevelId: id, From: params.From, To: params.To})
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -112,11 +100,6 @@ func GetLevelCompletes(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "ok", "result": res})
 }
 
-type AvgTimeResult struct {
-	AvgTime float64   `db:"avg_time" json:"avgTime"`
-	Date    time.Time `db:"date" json:"date"`
-}
-
 func GetAvgTime(c *gin.Context) {
 	param := c.Param("id")
 	id, err := strconv.Atoi(param)
@@ -129,19 +112,10 @@ func GetAvgTime(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "invalid id"})
 		return
 	}
-	qb := db.SelectFrom("events").Select("AVG(time) avg_time").Select("date(timestamp)").Where("event_type", "=", "game_finish").
-		And("level_id", "=", id)
-	base := time.Time{}
-	if params.From != base {
-		qb = qb.And("timestamp", ">=", params.From)
-	}
-	if params.To != base {
-		qb = qb.And("timestamp", "<=", params.To)
+	if !levelReadable(c, id) {
+		return
 	}
-	query, args := qb.GroupBy("date(timestamp)").Query()
-	//query, args := "SELECT COUNT(*) game_starts, date(timestamp) FROM events WHERE event_type = 'game_start' AND level_id = $1 GROUP BY date(timestamp);"
-	res := []AvgTimeResult{}
-	err = db.Client.Client.Select(&res, query, args...)
+	res, err := tracking.AvgTime(tracking.StatsFilter{LevelId: id, From: params.From, To: params.To})
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
@@ -157,11 +131,6 @@ func GetAvgTime(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "ok", "result": res})
 }
 
-type UniqueUsersResult struct {
-	UniqueUsers int `db:"unique_users" json:"uniqueUsers"`
-	Month       int `db:"month" json:"month"`
-}
-
 func GetUniqueUsers(c *gin.Context) {
 	param := c.Param("id")
 	id, err := strconv.Atoi(param)
@@ -174,19 +143,10 @@ func GetUniqueUsers(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "invalid id"})
 		return
 	}
-	qb := db.SelectFrom("events").Select("COUNT(DISTINCT uid) unique_users").Select("EXTRACT(MONTH FROM timestamp) AS month").Where("event_type", "=", "game_finish").
-		And("level_id", "=", id)
-	base := time.Time{}
-	if params.From != base {
-		qb = qb.And("timestamp", ">=", params.From)
-	}
-	if params.To != base {
-		qb = qb.And("timestamp", "<=", params.To)
+	if !levelReadable(c, id) {
+		return
 	}
-	query, args := qb.GroupBy("EXTRACT(MONTH FROM timestamp)").Query()
-	//query, args := "SELECT COUNT(*) game_starts, date(timestamp) FROM events WHERE event_type = 'game_start' AND level_id = $1 GROUP BY date(timestamp);"
-	res := []UniqueUsersResult{}
-	err = db.Client.Client.Select(&res, query, args...)
+	res, err := tracking.UniqueUsers(tracking.StatsFilter{LevelId: id, From: params.From, To: params.To})
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
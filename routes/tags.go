@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+func AutocompleteTags(c *gin.Context) {
+	limit, ok := getLimit(c)
+	if !ok {
+		return
+	}
+	tags, err := models.AutocompleteTags(c.Query("q"), limit)
+	if err == models.ErrInvalidTag {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "tags": tags})
+}
+
+func PopularTags(c *gin.Context) {
+	limit, ok := getLimit(c)
+	if !ok {
+		return
+	}
+	tags, err := models.PopularTags(c.Query("curated") == "true", limit)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "tags": tags})
+}
+
+type SetCuratedParams struct {
+	Curated *bool `json:"curated" binding:"required"`
+}
+
+func SetTagCurated(c *gin.Context) {
+	params := SetCuratedParams{}
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	err := models.SetTagCurated(c.Param("tag"), *params.Curated)
+	if err == models.ErrInvalidTag {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+func GetTagLevels(c *gin.Context) {
+	page, ok := getPage(c)
+	if !ok {
+		return
+	}
+	levels, next, err := models.GetTagLevels(c.Param("tag"), page)
+	if err == models.ErrInvalidTag {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "levels": levels, "nextCursor": next})
+}
+
+type SetLevelTagsParams struct {
+	Tags []string `json:"tags" binding:"required"`
+}
+
+// Replaces the tags of the level
+func SetLevelTags(c *gin.Context) {
+	claims := getClaims(c)
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	params := SetLevelTagsParams{}
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	owner, err := models.GetLevelOwner(id)
+	if err != nil {
+		ownerError(c, err)
+		return
+	}
+	if !canManage(claims, owner) {
+		c.JSON(403, gin.H{"error": "forbidden"})
+		return
+	}
+	err = models.SetLevelTags(id, params.Tags)
+	if err == models.ErrInvalidTag || err == models.ErrTooManyTags {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
@@ -11,6 +11,7 @@ func PostEvent(c *gin.Context) {
 	event := tracking.Event{}
 	if err := c.ShouldBindJSON(&event); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
+		return
 	}
 	claims := getClaims(c)
 	uid, _ := strconv.Atoi(claims.Subject)
@@ -19,16 +19,19 @@ func GetUser(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "ok", "user": u})
 }
 
+func AutocompleteUsers(c *gin.Context) {
+	autocomplete(c, models.AutocompleteUsers)
+}
+
 func QueryUsers(c *gin.Context) {
-	params := QueryFTSParams{}
-	if err := c.ShouldBindJSON(&params); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+	params, p, ok := bindQuery(c)
+	if !ok {
 		return
 	}
-	users, err := models.QueryUserFTS(params.Query)
+	users, next, err := models.QueryUserFTS(params.Query, params.Language, p)
 	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(200, gin.H{"status": "ok", "results": users})
+	c.JSON(200, gin.H{"status": "ok", "results": users, "nextCursor": next})
 }
@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+	"github.com/sofferjacob/maker_api/tracking"
+)
+
+// Reads the level id and version number of the path,
+// aborting the request if they are invalid
+func getVersionParams(c *gin.Context) (int, int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id == 0 {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return 0, 0, false
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(400, gin.H{"error": "invalid version"})
+		return 0, 0, false
+	}
+	return id, version, true
+}
+
+// Responds with the errors of the version functions
+func versionError(c *gin.Context, err error) {
+	if err == models.ErrLevelNotFound || err == models.ErrVersionNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(500, gin.H{"error": err.Error()})
+}
+
+func GetLevelVersions(c *gin.Context) {
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	page, ok := getPage(c)
+	if !ok {
+		return
+	}
+	versions, next, err := models.GetLevelVersions(id, getClaims(c).Viewer(), page)
+	if err != nil {
+		versionError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "versions": versions, "nextCursor": next})
+}
+
+func GetLevelVersion(c *gin.Context) {
+	id, version, ok := getVersionParams(c)
+	if !ok {
+		return
+	}
+	res, err := models.GetLevelVersion(id, version, getClaims(c).Viewer())
+	if err != nil {
+		versionError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "version": res})
+}
+
+type DiffVersionsParams struct {
+	From int `form:"from" binding:"required,min=1"`
+	To   int `form:"to" binding:"required,min=1"`
+}
+
+func DiffLevelVersions(c *gin.Context) {
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	params := DiffVersionsParams{}
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	changes, err := models.DiffLevelVersions(id, params.From, params.To, getClaims(c).Viewer())
+	if err != nil {
+		versionError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "changes": changes})
+}
+
+// Only the creator can roll back a level
+func RollbackLevel(c *gin.Context) {
+	claims := getClaims(c)
+	uid, _ := strconv.Atoi(claims.Subject)
+	id, version, ok := getVersionParams(c)
+	if !ok {
+		return
+	}
+	owner, err := models.GetLevelOwner(id)
+	if err != nil {
+		ownerError(c, err)
+		return
+	}
+	if owner != uid {
+		c.JSON(403, gin.H{"error": "forbidden"})
+		return
+	}
+	revision, ok := getRevision(c, 0)
+	if !ok {
+		return
+	}
+	res, revision, err := models.RollbackLevel(id, uid, version, revision)
+	if err == models.ErrRevisionConflict {
+		levelConflict(c, id, err)
+		return
+	}
+	if err != nil {
+		versionError(c, err)
+		return
+	}
+	c.Header("ETag", revisionETag(revision))
+	c.JSON(200, gin.H{"status": "ok", "version": res, "revision": revision})
+	event := tracking.Event{
+		EventType: "level_update",
+		Uid:       uid,
+		LevelId:   id,
+	}
+	event.Send()
+}
@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+func GetLevelVote(c *gin.Context) {
+	claims := getClaims(c)
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	vote, err := models.GetLevelVote(id, claims.Viewer())
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "vote not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "vote": vote})
+}
+
+// Omitted fields keep their previous value
+type VoteParams struct {
+	Liked *bool `json:"liked"`
+	Stars *int  `json:"stars" binding:"omitempty,min=1,max=5"`
+}
+
+func VoteLevel(c *gin.Context) {
+	claims := getClaims(c)
+	uid, _ := strconv.Atoi(claims.Subject)
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	params := VoteParams{}
+	if err := c.ShouldBindJSON(&params); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	owner, err := models.GetLevelOwner(id)
+	if err != nil {
+		ownerError(c, err)
+		return
+	}
+	if owner == uid {
+		c.JSON(403, gin.H{"error": "can't vote on your own level"})
+		return
+	}
+	vote := models.LevelVote{LevelId: id, Uid: uid, Liked: params.Liked, Stars: params.Stars}
+	err = vote.Save(claims.Viewer())
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err == models.ErrEmptyVote || err == models.ErrInvalidStars {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	rating, err := models.GetLevelRating(id)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok", "rating": rating})
+}
+
+func DeleteLevelVote(c *gin.Context) {
+	claims := getClaims(c)
+	param := c.Param("id")
+	id, err := strconv.Atoi(param)
+	if err != nil || id == 0 || param == "" {
+		c.JSON(400, gin.H{"error": "invalid id"})
+		return
+	}
+	err = models.DeleteLevelVote(id, claims.Viewer())
+	if err == models.ErrLevelNotFound {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "vote not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
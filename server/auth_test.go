@@ -0,0 +1,183 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/sofferjacob/maker_api/mail"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+func signedToken(t *testing.T, key string, claims models.Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims).SignedString([]byte(key))
+	if err != nil {
+		t.Fatalf("could not sign token: %v", err)
+	}
+	return token
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	u := newUser(t)
+	expect(t, 500, "POST", "/id/register", "", gin.H{"name": "Copy", "email": u.Email, "password": "other"})
+	expect(t, 400, "POST", "/id/register", "", gin.H{"email": "missing@example.com"})
+	expect(t, 500, "POST", "/id/login", "", gin.H{"email": u.Email, "password": "wrong"})
+	expect(t, 500, "POST", "/id/login", "", gin.H{"email": "nobody@example.com", "password": "wrong"})
+
+	var body struct {
+		User models.UserData `json:"user"`
+	}
+	expect(t, 200, "GET", "/id/profile", u.Token, nil).decode(t, &body)
+	if body.User.Id != u.Id || body.User.Email != u.Email || body.User.Role != models.RoleUser {
+		t.Fatalf("unexpected profile %+v", body.User)
+	}
+
+	expect(t, 200, "PUT", "/id/profile", u.Token, gin.H{"name": "Renamed"})
+	expect(t, 200, "GET", "/id/profile", u.Token, nil).decode(t, &body)
+	if body.User.Name != "Renamed" {
+		t.Fatalf("expected name to be updated, got %v", body.User.Name)
+	}
+}
+
+func TestAuthFailures(t *testing.T) {
+	u := newUser(t)
+	sub := strconv.Itoa(u.Id)
+	expired := signedToken(t, "test-auth-key", models.Claims{Role: models.RoleUser, StandardClaims: jwt.StandardClaims{
+		Subject:   sub,
+		Id:        "1",
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	}})
+	wrongKey := signedToken(t, "another-key", models.Claims{Role: models.RoleUser, StandardClaims: jwt.StandardClaims{
+		Subject:   sub,
+		Id:        "1",
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	}})
+	noSession := signedToken(t, "test-auth-key", models.Claims{Role: models.RoleUser, StandardClaims: jwt.StandardClaims{
+		Subject:   sub,
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	}})
+	tokens := map[string]string{
+		"missing":    "",
+		"malformed":  "not-a-real-token",
+		"expired":    expired,
+		"wrong key":  wrongKey,
+		"no session": noSession,
+	}
+	endpoints := []struct{ method, path string }{
+		{"GET", "/id/profile"},
+		{"POST", "/id/logout"},
+		{"POST", "/collections/"},
+		{"GET", "/drafts/u"},
+		{"GET", "/levels/trending"},
+		{"GET", "/u/" + sub},
+		{"POST", "/t/"},
+		{"POST", "/stats/1/gameStarts"},
+		{"PUT", "/admin/users/" + sub + "/role"},
+	}
+	for name, token := range tokens {
+		for _, e := range endpoints {
+			if res := call(t, e.method, e.path, token, gin.H{}); res.Code != 403 {
+				t.Errorf("%v token: %v %v: expected 403, got %d: %s", name, e.method, e.path, res.Code, res.Body)
+			}
+		}
+	}
+}
+
+func TestRefreshAndLogout(t *testing.T) {
+	u := newUser(t)
+	var pair struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	expect(t, 200, "POST", "/id/refresh", "", gin.H{"refreshToken": u.RefreshToken}).decode(t, &pair)
+	expect(t, 200, "GET", "/id/profile", pair.Token, nil)
+
+	// Reusing a rotated refresh token revokes the session
+	expect(t, 403, "POST", "/id/refresh", "", gin.H{"refreshToken": u.RefreshToken})
+	expect(t, 403, "POST", "/id/refresh", "", gin.H{"refreshToken": pair.RefreshToken})
+	expect(t, 403, "GET", "/id/profile", pair.Token, nil)
+	expect(t, 400, "POST", "/id/refresh", "", gin.H{})
+
+	u.login(t)
+	expect(t, 200, "POST", "/id/logout", u.Token, nil)
+	expect(t, 403, "GET", "/id/profile", u.Token, nil)
+	expect(t, 403, "POST", "/id/refresh", "", gin.H{"refreshToken": u.RefreshToken})
+
+	first := u
+	u.login(t)
+	expect(t, 200, "POST", "/id/logout/all", u.Token, nil)
+	expect(t, 403, "GET", "/id/profile", u.Token, nil)
+	expect(t, 403, "GET", "/id/profile", first.Token, nil)
+}
+
+func TestPasswordReset(t *testing.T) {
+	u := newUser(t)
+	expect(t, 200, "POST", "/id/password/forgot", "", gin.H{"email": u.Email})
+	// Unknown emails look the same to the caller
+	expect(t, 200, "POST", "/id/password/forgot", "", gin.H{"email": "nobody@example.com"})
+	token := mailedToken(t, u.Email)
+
+	expect(t, 400, "POST", "/id/password/reset", "", gin.H{"token": "bogus", "password": "new-password"})
+	expect(t, 200, "POST", "/id/password/reset", "", gin.H{"token": token, "password": "new-password"})
+	expect(t, 400, "POST", "/id/password/reset", "", gin.H{"token": token, "password": "again"})
+
+	expect(t, 403, "GET", "/id/profile", u.Token, nil)
+	expect(t, 500, "POST", "/id/login", "", gin.H{"email": u.Email, "password": u.Password})
+	u.Password = "new-password"
+	u.login(t)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	u := newUser(t)
+	expect(t, 400, "GET", "/id/verify", "", nil)
+	expect(t, 400, "GET", "/id/verify?token=bogus", "", nil)
+
+	t.Setenv("REQUIRE_VERIFIED_EMAIL", "true")
+	expect(t, 403, "POST", "/levels/", u.Token, levelBody("Unverified"))
+
+	expect(t, 200, "POST", "/id/verify/resend", u.Token, nil)
+	expect(t, 200, "GET", "/id/verify?token="+mailedToken(t, u.Email), "", nil)
+	var body struct {
+		User models.UserData `json:"user"`
+	}
+	expect(t, 200, "GET", "/id/profile", u.Token, nil).decode(t, &body)
+	if !body.User.Verified {
+		t.Fatal("expected user to be verified")
+	}
+	newLevel(t, u, "Verified")
+}
+
+func TestAdminRoles(t *testing.T) {
+	admin := newUser(t)
+	u := newUser(t)
+	path := "/admin/users/" + strconv.Itoa(u.Id) + "/role"
+	expect(t, 403, "PUT", path, u.Token, gin.H{"role": models.RoleModerator})
+
+	admin.setRole(t, models.RoleAdmin)
+	expect(t, 400, "PUT", path, admin.Token, gin.H{"role": "overlord"})
+	expect(t, 404, "PUT", "/admin/users/999999/role", admin.Token, gin.H{"role": models.RoleModerator})
+	expect(t, 200, "PUT", path, admin.Token, gin.H{"role": models.RoleModerator})
+
+	// Changing the role revokes the user's sessions
+	expect(t, 403, "GET", "/id/profile", u.Token, nil)
+}
+
+type failingMailer struct{}
+
+func (failingMailer) Send(m mail.Message) error {
+	return errors.New("mail server down")
+}
+
+func TestRegisterMailFailure(t *testing.T) {
+	sender := mail.Sender
+	mail.Sender = failingMailer{}
+	defer func() { mail.Sender = sender }()
+	email := fmt.Sprintf("mailless%d@example.com", time.Now().UnixNano())
+	expect(t, 200, "POST", "/id/register", "", gin.H{"name": "Mailless", "email": email, "password": "secret"})
+	expect(t, 200, "POST", "/id/login", "", gin.H{"email": email, "password": "secret"})
+}
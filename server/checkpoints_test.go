@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+func TestDraftCheckpoints(t *testing.T) {
+	u := newUser(t)
+	other := newUser(t)
+	var created struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/drafts/", u.Token, gin.H{"name": "History", "car": 1, "soundtrack": 1, "courseData": gin.H{"laps": 1}}).decode(t, &created)
+	path := fmt.Sprintf("/drafts/%d/checkpoints", created.Id)
+	laps := func() float64 {
+		var res struct {
+			Draft models.Draft `json:"draft"`
+		}
+		expect(t, 200, "GET", fmt.Sprintf("/drafts/%d", created.Id), u.Token, nil).decode(t, &res)
+		return res.Draft.CourseData["laps"].(float64)
+	}
+	list := func() []models.Checkpoint {
+		var res struct {
+			Checkpoints []models.Checkpoint `json:"checkpoints"`
+		}
+		expect(t, 200, "GET", path, u.Token, nil).decode(t, &res)
+		return res.Checkpoints
+	}
+
+	// Saves in a row only keep the course from before them
+	expect(t, 200, "PUT", "/drafts/", u.Token, gin.H{"id": created.Id, "courseData": gin.H{"laps": 2}, "revision": 1})
+	expect(t, 200, "PUT", "/drafts/", u.Token, gin.H{"id": created.Id, "courseData": gin.H{"laps": 3}, "revision": 2})
+	expect(t, 200, "PUT", "/drafts/", u.Token, gin.H{"id": created.Id, "name": "Renamed", "revision": 3})
+	checkpoints := list()
+	if len(checkpoints) != 1 || checkpoints[0].Revision != 1 || checkpoints[0].CourseData != nil {
+		t.Fatalf("expected a checkpoint of revision 1 without its course, got %+v", checkpoints)
+	}
+	first := checkpoints[0].Id
+
+	expect(t, 400, "POST", path, u.Token, gin.H{})
+	var named struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", path, u.Token, gin.H{"name": "Three laps"}).decode(t, &named)
+	var body struct {
+		Checkpoint models.Checkpoint `json:"checkpoint"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("%v/%d", path, named.Id), u.Token, nil).decode(t, &body)
+	if body.Checkpoint.Name != "Three laps" || body.Checkpoint.Revision != 4 || body.Checkpoint.CourseData["laps"] != 3.0 {
+		t.Fatalf("unexpected named checkpoint: %+v", body.Checkpoint)
+	}
+
+	// The named checkpoint already has the course
+	expect(t, 200, "PUT", "/drafts/", u.Token, gin.H{"id": created.Id, "courseData": gin.H{"laps": 4}, "revision": 4})
+	if len(list()) != 2 {
+		t.Fatalf("expected no new checkpoint, got %+v", list())
+	}
+
+	// Restoring records the course it replaces, so it can be undone
+	res := expect(t, 200, "POST", fmt.Sprintf("%v/%d/restore", path, first), u.Token, nil)
+	if res.Header.Get("ETag") != `"6"` || laps() != 1 {
+		t.Fatalf("expected the first course at revision 6, got %v %s", res.Header.Get("ETag"), res.Body)
+	}
+	checkpoints = list()
+	if len(checkpoints) != 3 || checkpoints[1].Id != named.Id {
+		t.Fatalf("expected the restore to be recorded, got %+v", checkpoints)
+	}
+	expect(t, 200, "POST", fmt.Sprintf("%v/%d/restore", path, checkpoints[0].Id), u.Token, nil)
+	if laps() != 4 {
+		t.Fatalf("expected the restore to be undone, got %v laps", laps())
+	}
+
+	expect(t, 403, "GET", path, other.Token, nil)
+	expect(t, 403, "POST", fmt.Sprintf("%v/%d/restore", path, first), other.Token, nil)
+	expect(t, 404, "GET", "/drafts/999999/checkpoints", u.Token, nil)
+	expect(t, 400, "GET", path+"/abc", u.Token, nil)
+	expect(t, 404, "GET", fmt.Sprintf("%v/%d", path, 999999), u.Token, nil)
+	var otherDraft struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/drafts/", u.Token, gin.H{"name": "Other", "car": 1, "soundtrack": 1}).decode(t, &otherDraft)
+	expect(t, 404, "GET", fmt.Sprintf("/drafts/%d/checkpoints/%d", otherDraft.Id, first), u.Token, nil)
+
+	expect(t, 200, "DELETE", fmt.Sprintf("%v/%d", path, named.Id), u.Token, nil)
+	expect(t, 404, "DELETE", fmt.Sprintf("%v/%d", path, named.Id), u.Token, nil)
+	for i := 0; i < 20; i++ {
+		expect(t, 200, "POST", path, u.Token, gin.H{"name": fmt.Sprintf("Checkpoint %d", i)})
+	}
+	expect(t, 400, "POST", path, u.Token, gin.H{"name": "One too many"})
+
+	// Checkpoints are deleted with their draft
+	expect(t, 200, "DELETE", fmt.Sprintf("/drafts/%d", created.Id), u.Token, nil)
+	expect(t, 404, "GET", fmt.Sprintf("%v/%d", path, first), u.Token, nil)
+}
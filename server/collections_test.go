@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+func TestCollections(t *testing.T) {
+	u := newUser(t)
+	other := newUser(t)
+	levelId := newLevel(t, u, "Collected")
+	expect(t, 400, "POST", "/collections/", u.Token, gin.H{"description": "No name"})
+
+	var created struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/collections/", u.Token, gin.H{"name": "Favorites", "description": "Hardest tracks"}).decode(t, &created)
+	id := created.Id
+	path := fmt.Sprintf("/collections/%d", id)
+
+	var body struct {
+		Collection models.CollectionData `json:"collection"`
+	}
+	expect(t, 200, "GET", path, other.Token, nil).decode(t, &body)
+	if body.Collection.Name != "Favorites" || body.Collection.Uid != u.Id {
+		t.Fatalf("unexpected collection %+v", body.Collection)
+	}
+	expect(t, 400, "GET", "/collections/abc", u.Token, nil)
+
+	var list struct {
+		Collections []models.CollectionData `json:"collections"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/collections/u/%d", u.Id), other.Token, nil).decode(t, &list)
+	if len(list.Collections) != 1 {
+		t.Fatalf("expected 1 collection, got %d", len(list.Collections))
+	}
+
+	expect(t, 403, "PUT", "/collections/", other.Token, gin.H{"id": id, "name": "Stolen"})
+	expect(t, 200, "PUT", "/collections/", u.Token, gin.H{"id": id, "name": "Favorite tracks"})
+	var results struct {
+		Results []models.Collection `json:"results"`
+	}
+	expect(t, 200, "POST", "/collections/query", other.Token, gin.H{"query": "favorite"}).decode(t, &results)
+	if len(results.Results) == 0 {
+		t.Fatal("expected query to find the collection")
+	}
+
+	link := gin.H{"levelId": levelId, "collectionId": id}
+	expect(t, 403, "POST", "/collections/level", other.Token, link)
+	expect(t, 200, "POST", "/collections/level", u.Token, link)
+	var levels struct {
+		Levels []models.Level `json:"levels"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/collections/levels/%d", id), other.Token, nil).decode(t, &levels)
+	if !containsLevel(levels.Levels, levelId) {
+		t.Fatalf("expected linked level %d, got %+v", levelId, levels.Levels)
+	}
+	expect(t, 200, "GET", "/collections/trending", other.Token, nil)
+
+	expect(t, 403, "DELETE", "/collections/level", other.Token, link)
+	expect(t, 200, "DELETE", "/collections/level", u.Token, link)
+	expect(t, 200, "GET", fmt.Sprintf("/collections/levels/%d", id), u.Token, nil).decode(t, &levels)
+	if len(levels.Levels) != 0 {
+		t.Fatalf("expected no levels after unlinking, got %d", len(levels.Levels))
+	}
+
+	// Deleting a collection also removes its links
+	expect(t, 200, "POST", "/collections/level", u.Token, link)
+	expect(t, 403, "DELETE", path, other.Token, nil)
+	expect(t, 200, "DELETE", path, u.Token, nil)
+	expect(t, 500, "GET", path, u.Token, nil)
+	expect(t, 200, "GET", fmt.Sprintf("/collections/levels/%d", id), u.Token, nil).decode(t, &levels)
+	if len(levels.Levels) != 0 {
+		t.Fatalf("expected the links to be deleted with the collection, got %d", len(levels.Levels))
+	}
+}
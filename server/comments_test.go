@@ -0,0 +1,101 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type commentList struct {
+	Comments   []models.CommentData `json:"comments"`
+	Results    []models.CommentData `json:"results"`
+	NextCursor string               `json:"nextCursor"`
+}
+
+func newComment(t *testing.T, u testUser, body gin.H) int {
+	t.Helper()
+	var res struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/comments/", u.Token, body).decode(t, &res)
+	return res.Id
+}
+
+func TestLevelComments(t *testing.T) {
+	owner := newUser(t)
+	author := newUser(t)
+	other := newUser(t)
+	level := newLevel(t, owner, "Commented canyon")
+
+	expect(t, 400, "POST", "/comments/", author.Token, gin.H{"body": "No target"})
+	expect(t, 400, "POST", "/comments/", author.Token, gin.H{"levelId": level})
+	first := newComment(t, author, gin.H{"levelId": level, "body": "Loved the zigzag"})
+	second := newComment(t, other, gin.H{"levelId": level, "body": "Too hard"})
+	reply := newComment(t, owner, gin.H{"parentId": first, "body": "Thanks!"})
+	expect(t, 400, "POST", "/comments/", other.Token, gin.H{"parentId": first, "collectionId": 1, "body": "Wrong target"})
+	expect(t, 404, "POST", "/comments/", other.Token, gin.H{"parentId": 1 << 30, "body": "Orphan"})
+	expect(t, 404, "POST", "/comments/", other.Token, gin.H{"levelId": 1 << 30, "body": "Nowhere"})
+	expect(t, 404, "POST", "/comments/", other.Token, gin.H{"collectionId": 1 << 30, "body": "Nowhere"})
+
+	var list commentList
+	expect(t, 200, "GET", fmt.Sprintf("/levels/%d/comments?limit=1", level), other.Token, nil).decode(t, &list)
+	if len(list.Comments) != 1 || list.Comments[0].Id != second || list.NextCursor == "" {
+		t.Fatalf("expected the newest comment first, got %+v", list)
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/%d/comments?limit=1&cursor=%v", level, list.NextCursor), other.Token, nil).decode(t, &list)
+	if len(list.Comments) != 1 || list.Comments[0].Id != first || list.NextCursor != "" {
+		t.Fatalf("expected the first comment on the last page, got %+v", list)
+	}
+	c := list.Comments[0]
+	if c.UserName != author.Name || c.Replies != 1 || c.LevelId == nil || *c.LevelId != level {
+		t.Fatalf("unexpected comment %+v", c)
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/comments/%d/replies", first), other.Token, nil).decode(t, &list)
+	if len(list.Comments) != 1 || list.Comments[0].Id != reply || *list.Comments[0].LevelId != level {
+		t.Fatalf("expected the reply on the level, got %+v", list.Comments)
+	}
+
+	path := fmt.Sprintf("/comments/%d", first)
+	expect(t, 403, "PUT", path, owner.Token, gin.H{"body": "Edited by someone else"})
+	expect(t, 200, "PUT", path, author.Token, gin.H{"body": "Loved the zigzag jump"})
+	var res struct {
+		Comment models.CommentData `json:"comment"`
+	}
+	expect(t, 200, "GET", path, other.Token, nil).decode(t, &res)
+	if res.Comment.Body != "Loved the zigzag jump" || !res.Comment.Updated.Valid {
+		t.Fatalf("expected the comment to be edited, got %+v", res.Comment)
+	}
+
+	expect(t, 200, "POST", "/comments/query", other.Token, gin.H{"query": "zigzag"}).decode(t, &list)
+	if len(list.Results) == 0 || list.Results[0].Id != first {
+		t.Fatalf("expected query to find comment %d, got %+v", first, list.Results)
+	}
+
+	// The level owner can delete any comment on it,
+	// along with its replies
+	expect(t, 403, "DELETE", fmt.Sprintf("/comments/%d", second), author.Token, nil)
+	expect(t, 200, "DELETE", path, owner.Token, nil)
+	expect(t, 404, "GET", path, other.Token, nil)
+	expect(t, 404, "GET", fmt.Sprintf("/comments/%d", reply), other.Token, nil)
+	expect(t, 200, "DELETE", fmt.Sprintf("/comments/%d", second), other.Token, nil)
+}
+
+func TestCollectionComments(t *testing.T) {
+	owner := newUser(t)
+	author := newUser(t)
+	var res struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/collections/", owner.Token, gin.H{"name": "Commented"}).decode(t, &res)
+	id := newComment(t, author, gin.H{"collectionId": res.Id, "body": "Great picks"})
+
+	var list commentList
+	expect(t, 200, "GET", fmt.Sprintf("/collections/%d/comments", res.Id), owner.Token, nil).decode(t, &list)
+	if len(list.Comments) != 1 || list.Comments[0].Id != id || list.Comments[0].UserName != author.Name {
+		t.Fatalf("unexpected comments %+v", list.Comments)
+	}
+	expect(t, 404, "GET", fmt.Sprintf("/collections/%d/comments", 1<<30), owner.Token, nil)
+	expect(t, 200, "DELETE", fmt.Sprintf("/comments/%d", id), owner.Token, nil)
+}
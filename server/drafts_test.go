@@ -0,0 +1,255 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+func TestDrafts(t *testing.T) {
+	u := newUser(t)
+	other := newUser(t)
+	expect(t, 400, "POST", "/drafts/", u.Token, gin.H{"name": "No car"})
+
+	var created struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/drafts/", u.Token, gin.H{
+		"name":       "Work in progress",
+		"car":        1,
+		"soundtrack": 2,
+		"courseData": gin.H{"blocks": []gin.H{}},
+	}).decode(t, &created)
+	path := fmt.Sprintf("/drafts/%d", created.Id)
+
+	var body struct {
+		Draft models.Draft `json:"draft"`
+	}
+	expect(t, 200, "GET", path, u.Token, nil).decode(t, &body)
+	if body.Draft.Name != "Work in progress" || body.Draft.Uid != u.Id {
+		t.Fatalf("unexpected draft %+v", body.Draft)
+	}
+	expect(t, 403, "GET", path, other.Token, nil)
+	expect(t, 400, "GET", "/drafts/abc", u.Token, nil)
+
+	expect(t, 200, "PUT", "/drafts/", u.Token, gin.H{"id": created.Id, "name": "Almost done", "revision": 1})
+	// Other users' drafts are not found
+	expect(t, 404, "PUT", "/drafts/", other.Token, gin.H{"id": created.Id, "name": "Hijacked", "revision": 2})
+	expect(t, 404, "PUT", "/drafts/", u.Token, gin.H{"id": 1 << 30, "name": "Missing", "revision": 1})
+	expect(t, 200, "GET", path, u.Token, nil).decode(t, &body)
+	if body.Draft.Name != "Almost done" {
+		t.Fatalf("expected name Almost done, got %v", body.Draft.Name)
+	}
+
+	var list struct {
+		Drafts []models.Draft `json:"drafts"`
+	}
+	expect(t, 200, "GET", "/drafts/u", u.Token, nil).decode(t, &list)
+	if len(list.Drafts) != 1 {
+		t.Fatalf("expected 1 draft, got %d", len(list.Drafts))
+	}
+
+	expect(t, 200, "DELETE", path, other.Token, nil)
+	expect(t, 200, "GET", path, u.Token, nil)
+	expect(t, 200, "DELETE", path, u.Token, nil)
+	expect(t, 500, "GET", path, u.Token, nil)
+}
+
+func TestPublishDraft(t *testing.T) {
+	u := newUser(t)
+	other := newUser(t)
+	var created struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/drafts/", u.Token, gin.H{
+		"name":       "To publish",
+		"car":        1,
+		"soundtrack": 1,
+		"courseData": gin.H{"blocks": []gin.H{{"x": 1}}},
+	}).decode(t, &created)
+	publish := gin.H{"draftId": created.Id, "difficulty": 3, "description": "Published from a draft", "theme": 2}
+	expect(t, 403, "POST", "/levels/fromDraft", other.Token, publish)
+	expect(t, 200, "POST", "/levels/fromDraft", u.Token, publish).decode(t, &created)
+	levelId := created.Id
+	expect(t, 500, "GET", fmt.Sprintf("/drafts/%d", publish["draftId"]), u.Token, nil)
+
+	// The owner gets a draft tied to the level
+	var body struct {
+		Draft models.Draft `json:"draft"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/drafts/level/%d", levelId), u.Token, nil).decode(t, &body)
+	if body.Draft.LevelId != levelId || body.Draft.Name != "To publish" {
+		t.Fatalf("unexpected level draft %+v", body.Draft)
+	}
+	draftId := body.Draft.Id
+	expect(t, 200, "PUT", "/drafts/", u.Token, gin.H{"id": draftId, "courseData": gin.H{"blocks": []gin.H{{"x": 2}}}, "car": 2, "soundtrack": 3, "revision": body.Draft.Revision})
+	revision := levelRevision(t, u, levelId)
+	expect(t, 400, "PUT", "/levels/fromDraft", other.Token, gin.H{"levelId": levelId, "draftId": draftId, "revision": revision})
+	expect(t, 404, "PUT", "/levels/fromDraft", u.Token, gin.H{"levelId": levelId, "draftId": 1 << 30, "revision": revision})
+	expect(t, 428, "PUT", "/levels/fromDraft", u.Token, gin.H{"levelId": levelId, "draftId": draftId})
+	expect(t, 409, "PUT", "/levels/fromDraft", u.Token, gin.H{"levelId": levelId, "draftId": draftId, "revision": revision + 1})
+	var updated struct {
+		Revision int `json:"revision"`
+	}
+	expect(t, 200, "PUT", "/levels/fromDraft", u.Token, gin.H{"levelId": levelId, "draftId": draftId, "revision": revision}).decode(t, &updated)
+	if updated.Revision != revision+1 {
+		t.Fatalf("expected revision %d, got %d", revision+1, updated.Revision)
+	}
+
+	// The level takes the car and soundtrack of the draft
+	var level struct {
+		Level models.Level `json:"level"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/info/%d", levelId), u.Token, nil).decode(t, &level)
+	if level.Level.Car != 2 || level.Level.Soundtrack != 3 || !level.Level.Updated.Valid {
+		t.Fatalf("expected the car, soundtrack and updated time of the draft, got %+v", level.Level)
+	}
+
+	// Anyone else gets a fork
+	expect(t, 200, "GET", fmt.Sprintf("/drafts/level/%d", levelId), other.Token, nil).decode(t, &body)
+	if body.Draft.LevelId != 0 || body.Draft.Uid != other.Id || body.Draft.Name != "Copia de To publish" {
+		t.Fatalf("unexpected fork %+v", body.Draft)
+	}
+	expect(t, 400, "GET", "/drafts/level/abc", u.Token, nil)
+}
+
+func TestDraftRevisions(t *testing.T) {
+	u := newUser(t)
+	var created struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/drafts/", u.Token, gin.H{"name": "Autosaved", "car": 1, "soundtrack": 1}).decode(t, &created)
+	path := fmt.Sprintf("/drafts/%d", created.Id)
+	if etag := expect(t, 200, "GET", path, u.Token, nil).Header.Get("ETag"); etag != `"1"` {
+		t.Fatalf("expected ETag \"1\", got %q", etag)
+	}
+
+	expect(t, 428, "PUT", "/drafts/", u.Token, gin.H{"id": created.Id, "name": "No revision"})
+	var saved struct {
+		Revision int `json:"revision"`
+	}
+	expect(t, 200, "PUT", "/drafts/", u.Token, gin.H{"id": created.Id, "name": "Tab one", "revision": 1}).decode(t, &saved)
+	if saved.Revision != 2 {
+		t.Fatalf("expected revision 2, got %d", saved.Revision)
+	}
+	res := callWithHeaders(t, "PUT", "/drafts/", u.Token, map[string]string{"If-Match": `W/"1"`}, gin.H{"id": created.Id, "name": "Tab two"})
+	var conflict struct {
+		Draft models.Draft `json:"draft"`
+	}
+	res.decode(t, &conflict)
+	if res.Code != 409 || conflict.Draft.Name != "Tab one" || res.Header.Get("ETag") != `"2"` {
+		t.Fatalf("expected a conflict with tab one's draft, got %d %s", res.Code, res.Body)
+	}
+	res = callWithHeaders(t, "PUT", "/drafts/", u.Token, map[string]string{"If-Match": "abc"}, gin.H{"id": created.Id, "name": "Tab two"})
+	if res.Code != 400 {
+		t.Fatalf("expected an invalid If-Match header to fail, got %d", res.Code)
+	}
+}
+
+func TestPatchDraft(t *testing.T) {
+	u := newUser(t)
+	var created struct {
+		Id int `json:"id"`
+	}
+	course := gin.H{"start": gin.H{"x": 0, "y": 0}, "blocks": []int{1, 2, 3}, "title": "Old"}
+	expect(t, 200, "POST", "/drafts/", u.Token, gin.H{"name": "Patched", "car": 1, "soundtrack": 1, "courseData": course}).decode(t, &created)
+	path := fmt.Sprintf("/drafts/%d", created.Id)
+	jsonPatch := func(rev string) map[string]string {
+		return map[string]string{"Content-Type": "application/json-patch+json", "If-Match": rev}
+	}
+	get := func() models.Draft {
+		var res struct {
+			Draft models.Draft `json:"draft"`
+		}
+		expect(t, 200, "GET", path, u.Token, nil).decode(t, &res)
+		return res.Draft
+	}
+
+	ops := []gin.H{
+		{"op": "test", "path": "/title", "value": "Old"},
+		{"op": "replace", "path": "/start/x", "value": 5},
+		{"op": "add", "path": "/blocks/-", "value": 4},
+		{"op": "remove", "path": "/blocks/0"},
+		{"op": "copy", "from": "/start", "path": "/finish"},
+		{"op": "move", "from": "/title", "path": "/name"},
+	}
+	res := callWithHeaders(t, "PATCH", path, u.Token, jsonPatch(`"1"`), ops)
+	if res.Code != 200 || res.Header.Get("ETag") != `"2"` {
+		t.Fatalf("expected the patch to apply, got %d %s", res.Code, res.Body)
+	}
+	d := get()
+	want := map[string]interface{}{
+		"start":  map[string]interface{}{"x": 5.0, "y": 0.0},
+		"finish": map[string]interface{}{"x": 5.0, "y": 0.0},
+		"blocks": []interface{}{2.0, 3.0, 4.0},
+		"name":   "Old",
+	}
+	if !reflect.DeepEqual(d.CourseData, want) || d.Revision != 2 {
+		t.Fatalf("unexpected patched draft: %+v", d)
+	}
+
+	// A failed test leaves the draft as is
+	res = callWithHeaders(t, "PATCH", path, u.Token, jsonPatch(`"2"`), []gin.H{
+		{"op": "remove", "path": "/finish"},
+		{"op": "test", "path": "/name", "value": "New"},
+	})
+	if res.Code != 422 {
+		t.Fatalf("expected a failed test to be rejected, got %d %s", res.Code, res.Body)
+	}
+	res = callWithHeaders(t, "PATCH", path, u.Token, jsonPatch(`"2"`), []gin.H{{"op": "replace", "path": "/missing", "value": 1}})
+	if res.Code != 422 {
+		t.Fatalf("expected a missing path to be rejected, got %d %s", res.Code, res.Body)
+	}
+	if d := get(); d.Revision != 2 || d.CourseData["finish"] == nil {
+		t.Fatalf("expected failed patches to change nothing, got %+v", d)
+	}
+
+	merge := map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": `"2"`}
+	res = callWithHeaders(t, "PATCH", path, u.Token, merge, gin.H{"finish": nil, "start": gin.H{"y": 7}})
+	if res.Code != 200 || res.Header.Get("ETag") != `"3"` {
+		t.Fatalf("expected the merge patch to apply, got %d %s", res.Code, res.Body)
+	}
+	d = get()
+	if _, ok := d.CourseData["finish"]; ok || !reflect.DeepEqual(d.CourseData["start"], map[string]interface{}{"x": 5.0, "y": 7.0}) {
+		t.Fatalf("unexpected merged draft: %+v", d.CourseData)
+	}
+
+	// Patches against an old revision conflict
+	res = callWithHeaders(t, "PATCH", path, u.Token, merge, gin.H{"title": "Stale"})
+	var conflict struct {
+		Draft models.Draft `json:"draft"`
+	}
+	res.decode(t, &conflict)
+	if res.Code != 409 || conflict.Draft.Revision != 3 || res.Header.Get("ETag") != `"3"` {
+		t.Fatalf("expected a conflict with revision 3, got %d %s", res.Code, res.Body)
+	}
+
+	res = callWithHeaders(t, "PATCH", path, u.Token, map[string]string{"Content-Type": "application/merge-patch+json"}, gin.H{"title": "New"})
+	if res.Code != 428 {
+		t.Fatalf("expected a patch without If-Match to fail, got %d", res.Code)
+	}
+	res = callWithHeaders(t, "PATCH", path, u.Token, map[string]string{"If-Match": `"3"`}, gin.H{"title": "New"})
+	if res.Code != 415 {
+		t.Fatalf("expected plain JSON to be unsupported, got %d", res.Code)
+	}
+	res = callWithHeaders(t, "PATCH", path, u.Token, jsonPatch(`"3"`), gin.H{"op": "add"})
+	if res.Code != 400 {
+		t.Fatalf("expected a patch that isn't an array to fail, got %d", res.Code)
+	}
+	other := newUser(t)
+	res = callWithHeaders(t, "PATCH", path, u.Token, jsonPatch("*"), []gin.H{{"op": "remove", "path": "/start"}})
+	if res.Code != 428 {
+		t.Fatalf("expected a wildcard If-Match to be rejected, got %d", res.Code)
+	}
+	res = callWithHeaders(t, "PATCH", path, other.Token, jsonPatch(`"3"`), []gin.H{{"op": "remove", "path": "/start"}})
+	if res.Code != 403 {
+		t.Fatalf("expected other users to be forbidden, got %d", res.Code)
+	}
+	res = callWithHeaders(t, "PATCH", "/drafts/999999", u.Token, jsonPatch(`"1"`), []gin.H{})
+	if res.Code != 404 {
+		t.Fatalf("expected a missing draft to 404, got %d", res.Code)
+	}
+}
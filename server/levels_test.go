@@ -0,0 +1,540 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+func TestLevels(t *testing.T) {
+	owner := newUser(t)
+	other := newUser(t)
+	expect(t, 400, "POST", "/levels/", owner.Token, gin.H{"name": "Incomplete"})
+	id := newLevel(t, owner, "Volcano sprint")
+	path := fmt.Sprintf("/levels/%d", id)
+
+	var body struct {
+		Level models.Level `json:"level"`
+	}
+	expect(t, 200, "GET", path, other.Token, nil).decode(t, &body)
+	if body.Level.Name != "Volcano sprint" || body.Level.Uid != owner.Id || body.Level.CourseData == nil {
+		t.Fatalf("unexpected level %+v", body.Level)
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/info/%d", id), other.Token, nil)
+	expect(t, 400, "GET", "/levels/abc", other.Token, nil)
+	expect(t, 400, "GET", "/levels/info/0", other.Token, nil)
+
+	var list struct {
+		Levels  []models.Level `json:"levels"`
+		Results []models.Level `json:"results"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/u/%d", owner.Id), other.Token, nil).decode(t, &list)
+	if len(list.Levels) != 1 || list.Levels[0].Id != id {
+		t.Fatalf("expected the owner's level, got %+v", list.Levels)
+	}
+	expect(t, 200, "POST", "/levels/query", other.Token, gin.H{"query": "volcano"}).decode(t, &list)
+	if !containsLevel(list.Results, id) {
+		t.Fatalf("expected query to find level %d, got %+v", id, list.Results)
+	}
+	expect(t, 400, "POST", "/levels/query", other.Token, gin.H{"sort": "oldest"})
+	expect(t, 200, "GET", "/levels/trending", other.Token, nil)
+	expect(t, 200, "GET", fmt.Sprintf("/levels/leaderboard/%d", id), other.Token, nil)
+
+	update := gin.H{"id": id, "name": "Volcano marathon", "revision": 1}
+	expect(t, 403, "PUT", "/levels/", other.Token, update)
+	expect(t, 200, "PUT", "/levels/", owner.Token, update)
+	expect(t, 200, "GET", path, owner.Token, nil).decode(t, &body)
+	if body.Level.Name != "Volcano marathon" {
+		t.Fatalf("expected name to be updated, got %v", body.Level.Name)
+	}
+
+	expect(t, 403, "DELETE", path, other.Token, nil)
+	expect(t, 200, "DELETE", path, owner.Token, nil)
+	expect(t, 404, "GET", path, owner.Token, nil)
+	expect(t, 404, "DELETE", path, owner.Token, nil)
+}
+
+func TestModeratorManagesLevels(t *testing.T) {
+	owner := newUser(t)
+	mod := newUser(t)
+	id := newLevel(t, owner, "Moderated")
+	mod.setRole(t, models.RoleModerator)
+
+	expect(t, 200, "PUT", "/levels/", mod.Token, gin.H{"id": id, "description": "Edited by a moderator", "revision": 1})
+	var body struct {
+		Level models.Level `json:"level"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/%d", id), owner.Token, nil).decode(t, &body)
+	if body.Level.Uid != owner.Id {
+		t.Fatalf("moderator edit changed the owner to %d", body.Level.Uid)
+	}
+	expect(t, 200, "DELETE", fmt.Sprintf("/levels/%d", id), mod.Token, nil)
+}
+
+func containsLevel(levels []models.Level, id int) bool {
+	for _, l := range levels {
+		if l.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPagination(t *testing.T) {
+	u := newUser(t)
+	ids := []int{}
+	for i := 0; i < 5; i++ {
+		ids = append(ids, newLevel(t, u, fmt.Sprintf("Glacier %d", i)))
+	}
+
+	// Newest first, following the cursor
+	seen := []int{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination did not end")
+		}
+		var list struct {
+			Levels     []models.Level `json:"levels"`
+			NextCursor string         `json:"nextCursor"`
+		}
+		expect(t, 200, "GET", fmt.Sprintf("/levels/u/%d?limit=2&cursor=%v", u.Id, cursor), u.Token, nil).decode(t, &list)
+		for _, l := range list.Levels {
+			seen = append(seen, l.Id)
+		}
+		if list.NextCursor == "" {
+			break
+		}
+		cursor = list.NextCursor
+	}
+	if len(seen) != len(ids) {
+		t.Fatalf("expected %d levels, got %v", len(ids), seen)
+	}
+	for i, id := range seen {
+		if id != ids[len(ids)-1-i] {
+			t.Fatalf("expected newest first %v, got %v", ids, seen)
+		}
+	}
+
+	var results struct {
+		Results    []models.Level `json:"results"`
+		NextCursor string         `json:"nextCursor"`
+	}
+	expect(t, 200, "POST", "/levels/query", u.Token, gin.H{"query": "glacier", "limit": 3}).decode(t, &results)
+	if len(results.Results) != 3 || results.NextCursor == "" {
+		t.Fatalf("expected a first page of 3 results, got %d", len(results.Results))
+	}
+	first := append([]models.Level{}, results.Results...)
+	expect(t, 200, "POST", "/levels/query", u.Token, gin.H{"query": "glacier", "limit": 3, "cursor": results.NextCursor}).decode(t, &results)
+	if len(results.Results) != 2 || results.NextCursor != "" {
+		t.Fatalf("expected a last page of 2 results, got %d", len(results.Results))
+	}
+	for _, l := range results.Results {
+		if containsLevel(first, l.Id) {
+			t.Fatalf("level %d is in both pages", l.Id)
+		}
+	}
+
+	expect(t, 400, "GET", fmt.Sprintf("/levels/u/%d?limit=1000", u.Id), u.Token, nil)
+	expect(t, 400, "GET", fmt.Sprintf("/levels/u/%d?limit=abc", u.Id), u.Token, nil)
+	expect(t, 400, "GET", fmt.Sprintf("/levels/u/%d?cursor=not-a-cursor", u.Id), u.Token, nil)
+	expect(t, 400, "POST", "/levels/query", u.Token, gin.H{"query": "glacier", "cursor": "%%%"})
+}
+
+func TestLevelVotes(t *testing.T) {
+	owner := newUser(t)
+	fan := newUser(t)
+	critic := newUser(t)
+	id := newLevel(t, owner, "Rated ramp")
+	path := fmt.Sprintf("/levels/%d/vote", id)
+
+	expect(t, 403, "PUT", path, owner.Token, gin.H{"liked": true})
+	expect(t, 400, "PUT", path, fan.Token, gin.H{})
+	expect(t, 400, "PUT", path, fan.Token, gin.H{"stars": 6})
+	expect(t, 404, "GET", path, fan.Token, nil)
+
+	expect(t, 200, "PUT", path, fan.Token, gin.H{"liked": true, "stars": 5})
+	expect(t, 200, "PUT", path, critic.Token, gin.H{"liked": true, "stars": 1})
+	// Changing the vote keeps the parts that aren't sent
+	var res struct {
+		Rating models.LevelRating `json:"rating"`
+	}
+	expect(t, 200, "PUT", path, critic.Token, gin.H{"liked": false}).decode(t, &res)
+	if res.Rating.Likes != 1 || res.Rating.Dislikes != 1 || res.Rating.Ratings != 2 || res.Rating.Average != 3 {
+		t.Fatalf("unexpected rating %+v", res.Rating)
+	}
+	var vote struct {
+		Vote models.LevelVote `json:"vote"`
+	}
+	expect(t, 200, "GET", path, critic.Token, nil).decode(t, &vote)
+	if vote.Vote.Liked == nil || *vote.Vote.Liked || vote.Vote.Stars == nil || *vote.Vote.Stars != 1 {
+		t.Fatalf("unexpected vote %+v", vote.Vote)
+	}
+
+	var info struct {
+		Level models.Level `json:"level"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/info/%d", id), owner.Token, nil).decode(t, &info)
+	if info.Level.Rating == nil || info.Level.Rating.Ratings != 2 || info.Level.Rating.Score == 0 {
+		t.Fatalf("expected level info to include the rating, got %+v", info.Level.Rating)
+	}
+
+	expect(t, 200, "DELETE", path, critic.Token, nil)
+	expect(t, 404, "DELETE", path, critic.Token, nil)
+	expect(t, 200, "GET", fmt.Sprintf("/levels/info/%d", id), owner.Token, nil).decode(t, &info)
+	if info.Level.Rating.Ratings != 1 || info.Level.Rating.Average != 5 {
+		t.Fatalf("expected the deleted vote not to count, got %+v", info.Level.Rating)
+	}
+}
+
+func TestTrendingByRating(t *testing.T) {
+	voter := newUser(t)
+	owner := newUser(t)
+	// A single perfect rating shouldn't beat many
+	single := newLevel(t, owner, "Lucky")
+	popular := newLevel(t, owner, "Beloved")
+	disliked := newLevel(t, owner, "Disliked")
+	expect(t, 200, "PUT", fmt.Sprintf("/levels/%d/vote", single), voter.Token, gin.H{"stars": 5})
+	expect(t, 200, "PUT", fmt.Sprintf("/levels/%d/vote", disliked), voter.Token, gin.H{"stars": 1})
+	for i := 0; i < 10; i++ {
+		u := newUser(t)
+		expect(t, 200, "PUT", fmt.Sprintf("/levels/%d/vote", popular), u.Token, gin.H{"stars": 5})
+	}
+
+	var body struct {
+		Levels []models.Level `json:"levels"`
+	}
+	expect(t, 200, "GET", "/levels/trending?sort=rating", voter.Token, nil).decode(t, &body)
+	pos := map[int]int{}
+	for i, l := range body.Levels {
+		if l.Rating == nil {
+			t.Fatalf("expected rated levels to include their rating")
+		}
+		pos[l.Id] = i + 1
+	}
+	if pos[single] == 0 || pos[popular] == 0 || pos[popular] > pos[single] || pos[disliked] < pos[single] {
+		t.Fatalf("expected %d to rank above %d, got %+v", popular, single, body.Levels)
+	}
+	expect(t, 400, "GET", "/levels/trending?sort=random", voter.Token, nil)
+}
+
+func TestDifficultyVotes(t *testing.T) {
+	owner := newUser(t)
+	finisher := newUser(t)
+	quitter := newUser(t)
+	id := newLevel(t, owner, "Glacier gauntlet")
+	path := fmt.Sprintf("/levels/%d/difficulty", id)
+
+	expect(t, 200, "POST", "/t/", finisher.Token, gin.H{"eventType": "game_start", "levelId": id})
+	expect(t, 200, "POST", "/t/", finisher.Token, gin.H{"eventType": "game_finish", "levelId": id, "time": 90000})
+	expect(t, 200, "POST", "/t/", quitter.Token, gin.H{"eventType": "game_start", "levelId": id})
+
+	expect(t, 403, "PUT", path, quitter.Token, gin.H{"difficulty": 5})
+	expect(t, 400, "PUT", path, finisher.Token, gin.H{"difficulty": 9})
+	expect(t, 404, "GET", path, finisher.Token, nil)
+	expect(t, 200, "PUT", path, finisher.Token, gin.H{"difficulty": 4})
+	expect(t, 200, "PUT", path, finisher.Token, gin.H{"difficulty": 5})
+
+	var info struct {
+		Level models.Level `json:"level"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/info/%d", id), owner.Token, nil).decode(t, &info)
+	d := info.Level.CommunityDifficulty
+	if d == nil || d.Votes != 1 || d.AverageVote != 5 || d.Starts != 2 || d.Finishes != 1 || d.AvgTime != 90000 {
+		t.Fatalf("unexpected community difficulty %+v", d)
+	}
+	// The creator said 2, the vote and the
+	// completion rate say it's harder
+	if d.Value <= 2 || d.Value > 5 {
+		t.Fatalf("expected a community difficulty above the creator's, got %v", d.Value)
+	}
+
+	var list struct {
+		Results []models.Level `json:"results"`
+	}
+	query := gin.H{"query": "glacier", "minDifficulty": d.Value - 0.01}
+	expect(t, 200, "POST", "/levels/query", owner.Token, query).decode(t, &list)
+	if !containsLevel(list.Results, id) {
+		t.Fatalf("expected the level within the difficulty range, got %+v", list.Results)
+	}
+	query = gin.H{"query": "glacier", "maxDifficulty": d.Value - 0.01}
+	expect(t, 200, "POST", "/levels/query", owner.Token, query).decode(t, &list)
+	if containsLevel(list.Results, id) {
+		t.Fatalf("expected the level to be filtered out, got %+v", list.Results)
+	}
+	expect(t, 400, "POST", "/levels/query", owner.Token, gin.H{"query": "glacier", "minDifficulty": 7})
+
+	// Creators rate their level when they publish it
+	expect(t, 200, "POST", "/t/", owner.Token, gin.H{"eventType": "game_finish", "levelId": id, "time": 60000})
+	expect(t, 403, "PUT", path, owner.Token, gin.H{"difficulty": 1})
+
+	expect(t, 200, "DELETE", path, finisher.Token, nil)
+	expect(t, 404, "DELETE", path, finisher.Token, nil)
+}
+
+func TestLevelSearch(t *testing.T) {
+	owner := newUser(t)
+	player := newUser(t)
+	create := func(name string, theme, car int) int {
+		body := levelBody(name)
+		body["theme"], body["car"] = theme, car
+		var res struct {
+			Id int `json:"id"`
+		}
+		expect(t, 200, "POST", "/levels/", owner.Token, body).decode(t, &res)
+		return res.Id
+	}
+	desert := create("Desert dunes", 1, 1)
+	jungle := create("Jungle dunes", 2, 1)
+	night := create("Night drive", 2, 3)
+	for i := 0; i < 2; i++ {
+		expect(t, 200, "POST", "/t/", player.Token, gin.H{"eventType": "game_start", "levelId": jungle})
+	}
+	expect(t, 200, "POST", "/t/", player.Token, gin.H{"eventType": "game_start", "levelId": night})
+	expect(t, 200, "PUT", fmt.Sprintf("/levels/%d/vote", desert), player.Token, gin.H{"stars": 5})
+	expect(t, 200, "PUT", fmt.Sprintf("/levels/%d/vote", night), player.Token, gin.H{"stars": 2})
+
+	var res struct {
+		Results    []models.Level `json:"results"`
+		NextCursor string         `json:"nextCursor"`
+		Facets     *struct {
+			Themes []models.FacetCount `json:"themes"`
+			Cars   []models.FacetCount `json:"cars"`
+		} `json:"facets"`
+	}
+	search := func(body gin.H) []int {
+		t.Helper()
+		body["creator"] = owner.Id
+		res.Facets = nil
+		expect(t, 200, "POST", "/levels/query", player.Token, body).decode(t, &res)
+		ids := []int{}
+		for _, l := range res.Results {
+			ids = append(ids, l.Id)
+		}
+		return ids
+	}
+	expectIds := func(got []int, want ...int) {
+		t.Helper()
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("expected levels %v, got %v", want, got)
+		}
+	}
+
+	// An empty query lists every level, newest first
+	expectIds(search(gin.H{}), night, jungle, desert)
+	expectIds(search(gin.H{"query": "dunes", "sort": "newest"}), jungle, desert)
+	expectIds(search(gin.H{"themes": []int{2}}), night, jungle)
+	expectIds(search(gin.H{"themes": []int{2}, "cars": []int{1}}), jungle)
+	expectIds(search(gin.H{"minPlays": 1}), night, jungle)
+	expectIds(search(gin.H{"minRating": 3}), desert)
+	expectIds(search(gin.H{"createdFrom": time.Now().Add(time.Hour)}))
+	expectIds(search(gin.H{"createdTo": time.Now().Add(time.Hour)}), night, jungle, desert)
+	// None of the levels was updated
+	expectIds(search(gin.H{"updatedFrom": time.Now().Add(-time.Hour)}))
+
+	expectIds(search(gin.H{"sort": "plays"}), jungle, night, desert)
+	expectIds(search(gin.H{"sort": "rating"}), desert, night, jungle)
+	// Pages keep the sort order
+	expectIds(search(gin.H{"sort": "plays", "limit": 2}), jungle, night)
+	expectIds(search(gin.H{"sort": "plays", "limit": 2, "cursor": res.NextCursor}), desert)
+	if res.NextCursor != "" {
+		t.Fatalf("expected the last page, got cursor %q", res.NextCursor)
+	}
+
+	// Each facet ignores its own filter
+	expectIds(search(gin.H{"themes": []int{2}, "facets": true}), night, jungle)
+	if res.Facets == nil {
+		t.Fatalf("expected facet counts")
+	}
+	themes := fmt.Sprint(res.Facets.Themes)
+	if themes != "[{2 2} {1 1}]" {
+		t.Fatalf("unexpected theme facet %v", themes)
+	}
+	if cars := fmt.Sprint(res.Facets.Cars); cars != "[{1 1} {3 1}]" {
+		t.Fatalf("unexpected car facet %v", cars)
+	}
+	search(gin.H{})
+	if res.Facets != nil {
+		t.Fatalf("expected facets only when requested")
+	}
+
+	expect(t, 400, "POST", "/levels/query", player.Token, gin.H{"sort": "oldest"})
+	expect(t, 400, "POST", "/levels/query", player.Token, gin.H{"minRating": 9})
+}
+
+func TestLevelVisibility(t *testing.T) {
+	owner := newUser(t)
+	other := newUser(t)
+	mod := newUser(t)
+	mod.setRole(t, models.RoleModerator)
+	create := func(name, visibility string) int {
+		b := levelBody(name)
+		b["visibility"] = visibility
+		var res struct {
+			Id int `json:"id"`
+		}
+		expect(t, 200, "POST", "/levels/", owner.Token, b).decode(t, &res)
+		return res.Id
+	}
+	bad := levelBody("Hidden glacier")
+	bad["visibility"] = "secret"
+	expect(t, 400, "POST", "/levels/", owner.Token, bad)
+
+	private := create("Hidden glacier private", "private")
+	unlisted := create("Hidden glacier unlisted", "unlisted")
+	for _, id := range []int{private, unlisted} {
+		expect(t, 404, "GET", fmt.Sprintf("/levels/%d", id), other.Token, nil)
+		expect(t, 404, "GET", fmt.Sprintf("/levels/info/%d", id), other.Token, nil)
+		expect(t, 404, "GET", fmt.Sprintf("/drafts/level/%d", id), other.Token, nil)
+		expect(t, 200, "GET", fmt.Sprintf("/levels/%d", id), owner.Token, nil)
+		expect(t, 200, "GET", fmt.Sprintf("/levels/%d", id), mod.Token, nil)
+		expect(t, 200, "POST", "/t/", other.Token, gin.H{"eventType": "game_start", "levelId": id})
+	}
+
+	// Nothing scoped to a hidden level reveals it
+	var comment struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/comments/", owner.Token, gin.H{"levelId": private, "body": "Hidden glacier notes"}).decode(t, &comment)
+	hidden := []struct{ method, path string }{
+		{"GET", fmt.Sprintf("/levels/%d/comments", private)},
+		{"GET", fmt.Sprintf("/comments/%d", comment.Id)},
+		{"GET", fmt.Sprintf("/comments/%d/replies", comment.Id)},
+		{"GET", fmt.Sprintf("/levels/%d/vote", private)},
+		{"PUT", fmt.Sprintf("/levels/%d/vote", private)},
+		{"DELETE", fmt.Sprintf("/levels/%d/vote", private)},
+		{"GET", fmt.Sprintf("/levels/%d/difficulty", private)},
+		{"PUT", fmt.Sprintf("/levels/%d/difficulty", private)},
+		{"DELETE", fmt.Sprintf("/levels/%d/difficulty", private)},
+		{"GET", fmt.Sprintf("/levels/leaderboard/%d", private)},
+		{"POST", fmt.Sprintf("/stats/%d/gameStarts", private)},
+		{"POST", fmt.Sprintf("/stats/%d/uniqueUsers", private)},
+	}
+	for _, r := range hidden {
+		expect(t, 404, r.method, r.path, other.Token, gin.H{"liked": true, "difficulty": 3})
+	}
+	expect(t, 404, "POST", "/comments/", other.Token, gin.H{"levelId": private, "body": "Found it"})
+	expect(t, 404, "POST", "/comments/", other.Token, gin.H{"parentId": comment.Id, "body": "Found it"})
+	expect(t, 200, "GET", fmt.Sprintf("/levels/%d/comments", private), owner.Token, nil)
+	expect(t, 200, "GET", fmt.Sprintf("/levels/leaderboard/%d", private), mod.Token, nil)
+	var found struct {
+		Results []models.CommentData `json:"results"`
+	}
+	expect(t, 200, "POST", "/comments/query", other.Token, gin.H{"query": "glacier notes"}).decode(t, &found)
+	if len(found.Results) != 0 {
+		t.Fatalf("expected search to skip comments on hidden levels, got %+v", found.Results)
+	}
+
+	var list struct {
+		Levels  []models.Level `json:"levels"`
+		Results []models.Level `json:"results"`
+	}
+	expect(t, 200, "POST", "/levels/query", other.Token, gin.H{"query": "hidden glacier"}).decode(t, &list)
+	if containsLevel(list.Results, private) || containsLevel(list.Results, unlisted) {
+		t.Fatalf("expected search to skip hidden levels, got %+v", list.Results)
+	}
+	expect(t, 200, "GET", "/levels/trending", other.Token, nil).decode(t, &list)
+	if containsLevel(list.Levels, private) || containsLevel(list.Levels, unlisted) {
+		t.Fatalf("expected trending to skip hidden levels, got %+v", list.Levels)
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/u/%d", owner.Id), other.Token, nil).decode(t, &list)
+	if len(list.Levels) != 0 {
+		t.Fatalf("expected no public levels, got %+v", list.Levels)
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/u/%d", owner.Id), owner.Token, nil).decode(t, &list)
+	if len(list.Levels) != 2 {
+		t.Fatalf("expected the owner to see their levels, got %+v", list.Levels)
+	}
+
+	var body struct {
+		Level models.Level `json:"level"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/%d", unlisted), owner.Token, nil).decode(t, &body)
+	token := body.Level.ShareToken
+	if token == "" {
+		t.Fatalf("expected unlisted level to have a share token")
+	}
+	expect(t, 200, "GET", "/levels/shared/"+token, other.Token, nil).decode(t, &body)
+	if body.Level.Id != unlisted || body.Level.CourseData == nil {
+		t.Fatalf("unexpected shared level %+v", body.Level)
+	}
+	expect(t, 404, "GET", "/levels/shared/not-a-token", other.Token, nil)
+
+	sharePath := fmt.Sprintf("/levels/%d/share", unlisted)
+	expect(t, 403, "POST", sharePath, other.Token, nil)
+	expect(t, 400, "POST", fmt.Sprintf("/levels/%d/share", private), owner.Token, nil)
+	var share struct {
+		ShareToken string `json:"shareToken"`
+		ShareLink  string `json:"shareLink"`
+	}
+	expect(t, 200, "POST", sharePath, owner.Token, nil).decode(t, &share)
+	if share.ShareToken == "" || share.ShareToken == token {
+		t.Fatalf("expected a new share token, got %+v", share)
+	}
+	expect(t, 404, "GET", "/levels/shared/"+token, other.Token, nil)
+	expect(t, 200, "GET", "/levels/shared/"+share.ShareToken, other.Token, nil)
+
+	// Making the level public drops its share link
+	expect(t, 200, "PUT", "/levels/", owner.Token, gin.H{"id": unlisted, "visibility": "public", "revision": levelRevision(t, owner, unlisted)})
+	expect(t, 404, "GET", "/levels/shared/"+share.ShareToken, other.Token, nil)
+	body.Level = models.Level{}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/%d", unlisted), other.Token, nil).decode(t, &body)
+	if body.Level.Visibility != models.VisibilityPublic || body.Level.ShareToken != "" {
+		t.Fatalf("unexpected public level %+v", body.Level)
+	}
+	expect(t, 400, "PUT", "/levels/", owner.Token, gin.H{"id": unlisted, "visibility": "secret"})
+}
+
+func TestLevelRevisions(t *testing.T) {
+	owner := newUser(t)
+	id := newLevel(t, owner, "Two tabs")
+	res := expect(t, 200, "GET", fmt.Sprintf("/levels/%d", id), owner.Token, nil)
+	if etag := res.Header.Get("ETag"); etag != `"1"` {
+		t.Fatalf("expected ETag \"1\", got %q", etag)
+	}
+
+	expect(t, 428, "PUT", "/levels/", owner.Token, gin.H{"id": id, "name": "No revision"})
+	expect(t, 400, "PUT", "/levels/", owner.Token, gin.H{"id": id, "name": "Bad revision", "revision": -1})
+	res = callWithHeaders(t, "PUT", "/levels/", owner.Token, map[string]string{"If-Match": `"1"`}, gin.H{"id": id, "name": "First tab"})
+	if res.Code != 200 || res.Header.Get("ETag") != `"2"` {
+		t.Fatalf("expected revision 2, got %d %s", res.Code, res.Body)
+	}
+
+	// The second tab still has revision 1
+	res = callWithHeaders(t, "PUT", "/levels/", owner.Token, map[string]string{"If-Match": `"1"`}, gin.H{"id": id, "name": "Second tab"})
+	var conflict struct {
+		Level models.Level `json:"level"`
+	}
+	res.decode(t, &conflict)
+	if res.Code != 409 || conflict.Level.Name != "First tab" || conflict.Level.Revision != 2 || conflict.Level.CourseData == nil {
+		t.Fatalf("expected a conflict with the first tab's level, got %d %s", res.Code, res.Body)
+	}
+	expect(t, 409, "PUT", "/levels/", owner.Token, gin.H{"id": id, "name": "Second tab", "revision": 1})
+	expect(t, 200, "PUT", "/levels/", owner.Token, gin.H{"id": id, "name": "Second tab", "revision": 2})
+	// If-Match: * updates any revision
+	res = callWithHeaders(t, "PUT", "/levels/", owner.Token, map[string]string{"If-Match": "*"}, gin.H{"id": id, "name": "Any tab"})
+	if res.Code != 200 {
+		t.Fatalf("expected If-Match: * to update, got %d %s", res.Code, res.Body)
+	}
+	if rev := levelRevision(t, owner, id); rev != 4 {
+		t.Fatalf("expected revision 4, got %d", rev)
+	}
+}
+
+func TestUnknownIds(t *testing.T) {
+	u := newUser(t)
+	missing := 999999
+	expect(t, 404, "DELETE", fmt.Sprintf("/levels/%d", missing), u.Token, nil)
+	expect(t, 404, "PUT", "/levels/", u.Token, gin.H{"id": missing, "name": "Nowhere", "revision": 1})
+	expect(t, 404, "POST", fmt.Sprintf("/levels/%d/share", missing), u.Token, nil)
+	expect(t, 404, "PUT", fmt.Sprintf("/levels/%d/vote", missing), u.Token, gin.H{"liked": true})
+	expect(t, 404, "PUT", fmt.Sprintf("/levels/%d/tags", missing), u.Token, gin.H{"tags": []string{"ice"}})
+	expect(t, 404, "POST", fmt.Sprintf("/levels/%d/versions/1/rollback", missing), u.Token, nil)
+	expect(t, 404, "DELETE", fmt.Sprintf("/collections/%d", missing), u.Token, nil)
+	expect(t, 404, "PUT", "/collections/", u.Token, gin.H{"id": missing, "name": "Nowhere"})
+	expect(t, 404, "POST", "/collections/level", u.Token, gin.H{"collectionId": missing, "levelId": 1})
+	expect(t, 404, "DELETE", "/collections/level", u.Token, gin.H{"collectionId": missing, "levelId": 1})
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+// Forks the level and publishes the fork as u
+func remix(t *testing.T, u testUser, levelId int) int {
+	t.Helper()
+	var body struct {
+		Draft models.Draft `json:"draft"`
+		Id    int          `json:"id"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/drafts/level/%d", levelId), u.Token, nil).decode(t, &body)
+	if body.Draft.SourceLevelId == nil || *body.Draft.SourceLevelId != levelId || body.Draft.SourceVersion == nil {
+		t.Fatalf("expected the fork to record its source, got %+v", body.Draft)
+	}
+	publish := gin.H{"draftId": body.Draft.Id, "difficulty": 3, "description": "A remix", "theme": 1}
+	expect(t, 200, "POST", "/levels/fromDraft", u.Token, publish).decode(t, &body)
+	return body.Id
+}
+
+func TestRemixes(t *testing.T) {
+	owner := newUser(t)
+	remixer := newUser(t)
+	other := newUser(t)
+	origin := newLevel(t, owner, "Origin")
+	expect(t, 200, "PUT", "/levels/", owner.Token, gin.H{"id": origin, "courseData": gin.H{"blocks": []gin.H{}}, "revision": 1})
+	first := remix(t, remixer, origin)
+	second := remix(t, other, first)
+
+	var info struct {
+		Level models.Level `json:"level"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/info/%d", first), other.Token, nil).decode(t, &info)
+	l := info.Level
+	if l.SourceLevelId == nil || *l.SourceLevelId != origin || l.SourceVersion == nil || *l.SourceVersion != 2 {
+		t.Fatalf("expected a remix of version 2 of %d, got %+v", origin, l)
+	}
+	if l.Remixes == nil || *l.Remixes != 1 {
+		t.Fatalf("expected one remix, got %v", l.Remixes)
+	}
+
+	var list struct {
+		Levels []models.Level `json:"levels"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/%d/remixes", origin), other.Token, nil).decode(t, &list)
+	if len(list.Levels) != 1 || list.Levels[0].Id != first {
+		t.Fatalf("expected the remix of %d, got %+v", origin, list.Levels)
+	}
+
+	var lineage struct {
+		Lineage []models.LineageEntry `json:"lineage"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/%d/lineage", second), owner.Token, nil).decode(t, &lineage)
+	if len(lineage.Lineage) != 2 || lineage.Lineage[0].LevelId != first || lineage.Lineage[1].LevelId != origin ||
+		lineage.Lineage[1].Level == nil || lineage.Lineage[1].Level.Name != "Origin" {
+		t.Fatalf("unexpected lineage %+v", lineage.Lineage)
+	}
+
+	// Hidden levels aren't listed or counted, and their
+	// details are left out of lineages
+	expect(t, 200, "PUT", "/levels/", remixer.Token, gin.H{"id": first, "visibility": "private", "revision": levelRevision(t, remixer, first)})
+	expect(t, 200, "GET", fmt.Sprintf("/levels/%d/remixes", origin), other.Token, nil).decode(t, &list)
+	if len(list.Levels) != 0 {
+		t.Fatalf("expected private remixes to be hidden, got %+v", list.Levels)
+	}
+	lineage.Lineage = nil
+	expect(t, 200, "GET", fmt.Sprintf("/levels/%d/lineage", second), other.Token, nil).decode(t, &lineage)
+	if len(lineage.Lineage) != 2 || lineage.Lineage[0].Level != nil || lineage.Lineage[1].Level == nil {
+		t.Fatalf("expected the private source to be hidden, got %+v", lineage.Lineage)
+	}
+	expect(t, 404, "GET", fmt.Sprintf("/levels/%d/remixes", first), other.Token, nil)
+	expect(t, 404, "GET", fmt.Sprintf("/levels/%d/lineage", first), other.Token, nil)
+
+	// Deleting a source ends the lineage
+	expect(t, 200, "DELETE", fmt.Sprintf("/levels/%d", first), remixer.Token, nil)
+	expect(t, 200, "GET", fmt.Sprintf("/levels/%d/lineage", second), other.Token, nil).decode(t, &lineage)
+	if len(lineage.Lineage) != 0 {
+		t.Fatalf("expected an empty lineage, got %+v", lineage.Lineage)
+	}
+	expect(t, 400, "GET", "/levels/abc/lineage", other.Token, nil)
+}
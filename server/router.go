@@ -0,0 +1,153 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/middleware"
+	"github.com/sofferjacob/maker_api/models"
+	"github.com/sofferjacob/maker_api/routes"
+)
+
+// Registers every route of the API
+func newRouter() *gin.Engine {
+	r := gin.Default()
+
+	r.Use(cors.New(cors.Config{
+		AllowAllOrigins:  true,
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowCredentials: true,
+		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "If-Match"},
+		ExposeHeaders:    []string{"Content-Type", "Content-Length", "ETag"},
+		MaxAge:           12 * time.Hour,
+	}))
+
+	id := r.Group("/id")
+	{
+		id.POST("/register", routes.Register)
+		id.POST("/login", routes.Login)
+		id.POST("/refresh", routes.Refresh)
+		id.POST("/logout", middleware.RequireAuth(), routes.Logout)
+		id.POST("/logout/all", middleware.RequireAuth(), routes.LogoutAll)
+		id.POST("/password/forgot", routes.ForgotPassword)
+		id.POST("/password/reset", routes.ResetPassword)
+		id.GET("/verify", routes.VerifyEmail)
+		id.POST("/verify/resend", middleware.RequireAuth(), routes.ResendVerification)
+		id.GET("/profile", middleware.RequireAuth(), routes.Profile)
+		id.PUT("/profile", middleware.RequireAuth(), routes.UpdateProfile)
+	}
+
+	collections := r.Group("/collections", middleware.RequireAuth())
+	{
+		collections.POST("/", routes.CreateCollection)
+		collections.PUT("/", routes.UpdateCollection)
+		collections.GET("/u/:uid", routes.GetUserCollections)
+		collections.GET("/:id", routes.GetCollection)
+		collections.POST("/query", routes.QueryCollections)
+		collections.GET("/autocomplete", routes.AutocompleteCollections)
+		collections.DELETE("/:id", routes.DeleteCollection)
+		collections.POST("/level", routes.LinkLevel)
+		collections.DELETE("/level", routes.UnlinkLevel)
+		collections.GET("/levels/:id", routes.GetCollectionLevels)
+		collections.GET("/trending", routes.TrendingCollections)
+		collections.GET("/:id/comments", routes.GetCollectionComments)
+	}
+
+	drafts := r.Group("/drafts", middleware.RequireAuth())
+	{
+		drafts.POST("/", routes.CreateDraft)
+		drafts.PUT("/", routes.UpdateDraft)
+		drafts.GET("/:id", routes.GetDraft)
+		drafts.PATCH("/:id", routes.PatchDraft)
+		drafts.GET("/:id/checkpoints", routes.GetDraftCheckpoints)
+		drafts.POST("/:id/checkpoints", routes.CreateCheckpoint)
+		drafts.GET("/:id/checkpoints/:checkpoint", routes.GetDraftCheckpoint)
+		drafts.POST("/:id/checkpoints/:checkpoint/restore", routes.RestoreCheckpoint)
+		drafts.DELETE("/:id/checkpoints/:checkpoint", routes.DeleteCheckpoint)
+		drafts.GET("/level/:id", routes.GetLevelDraft)
+		drafts.GET("/u", routes.GetUserDrafts)
+		drafts.DELETE("/:id", routes.DeleteDraft)
+	}
+
+	levels := r.Group("/levels", middleware.RequireAuth())
+	{
+		levels.POST("/fromDraft", middleware.RequireVerified(), routes.CreateLevelFromDraft)
+		levels.POST("/", middleware.RequireVerified(), routes.CreateLevel)
+		levels.GET("/info/:id", routes.GetLevelInfo)
+		levels.GET("/:id", routes.GetLevel)
+		levels.GET("/shared/:token", routes.GetSharedLevel)
+		levels.POST("/:id/share", routes.ResetShareLink)
+		levels.GET("/:id/versions", routes.GetLevelVersions)
+		levels.GET("/:id/versions/:version", routes.GetLevelVersion)
+		levels.POST("/:id/versions/:version/rollback", routes.RollbackLevel)
+		levels.GET("/:id/diff", routes.DiffLevelVersions)
+		levels.GET("/:id/remixes", routes.GetLevelRemixes)
+		levels.GET("/:id/lineage", routes.GetLevelLineage)
+		levels.PUT("/fromDraft", routes.UpdateLevelFromDraft)
+		levels.PUT("/", routes.UpdateLevel)
+		levels.DELETE("/:id", routes.DeleteLevel)
+		levels.POST("/query", routes.QueryLevels)
+		levels.GET("/autocomplete", routes.AutocompleteLevels)
+		levels.GET("/trending", routes.TrendingLevels)
+		levels.GET("/leaderboard/:id", routes.Leaderboard)
+		levels.GET("/u/:uid", routes.GetUserLevels)
+		levels.GET("/:id/vote", routes.GetLevelVote)
+		levels.PUT("/:id/vote", routes.VoteLevel)
+		levels.DELETE("/:id/vote", routes.DeleteLevelVote)
+		levels.GET("/:id/comments", routes.GetLevelComments)
+		levels.GET("/:id/difficulty", routes.GetDifficultyVote)
+		levels.PUT("/:id/difficulty", routes.VoteDifficulty)
+		levels.DELETE("/:id/difficulty", routes.DeleteDifficultyVote)
+		levels.PUT("/:id/tags", routes.SetLevelTags)
+		levels.GET("/tag/:tag", routes.GetTagLevels)
+	}
+
+	tags := r.Group("/tags", middleware.RequireAuth())
+	{
+		tags.GET("/autocomplete", routes.AutocompleteTags)
+		tags.GET("/popular", routes.PopularTags)
+		tags.PUT("/:tag/curated", middleware.RequireRole(models.RoleModerator, models.RoleAdmin), routes.SetTagCurated)
+	}
+
+	comments := r.Group("/comments", middleware.RequireAuth())
+	{
+		comments.POST("/", middleware.RequireVerified(), routes.CreateComment)
+		comments.POST("/query", routes.QueryComments)
+		comments.GET("/:id", routes.GetComment)
+		comments.GET("/:id/replies", routes.GetCommentReplies)
+		comments.PUT("/:id", routes.UpdateComment)
+		comments.DELETE("/:id", routes.DeleteComment)
+	}
+
+	users := r.Group("/u", middleware.RequireAuth())
+	{
+		users.GET("/:id", routes.GetUser)
+		users.POST("/query", routes.QueryUsers)
+		users.GET("/autocomplete", routes.AutocompleteUsers)
+	}
+
+	transport := r.Group("/t", middleware.RequireAuth())
+	{
+		transport.POST("/", routes.PostEvent)
+	}
+
+	stats := r.Group("/stats", middleware.RequireAuth())
+	{
+		// Post must be used so the API is compatible with
+		// js fetch
+		stats.POST("/:id/gameStarts", routes.GetLevelStarts)
+		stats.POST("/:id/completes", routes.GetLevelCompletes)
+		stats.POST("/:id/avgTime", routes.GetAvgTime)
+		stats.POST("/:id/uniqueUsers", routes.GetUniqueUsers)
+	}
+
+	// The first admin has to be set directly
+	// in the database
+	admin := r.Group("/admin", middleware.RequireAuth(), middleware.RequireRole(models.RoleAdmin))
+	{
+		admin.PUT("/users/:id/role", routes.SetUserRole)
+	}
+
+	return r
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+func TestSearchSyntax(t *testing.T) {
+	owner := newUser(t)
+	canyon := newLevel(t, owner, "Xochimilco canyon")
+	desert := newLevel(t, owner, "Xochimilco desert")
+
+	var res struct {
+		Results []models.Level `json:"results"`
+	}
+	search := func(query string) []models.Level {
+		t.Helper()
+		body := gin.H{"query": query, "creator": owner.Id}
+		expect(t, 200, "POST", "/levels/query", owner.Token, body).decode(t, &res)
+		return res.Results
+	}
+	if l := search("xochimilco -desert"); len(l) != 1 || l[0].Id != canyon {
+		t.Fatalf("expected only the canyon, got %+v", l)
+	}
+	if l := search("canyon or desert"); !containsLevel(l, canyon) || !containsLevel(l, desert) {
+		t.Fatalf("expected either level, got %+v", l)
+	}
+	// Operators of to_tsquery are plain text
+	for _, q := range []string{"xochimilco & | !", "\"xochimilco canyon", "canyon:*", "(desert"} {
+		search(q)
+	}
+	// Misspelled words fall back to similar names, even
+	// when the only exact matches are filtered out
+	newLevel(t, newUser(t), "Xochimilko lagoon")
+	if l := search("xochimilko"); !containsLevel(l, canyon) || !containsLevel(l, desert) {
+		t.Fatalf("expected the misspelled query to find both levels, got %+v", l)
+	}
+}
+
+func TestAutocomplete(t *testing.T) {
+	u := newUser(t)
+	expect(t, 200, "PUT", "/id/profile", u.Token, gin.H{"name": "Tlaloc Racer"})
+	level := newLevel(t, u, "Popocatepetl climb")
+	var created struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/collections/", u.Token, gin.H{"name": "Iztaccihuatl tracks", "description": "Snowy"}).decode(t, &created)
+
+	var res struct {
+		Suggestions []models.Suggestion `json:"suggestions"`
+	}
+	suggests := func(path string, id int) bool {
+		t.Helper()
+		expect(t, 200, "GET", path, u.Token, nil).decode(t, &res)
+		for _, s := range res.Suggestions {
+			if s.Id == id {
+				return true
+			}
+		}
+		return false
+	}
+	cases := []struct {
+		path string
+		id   int
+	}{
+		{"/levels/autocomplete?q=popoca", level},
+		{"/levels/autocomplete?q=cli", level},
+		{"/levels/autocomplete?q=popocatepelt", level},
+		{"/u/autocomplete?q=tlal", u.Id},
+		{"/u/autocomplete?q=racer%20tla", u.Id},
+		{"/collections/autocomplete?q=iztac", created.Id},
+	}
+	for _, c := range cases {
+		if !suggests(c.path, c.id) {
+			t.Fatalf("expected %v to suggest %d, got %+v", c.path, c.id, res.Suggestions)
+		}
+	}
+	// Descriptions aren't completed
+	if suggests("/collections/autocomplete?q=snowy", created.Id) {
+		t.Fatalf("expected only names to be completed, got %+v", res.Suggestions)
+	}
+	expect(t, 200, "GET", "/levels/autocomplete?q=popoca&limit=1", u.Token, nil).decode(t, &res)
+	if len(res.Suggestions) != 1 {
+		t.Fatalf("expected one suggestion, got %+v", res.Suggestions)
+	}
+	expect(t, 200, "GET", "/levels/autocomplete", u.Token, nil).decode(t, &res)
+	if len(res.Suggestions) != 0 {
+		t.Fatalf("expected no suggestions without a prefix, got %+v", res.Suggestions)
+	}
+	expect(t, 400, "GET", "/levels/autocomplete?q=popoca&limit=0", u.Token, nil)
+}
+
+func TestSearchLanguage(t *testing.T) {
+	u := newUser(t)
+	var profile struct {
+		User models.UserData `json:"user"`
+	}
+	expect(t, 200, "GET", "/id/profile", u.Token, nil).decode(t, &profile)
+	if profile.User.Language != models.DefaultLanguage {
+		t.Fatalf("expected the default language, got %q", profile.User.Language)
+	}
+	register := gin.H{"name": "Jean", "email": "jean@example.com", "password": "hunter22", "language": "fr"}
+	expect(t, 400, "POST", "/id/register", "", register)
+	expect(t, 400, "PUT", "/id/profile", u.Token, gin.H{"language": "fr"})
+	expect(t, 200, "PUT", "/id/profile", u.Token, gin.H{"language": "pt"})
+	expect(t, 200, "GET", "/id/profile", u.Token, nil).decode(t, &profile)
+	if profile.User.Language != models.LanguagePortuguese {
+		t.Fatalf("expected the profile language to be updated, got %q", profile.User.Language)
+	}
+
+	// Content defaults to the language of its creator
+	var info struct {
+		Level models.Level `json:"level"`
+	}
+	id := newLevel(t, u, "Corrida noturna")
+	path := fmt.Sprintf("/levels/info/%d", id)
+	expect(t, 200, "GET", path, u.Token, nil).decode(t, &info)
+	if info.Level.Language != models.LanguagePortuguese {
+		t.Fatalf("expected the creator's language, got %q", info.Level.Language)
+	}
+	expect(t, 200, "PUT", "/levels/", u.Token, gin.H{"id": id, "language": "en", "revision": 1})
+	expect(t, 200, "GET", path, u.Token, nil).decode(t, &info)
+	if info.Level.Language != models.LanguageEnglish {
+		t.Fatalf("expected the level language to be updated, got %q", info.Level.Language)
+	}
+	body := levelBody("Langue")
+	body["language"] = "fr"
+	expect(t, 400, "POST", "/levels/", u.Token, body)
+
+	var created struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/collections/", u.Token, gin.H{"name": "Night races", "language": "en"}).decode(t, &created)
+	var collection struct {
+		Collection models.CollectionData `json:"collection"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/collections/%d", created.Id), u.Token, nil).decode(t, &collection)
+	if collection.Collection.Language != models.LanguageEnglish {
+		t.Fatalf("expected the collection language, got %q", collection.Collection.Language)
+	}
+
+	var res struct {
+		Results []models.Level `json:"results"`
+	}
+	query := gin.H{"query": "corrida", "language": "pt", "creator": u.Id}
+	expect(t, 200, "POST", "/levels/query", u.Token, query).decode(t, &res)
+	if !containsLevel(res.Results, id) {
+		t.Fatalf("expected the level, got %+v", res.Results)
+	}
+	for _, path := range []string{"/levels/query", "/u/query", "/collections/query", "/comments/query"} {
+		expect(t, 200, "POST", path, u.Token, gin.H{"query": "night", "language": "en"})
+		expect(t, 400, "POST", path, u.Token, gin.H{"query": "night", "language": "fr"})
+	}
+}
@@ -0,0 +1,95 @@
+// Package server builds the API and runs it behind an
+// http.Server, so it can be embedded by entry points
+// other than main (tests, admin tools).
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sofferjacob/maker_api/conf"
+	"github.com/sofferjacob/maker_api/mail"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type Config struct {
+	Port string
+	// Max time to read a request, including the body
+	ReadTimeout time.Duration
+	// Max time to write a response
+	WriteTimeout time.Duration
+	// Max time to keep idle connections open
+	IdleTimeout time.Duration
+	// Max time in-flight requests get to finish
+	// once the server is asked to stop
+	ShutdownTimeout time.Duration
+}
+
+// Reads the server config from the environment
+func LoadConfig() Config {
+	return Config{
+		Port:            os.Getenv("PORT"),
+		ReadTimeout:     conf.Duration("READ_TIMEOUT", 15*time.Second),
+		WriteTimeout:    conf.Duration("WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:     conf.Duration("IDLE_TIMEOUT", 2*time.Minute),
+		ShutdownTimeout: conf.Duration("SHUTDOWN_TIMEOUT", 20*time.Second),
+	}
+}
+
+// The services the API depends on. Nil
+// fields keep the current default (Postgres
+// and the mailer chosen by mail.Setup).
+type Deps struct {
+	Store  models.Store
+	Mailer mail.Mailer
+}
+
+// Sets up deps and returns the API handler
+func New(cfg Config, deps Deps) http.Handler {
+	if deps.Store != nil {
+		models.SetStore(deps.Store)
+	}
+	if deps.Mailer != nil {
+		mail.Sender = deps.Mailer
+	}
+	return newRouter()
+}
+
+// Serves h on cfg.Port until ctx is done, then
+// waits for in-flight requests to finish.
+func Serve(ctx context.Context, cfg Config, h http.Handler) error {
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%v", cfg.Port),
+		Handler:      h,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("could not shut down gracefully: %v", err.Error())
+	}
+	return nil
+}
+
+// Like Serve, stopping on SIGINT or SIGTERM
+func Run(cfg Config, h http.Handler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return Serve(ctx, cfg, h)
+}
@@ -0,0 +1,300 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/db"
+	"github.com/sofferjacob/maker_api/mail"
+	"github.com/sofferjacob/maker_api/migrations"
+	"github.com/sofferjacob/maker_api/models"
+	"github.com/sofferjacob/maker_api/models/memory"
+)
+
+// The end-to-end tests run requests through the handler
+// returned by New. They use the in-memory store unless
+// TEST_DATABASE_URL is set, in which case that database is
+// migrated from scratch and used instead (don't point it at
+// a database you want to keep).
+
+var (
+	router  http.Handler
+	mailbox = &syncBuffer{}
+	userSeq int64
+)
+
+// Holds the messages sent by the mailer
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	os.Setenv("AUTH_KEY", "test-auth-key")
+	os.Setenv("API_URL", "http://api.test")
+	os.Setenv("APP_URL", "http://app.test")
+	os.Setenv("REQUIRE_VERIFIED_EMAIL", "false")
+	deps := Deps{Mailer: mail.NewLogMailer(mailbox)}
+	if dsn := os.Getenv("TEST_DATABASE_URL"); dsn != "" {
+		os.Setenv("DATABASE_URL", dsn)
+		db.Client.Connect()
+		if _, err := db.Client.MigrateDown(migrations.FS, 1<<30); err != nil {
+			fmt.Printf("❌ Error: could not reset test database: %v\n", err.Error())
+			os.Exit(2)
+		}
+		if _, err := db.Client.MigrateUp(migrations.FS); err != nil {
+			fmt.Printf("❌ Error: could not migrate test database: %v\n", err.Error())
+			os.Exit(2)
+		}
+	} else {
+		deps.Store = memory.New()
+	}
+	router = New(Config{}, deps)
+	code := m.Run()
+	if db.Client.Client != nil {
+		db.Client.Close()
+	}
+	os.Exit(code)
+}
+
+type response struct {
+	Code   int
+	Body   []byte
+	Header http.Header
+}
+
+// Decodes the response body into v
+func (r response) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("could not decode response %s: %v", r.Body, err)
+	}
+}
+
+// Sends a request with an optional bearer token and JSON body
+func call(t *testing.T, method, path, token string, body interface{}) response {
+	t.Helper()
+	return callWithHeaders(t, method, path, token, nil, body)
+}
+
+// Like call, with extra request headers
+func callWithHeaders(t *testing.T, method, path, token string, headers map[string]string, body interface{}) response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("could not encode body: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return response{w.Code, w.Body.Bytes(), w.Header()}
+}
+
+// Like call, failing the test if the status isn't code
+func expect(t *testing.T, code int, method, path, token string, body interface{}) response {
+	t.Helper()
+	res := call(t, method, path, token, body)
+	if res.Code != code {
+		t.Fatalf("%v %v: expected %d, got %d: %s", method, path, code, res.Code, res.Body)
+	}
+	return res
+}
+
+type testUser struct {
+	Id           int
+	Name         string
+	Email        string
+	Password     string
+	Token        string
+	RefreshToken string
+}
+
+// Registers a user with a unique email and logs in
+func newUser(t *testing.T) testUser {
+	t.Helper()
+	n := atomic.AddInt64(&userSeq, 1)
+	u := testUser{
+		Name:     fmt.Sprintf("Player %d", n),
+		Email:    fmt.Sprintf("player%d-%d@example.com", n, time.Now().UnixNano()),
+		Password: "hunter22",
+	}
+	expect(t, 200, "POST", "/id/register", "", gin.H{"name": u.Name, "email": u.Email, "password": u.Password})
+	u.login(t)
+	return u
+}
+
+func (u *testUser) login(t *testing.T) {
+	t.Helper()
+	var body struct {
+		Token        string          `json:"token"`
+		RefreshToken string          `json:"refreshToken"`
+		User         models.UserData `json:"user"`
+	}
+	expect(t, 200, "POST", "/id/login", "", gin.H{"email": u.Email, "password": u.Password}).decode(t, &body)
+	u.Id = body.User.Id
+	u.Token = body.Token
+	u.RefreshToken = body.RefreshToken
+}
+
+// Gives the user a role and logs in again
+// so the token carries it
+func (u *testUser) setRole(t *testing.T, role string) {
+	t.Helper()
+	if err := models.SetUserRole(u.Id, role); err != nil {
+		t.Fatalf("could not set role: %v", err)
+	}
+	u.login(t)
+}
+
+var tokenRe = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// Returns the token of the last link mailed to email
+func mailedToken(t *testing.T, email string) string {
+	t.Helper()
+	msgs := strings.Split(mailbox.String(), "📧 ")
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if !strings.Contains(msgs[i], "To: "+email+"\n") {
+			continue
+		}
+		if m := tokenRe.FindStringSubmatch(msgs[i]); m != nil {
+			return m[1]
+		}
+	}
+	t.Fatalf("no token mailed to %v", email)
+	return ""
+}
+
+// The current revision of the level, read by u
+func levelRevision(t *testing.T, u testUser, id int) int {
+	t.Helper()
+	var body struct {
+		Level models.Level `json:"level"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/info/%d", id), u.Token, nil).decode(t, &body)
+	return body.Level.Revision
+}
+
+// The current revision of u's draft
+func draftRevision(t *testing.T, u testUser, id int) int {
+	t.Helper()
+	var body struct {
+		Draft models.Draft `json:"draft"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/drafts/%d", id), u.Token, nil).decode(t, &body)
+	return body.Draft.Revision
+}
+
+// Creates a level owned by u and returns its id
+func newLevel(t *testing.T, u testUser, name string) int {
+	t.Helper()
+	var body struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/levels/", u.Token, levelBody(name)).decode(t, &body)
+	return body.Id
+}
+
+func levelBody(name string) gin.H {
+	return gin.H{
+		"name":        name,
+		"description": "A level for the tests",
+		"difficulty":  2,
+		"theme":       1,
+		"car":         1,
+		"soundtrack":  1,
+		"courseData":  gin.H{"blocks": []gin.H{{"x": 0, "y": 0}}},
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(204)
+	})
+	cfg := Config{
+		Port:            strconv.Itoa(l.Addr().(*net.TCPAddr).Port),
+		ReadTimeout:     time.Second,
+		WriteTimeout:    time.Second,
+		ShutdownTimeout: 5 * time.Second,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, cfg, slow) }()
+	waitForListener(t, addr)
+
+	// Stop the server while a slow request is in flight
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	res, err := http.Get("http://" + addr)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != 204 {
+		t.Fatalf("expected the in-flight request to finish, got %d", res.StatusCode)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("expected a clean shutdown, got %v", err)
+	}
+}
+
+func waitForListener(t *testing.T, addr string) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("server never listened on %v", addr)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+	"github.com/sofferjacob/maker_api/tracking"
+)
+
+func TestEventsAndStats(t *testing.T) {
+	owner := newUser(t)
+	players := []testUser{newUser(t), newUser(t)}
+	id := newLevel(t, owner, "Tracked")
+
+	expect(t, 400, "POST", "/t/", owner.Token, gin.H{"levelId": id})
+	for i, p := range players {
+		expect(t, 200, "POST", "/t/", p.Token, gin.H{"eventType": "game_start", "levelId": id})
+		expect(t, 200, "POST", "/t/", p.Token, gin.H{"eventType": "game_finish", "levelId": id, "time": 1000 * (i + 1)})
+	}
+
+	stats := fmt.Sprintf("/stats/%d", id)
+	var starts struct {
+		Result []tracking.LevelStartsResult `json:"result"`
+	}
+	expect(t, 200, "POST", stats+"/gameStarts", owner.Token, gin.H{}).decode(t, &starts)
+	if len(starts.Result) != 1 || starts.Result[0].GameStarts != 2 {
+		t.Fatalf("expected 2 game starts, got %+v", starts.Result)
+	}
+	var completes struct {
+		Result []tracking.LevelCompleteResult `json:"result"`
+	}
+	expect(t, 200, "POST", stats+"/completes", owner.Token, gin.H{}).decode(t, &completes)
+	if len(completes.Result) != 1 || completes.Result[0].GameComplete != 2 {
+		t.Fatalf("expected 2 completes, got %+v", completes.Result)
+	}
+	var avg struct {
+		Result []tracking.AvgTimeResult `json:"result"`
+	}
+	expect(t, 200, "POST", stats+"/avgTime", owner.Token, gin.H{}).decode(t, &avg)
+	if len(avg.Result) != 1 || avg.Result[0].AvgTime != 1500 {
+		t.Fatalf("expected an average time of 1500, got %+v", avg.Result)
+	}
+	var unique struct {
+		Result []tracking.UniqueUsersResult `json:"result"`
+	}
+	expect(t, 200, "POST", stats+"/uniqueUsers", owner.Token, gin.H{}).decode(t, &unique)
+	if len(unique.Result) != 1 || unique.Result[0].UniqueUsers != 2 {
+		t.Fatalf("expected 2 unique users, got %+v", unique.Result)
+	}
+	expect(t, 200, "POST", stats+"/gameStarts", owner.Token, gin.H{"gt": 3}).decode(t, &starts)
+	if len(starts.Result) != 0 {
+		t.Fatalf("expected gt to filter out every day, got %+v", starts.Result)
+	}
+	expect(t, 400, "POST", "/stats/abc/gameStarts", owner.Token, gin.H{})
+
+	var board struct {
+		Result []models.Leaderboard `json:"result"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/leaderboard/%d", id), owner.Token, nil).decode(t, &board)
+	if len(board.Result) != 2 || board.Result[0].Uid != players[0].Id || board.Result[0].Time != 1000 {
+		t.Fatalf("unexpected leaderboard %+v", board.Result)
+	}
+}
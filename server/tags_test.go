@@ -0,0 +1,108 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+type tagList struct {
+	Tags []models.Tag `json:"tags"`
+}
+
+func TestLevelTags(t *testing.T) {
+	owner := newUser(t)
+	other := newUser(t)
+
+	body := levelBody("Frozen lake")
+	body["tags"] = []string{"Ice Physics", "winter", "ice-physics"}
+	var res struct {
+		Id int `json:"id"`
+	}
+	expect(t, 200, "POST", "/levels/", owner.Token, body).decode(t, &res)
+	frozen := res.Id
+	body = levelBody("Bad tags")
+	body["tags"] = []string{"no;sql"}
+	expect(t, 400, "POST", "/levels/", owner.Token, body)
+
+	var info struct {
+		Level models.Level `json:"level"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/levels/info/%d", frozen), other.Token, nil).decode(t, &info)
+	if fmt.Sprint(info.Level.Tags) != "[ice-physics winter]" {
+		t.Fatalf("expected normalized tags, got %v", info.Level.Tags)
+	}
+
+	icy := newLevel(t, other, "Icy highway")
+	path := fmt.Sprintf("/levels/%d/tags", icy)
+	expect(t, 403, "PUT", path, owner.Token, gin.H{"tags": []string{"ice-physics"}})
+	expect(t, 400, "PUT", path, other.Token, gin.H{"tags": []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"}})
+	expect(t, 200, "PUT", path, other.Token, gin.H{"tags": []string{"ice-physics", "highway"}})
+
+	var levels struct {
+		Levels  []models.Level `json:"levels"`
+		Results []models.Level `json:"results"`
+	}
+	expect(t, 200, "GET", "/levels/tag/ICE-physics", other.Token, nil).decode(t, &levels)
+	if len(levels.Levels) != 2 || levels.Levels[0].Id != icy || levels.Levels[1].Id != frozen {
+		t.Fatalf("expected both tagged levels, newest first, got %+v", levels.Levels)
+	}
+
+	query := gin.H{"query": "lake", "tags": []string{"winter", "ice-physics"}}
+	expect(t, 200, "POST", "/levels/query", other.Token, query).decode(t, &levels)
+	if !containsLevel(levels.Results, frozen) {
+		t.Fatalf("expected the query to find the tagged level, got %+v", levels.Results)
+	}
+	query["tags"] = []string{"winter", "highway"}
+	expect(t, 200, "POST", "/levels/query", other.Token, query).decode(t, &levels)
+	if len(levels.Results) != 0 {
+		t.Fatalf("expected levels to need every tag, got %+v", levels.Results)
+	}
+
+	var tags tagList
+	expect(t, 200, "GET", "/tags/autocomplete?q=Ic", other.Token, nil).decode(t, &tags)
+	if len(tags.Tags) == 0 || tags.Tags[0].Name != "ice-physics" || tags.Tags[0].Levels != 2 {
+		t.Fatalf("unexpected suggestions %+v", tags.Tags)
+	}
+	expect(t, 400, "GET", "/tags/autocomplete?q=ice&limit=500", other.Token, nil)
+
+	// Hidden levels don't count
+	expect(t, 200, "PUT", "/levels/", other.Token, gin.H{"id": icy, "visibility": "private", "revision": levelRevision(t, other, icy)})
+	expect(t, 200, "GET", "/tags/autocomplete?q=Ic", other.Token, nil).decode(t, &tags)
+	if len(tags.Tags) == 0 || tags.Tags[0].Name != "ice-physics" || tags.Tags[0].Levels != 1 {
+		t.Fatalf("expected only the public level to count, got %+v", tags.Tags)
+	}
+
+	// Untagging removes the level from the tag
+	expect(t, 200, "PUT", path, other.Token, gin.H{"tags": []string{}})
+	expect(t, 200, "GET", "/levels/tag/highway", other.Token, nil).decode(t, &levels)
+	if len(levels.Levels) != 0 {
+		t.Fatalf("expected no levels, got %+v", levels.Levels)
+	}
+}
+
+func TestCuratedTags(t *testing.T) {
+	user := newUser(t)
+	mod := newUser(t)
+	mod.setRole(t, models.RoleModerator)
+
+	expect(t, 403, "PUT", "/tags/speedrun/curated", user.Token, gin.H{"curated": true})
+	expect(t, 400, "PUT", "/tags/speedrun/curated", mod.Token, gin.H{})
+	expect(t, 200, "PUT", "/tags/Speed%20Run/curated", mod.Token, gin.H{"curated": true})
+
+	var tags tagList
+	expect(t, 200, "GET", "/tags/popular?curated=true", user.Token, nil).decode(t, &tags)
+	found := false
+	for _, tag := range tags.Tags {
+		found = found || tag.Name == "speed-run"
+		if !tag.Curated {
+			t.Fatalf("expected only curated tags, got %+v", tag)
+		}
+	}
+	if !found {
+		t.Fatalf("expected the curated tag without levels to be listed, got %+v", tags.Tags)
+	}
+	expect(t, 200, "GET", "/tags/popular", user.Token, nil)
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+func TestUsers(t *testing.T) {
+	u := newUser(t)
+	other := newUser(t)
+	var body struct {
+		User models.UserData `json:"user"`
+	}
+	expect(t, 200, "GET", fmt.Sprintf("/u/%d", u.Id), other.Token, nil).decode(t, &body)
+	if body.User.Id != u.Id || body.User.Name != u.Name {
+		t.Fatalf("unexpected user %+v", body.User)
+	}
+	expect(t, 500, "GET", "/u/abc", other.Token, nil)
+	expect(t, 500, "GET", "/u/999999", other.Token, nil)
+
+	expect(t, 200, "PUT", "/id/profile", u.Token, gin.H{"name": "Speedrunner"})
+	var results struct {
+		Results []models.UserData `json:"results"`
+	}
+	expect(t, 200, "POST", "/u/query", other.Token, gin.H{"query": "speedrunner"}).decode(t, &results)
+	found := false
+	for _, r := range results.Results {
+		found = found || r.Id == u.Id
+	}
+	if !found {
+		t.Fatalf("expected query to find user %d, got %+v", u.Id, results.Results)
+	}
+	expect(t, 400, "POST", "/u/query", other.Token, gin.H{})
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sofferjacob/maker_api/models"
+)
+
+func TestLevelVersions(t *testing.T) {
+	owner := newUser(t)
+	other := newUser(t)
+	id := newLevel(t, owner, "Versioned")
+	path := fmt.Sprintf("/levels/%d", id)
+	edited := gin.H{"blocks": []gin.H{{"x": 0, "y": 0}, {"x": 1, "y": 0}}, "spawn": 2}
+	expect(t, 200, "PUT", "/levels/", owner.Token, gin.H{"id": id, "courseData": edited, "revision": 1})
+	// Only new course data is a new version
+	expect(t, 200, "PUT", "/levels/", owner.Token, gin.H{"id": id, "name": "Versioned twice", "revision": 2})
+
+	var list struct {
+		Versions   []models.LevelVersion `json:"versions"`
+		NextCursor string                `json:"nextCursor"`
+	}
+	expect(t, 200, "GET", path+"/versions", other.Token, nil).decode(t, &list)
+	if len(list.Versions) != 2 || list.Versions[0].Version != 2 || list.Versions[1].Version != 1 {
+		t.Fatalf("expected versions 2 and 1, got %+v", list.Versions)
+	}
+	expect(t, 200, "GET", path+"/versions?limit=1", other.Token, nil).decode(t, &list)
+	if len(list.Versions) != 1 || list.NextCursor == "" {
+		t.Fatalf("expected a page of one version, got %+v", list)
+	}
+
+	var body struct {
+		Version models.LevelVersion `json:"version"`
+	}
+	expect(t, 200, "GET", path+"/versions/1", other.Token, nil).decode(t, &body)
+	first := body.Version.CourseData
+	if len(first["blocks"].([]interface{})) != 1 {
+		t.Fatalf("unexpected first version %+v", body.Version)
+	}
+	expect(t, 404, "GET", path+"/versions/9", other.Token, nil)
+	expect(t, 400, "GET", path+"/versions/0", other.Token, nil)
+
+	var diff struct {
+		Changes []models.Change `json:"changes"`
+	}
+	expect(t, 200, "GET", path+"/diff?from=1&to=2", other.Token, nil).decode(t, &diff)
+	ops := map[string]string{}
+	for _, c := range diff.Changes {
+		ops[c.Path] = c.Op
+	}
+	if len(diff.Changes) != 2 || ops["/blocks/1"] != "add" || ops["/spawn"] != "add" {
+		t.Fatalf("unexpected diff %+v", diff.Changes)
+	}
+	expect(t, 200, "GET", path+"/diff?from=2&to=2", other.Token, nil).decode(t, &diff)
+	if len(diff.Changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", diff.Changes)
+	}
+	expect(t, 400, "GET", path+"/diff?from=1", other.Token, nil)
+	expect(t, 404, "GET", path+"/diff?from=1&to=9", other.Token, nil)
+
+	expect(t, 403, "POST", path+"/versions/1/rollback", other.Token, nil)
+	match := map[string]string{"If-Match": fmt.Sprintf(`"%d"`, levelRevision(t, owner, id))}
+	if res := callWithHeaders(t, "POST", path+"/versions/9/rollback", owner.Token, match, nil); res.Code != 404 {
+		t.Fatalf("expected a rollback to a missing version to fail, got %d", res.Code)
+	}
+	expect(t, 428, "POST", path+"/versions/1/rollback", owner.Token, nil)
+	stale := map[string]string{"If-Match": `"1"`}
+	if res := callWithHeaders(t, "POST", path+"/versions/1/rollback", owner.Token, stale, nil); res.Code != 409 {
+		t.Fatalf("expected a rollback of a stale revision to conflict, got %d", res.Code)
+	}
+	var rollback struct {
+		Version  int `json:"version"`
+		Revision int `json:"revision"`
+	}
+	res := callWithHeaders(t, "POST", path+"/versions/1/rollback", owner.Token, match, nil)
+	if res.Code != 200 {
+		t.Fatalf("expected the rollback to succeed, got %d: %s", res.Code, res.Body)
+	}
+	res.decode(t, &rollback)
+	if rollback.Version != 3 || rollback.Revision != levelRevision(t, owner, id) || res.Header.Get("ETag") != fmt.Sprintf(`"%d"`, rollback.Revision) {
+		t.Fatalf("expected rollback to publish version 3 at the new revision, got %+v", rollback)
+	}
+	var level struct {
+		Level models.Level `json:"level"`
+	}
+	expect(t, 200, "GET", path, other.Token, nil).decode(t, &level)
+	if !reflect.DeepEqual(level.Level.CourseData, first) {
+		t.Fatalf("expected the course of version 1, got %+v", level.Level.CourseData)
+	}
+	expect(t, 200, "GET", path+"/versions/3", other.Token, nil).decode(t, &body)
+	if body.Version.RestoredFrom == nil || *body.Version.RestoredFrom != 1 {
+		t.Fatalf("expected version 3 to restore version 1, got %+v", body.Version)
+	}
+
+	expect(t, 200, "PUT", "/levels/", owner.Token, gin.H{"id": id, "visibility": "private", "revision": levelRevision(t, owner, id)})
+	expect(t, 404, "GET", path+"/versions", other.Token, nil)
+	expect(t, 404, "GET", path+"/diff?from=1&to=3", other.Token, nil)
+	expect(t, 200, "GET", path+"/versions", owner.Token, nil)
+
+	// A change to null keeps its value, as JSON Patch requires
+	cleared := gin.H{"blocks": first["blocks"], "spawn": nil}
+	expect(t, 200, "PUT", "/levels/", owner.Token, gin.H{"id": id, "courseData": cleared, "revision": levelRevision(t, owner, id)})
+	res = expect(t, 200, "GET", path+"/diff?from=3&to=4", owner.Token, nil)
+	if !strings.Contains(string(res.Body), `{"op":"add","path":"/spawn","value":null}`) {
+		t.Fatalf("expected an add op with a null value, got %s", res.Body)
+	}
+}
@@ -0,0 +1,90 @@
+package tracking
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sofferjacob/maker_api/db"
+)
+
+// Stores events in the events table. Q may be a
+// transaction, db.Client is used if it is nil.
+type PgRepository struct {
+	Q db.Queryer
+}
+
+func (r PgRepository) q() db.Queryer {
+	if r.Q == nil {
+		return db.Client.Client
+	}
+	return r.Q
+}
+
+func (r PgRepository) Insert(e *Event) error {
+	qb := db.Insert("events").Set("event_type", e.EventType)
+	if e.LevelId != 0 {
+		qb = qb.Set("level_id", e.LevelId)
+	}
+	if e.Uid != 0 {
+		qb = qb.Set("uid", e.Uid)
+	}
+	if e.Time != 0 {
+		qb = qb.Set("time", e.Time)
+	}
+	if e.DraftId != 0 {
+		qb = qb.Set("draft_id", e.DraftId)
+	}
+	if e.Body != nil || len(e.Body) > 0 {
+		body, err := json.Marshal(e.Body)
+		if err != nil {
+			return fmt.Errorf("could not parse body: %v", err.Error())
+		}
+		qb = qb.Set("body", body)
+	}
+	if e.State != "" {
+		qb = qb.Set("state", e.State)
+	}
+	query, args := qb.Query()
+	_, err := r.q().Exec(query, args...)
+	return err
+}
+
+func statsQuery(sel, eventType, group string, f StatsFilter) (string, []interface{}) {
+	qb := db.SelectFrom("events").SelectExpr(sel).Where("event_type", "=", eventType).
+		And("level_id", "=", f.LevelId)
+	if !f.From.IsZero() {
+		qb = qb.And("timestamp", ">=", f.From)
+	}
+	if !f.To.IsZero() {
+		qb = qb.And("timestamp", "<=", f.To)
+	}
+	return qb.GroupByExpr(group).Query()
+}
+
+func (r PgRepository) LevelStarts(f StatsFilter) ([]LevelStartsResult, error) {
+	query, args := statsQuery("COUNT(*) game_starts, date(timestamp)", "game_start", "date(timestamp)", f)
+	res := []LevelStartsResult{}
+	err := r.q().Select(&res, query, args...)
+	return res, err
+}
+
+func (r PgRepository) LevelCompletes(f StatsFilter) ([]LevelCompleteResult, error) {
+	query, args := statsQuery("COUNT(*) game_complete, date(timestamp)", "game_finish", "date(timestamp)", f)
+	res := []LevelCompleteResult{}
+	err := r.q().Select(&res, query, args...)
+	return res, err
+}
+
+func (r PgRepository) AvgTime(f StatsFilter) ([]AvgTimeResult, error) {
+	query, args := statsQuery("AVG(time) avg_time, date(timestamp)", "game_finish", "date(timestamp)", f)
+	res := []AvgTimeResult{}
+	err := r.q().Select(&res, query, args...)
+	return res, err
+}
+
+func (r PgRepository) UniqueUsers(f StatsFilter) ([]UniqueUsersResult, error) {
+	query, args := statsQuery("COUNT(DISTINCT uid) unique_users, EXTRACT(MONTH FROM timestamp) AS month", "game_finish", "EXTRACT(MONTH FROM timestamp)", f)
+	res := []UniqueUsersResult{}
+	err := r.q().Select(&res, query, args...)
+	return res, err
+}
@@ -0,0 +1,67 @@
+package tracking
+
+import "time"
+
+// Filters the events a stat is computed from.
+// Zero times leave the range open.
+type StatsFilter struct {
+	LevelId int
+	From    time.Time
+	To      time.Time
+}
+
+type LevelStartsResult struct {
+	GameStarts int       `db:"game_starts" json:"gameStarts"`
+	Date       time.Time `db:"date" json:"date"`
+}
+
+type LevelCompleteResult struct {
+	GameComplete int       `db:"game_complete" json:"gameCompletes"`
+	Date         time.Time `db:"date" json:"date"`
+}
+
+type AvgTimeResult struct {
+	AvgTime float64   `db:"avg_time" json:"avgTime"`
+	Date    time.Time `db:"date" json:"date"`
+}
+
+type UniqueUsersResult struct {
+	UniqueUsers int `db:"unique_users" json:"uniqueUsers"`
+	Month       int `db:"month" json:"month"`
+}
+
+type Repository interface {
+	Insert(e *Event) error
+	// game_start events per day
+	LevelStarts(f StatsFilter) ([]LevelStartsResult, error)
+	// game_finish events per day
+	LevelCompletes(f StatsFilter) ([]LevelCompleteResult, error)
+	// Average game_finish time per day
+	AvgTime(f StatsFilter) ([]AvgTimeResult, error)
+	// Distinct users that finished the level per month
+	UniqueUsers(f StatsFilter) ([]UniqueUsersResult, error)
+}
+
+var repository Repository = PgRepository{}
+
+// Replaces the repository events are stored in.
+// Postgres is used by default.
+func SetRepository(r Repository) {
+	repository = r
+}
+
+func LevelStarts(f StatsFilter) ([]LevelStartsResult, error) {
+	return repository.LevelStarts(f)
+}
+
+func LevelCompletes(f StatsFilter) ([]LevelCompleteResult, error) {
+	return repository.LevelCompletes(f)
+}
+
+func AvgTime(f StatsFilter) ([]AvgTimeResult, error) {
+	return repository.AvgTime(f)
+}
+
+func UniqueUsers(f StatsFilter) ([]UniqueUsersResult, error) {
+	return repository.UniqueUsers(f)
+}